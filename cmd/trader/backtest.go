@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/internal/backtest"
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/trade"
+)
+
+// backtest replays recorded tickers through trades of the database, the database is not changed
+func (c cli) backtest(args []string) error {
+	fs, output := c.flagSet("backtest")
+	data := fs.String("data", "", "recorded book tickers, a .csv or .jsonl file")
+	status := fs.String("status", string(trade.StatusActive), "trades with the status are replayed")
+	symbol := fs.String("symbol", "", "only trades of the symbol, also the symbol of tickers which do not name one")
+	policy := fs.String("policy", cfg.AllocationPolicy, "allocation policy, fifo, pro_rata or best_price")
+	filters := fs.Bool("filters", false, "follow symbol filters read from Binance, there are no filters otherwise")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *data == "" {
+		return errors.New("-data is required")
+	}
+
+	allocationPolicy, err := trade.ParseAllocationPolicy(*policy)
+	if err != nil {
+		return err
+	}
+
+	guard := trade.Guard{MaxSpreadPercent: cfg.Guard.MaxSpreadPercent, MaxDeviationPercent: cfg.Guard.MaxDeviationPercent}
+	fees := exchange.FeeSchedule{MakerPercent: cfg.Fees.MakerPercent, TakerPercent: cfg.Fees.TakerPercent}
+
+	ticks, err := backtest.ReadTicksFile(*data, *symbol)
+	if err != nil {
+		return err
+	}
+
+	trades, err := trade.NewRepository(c.db, c.logger).FindAll(trade.Filter{
+		Status: trade.Status(strings.ToUpper(*status)),
+		Symbol: strings.ToUpper(*symbol),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(trades) == 0 {
+		return errors.New("no trades to replay")
+	}
+
+	ctx := context.Background()
+
+	var symbols []exchange.SymbolInfo
+
+	if *filters {
+		binanceExchange := exchange.NewBinance(binance.NewClient(cfg.Binance.ApiKey, cfg.Binance.ApiSecret), c.logger)
+
+		for _, name := range tradeSymbols(trades) {
+			info, err := binanceExchange.SymbolInfo(ctx, name)
+			if err != nil {
+				return fmt.Errorf("symbol %s: %w", name, err)
+			}
+
+			symbols = append(symbols, *info)
+		}
+	}
+
+	report, err := backtest.NewBacktest(c.logger, allocationPolicy, guard, fees, symbols).Run(ctx, trades, ticks)
+	if err != nil {
+		return err
+	}
+
+	return c.printReport(*output, report)
+}
+
+func tradeSymbols(trades []trade.Trade) []string {
+	var symbols []string
+
+	seen := map[string]bool{}
+
+	for i := range trades {
+		if symbol := trades[i].GetSymbol(); !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+func (c cli) printReport(output string, report *backtest.Report) error {
+	if output == outputJSON {
+		return c.printJSON(report)
+	}
+
+	if output != outputTable {
+		return fmt.Errorf("unknown output %q", output)
+	}
+
+	fmt.Fprintf(c.out, "Replayed %d tickers from %s to %s\n\n",
+		report.Ticks, report.From.Format("2006-01-02 15:04:05"), report.To.Format("2006-01-02 15:04:05"))
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRADE ID\tSYMBOL\tSIDE\tSTATUS\tSIZE\tPRICE\tFILLED\tUNFILLED\tAVG PRICE\tFILLS\tFEES\tNET\tTIME TO COMPLETE")
+
+	for _, t := range report.Trades {
+		timeToComplete := "-"
+		if t.Completed {
+			timeToComplete = t.TimeToComplete.String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			t.TradeId, t.Symbol, t.Side, t.Status, t.Size, t.Price, t.Filled, t.Unfilled, t.AveragePrice.Round(8), len(t.Fills),
+			t.Proceeds.Fees.Round(8), t.Proceeds.Net.Round(8), timeToComplete,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out)
+
+	w = tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRADE ID\tORDER ID\tTIME\tQUANTITY\tPRICE")
+
+	for _, t := range report.Trades {
+		for _, f := range t.Fills {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				t.TradeId, f.OrderId, f.Time.Format("2006-01-02 15:04:05.000"), f.Quantity, f.Price.Round(8),
+			)
+		}
+	}
+
+	return w.Flush()
+}
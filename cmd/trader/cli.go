@@ -0,0 +1,546 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+// cli runs the commands, management commands go through the trade and order repositories
+type cli struct {
+	db     *gorm.DB
+	logger logus.Logger
+	out    io.Writer
+}
+
+func newCli(
+	db *gorm.DB,
+	logger logus.Logger,
+	out io.Writer,
+) cli {
+	return cli{db, logger, out}
+}
+
+func (c cli) execute(command string, args []string) error {
+	switch command {
+	case "run":
+		return c.run()
+	case "trade":
+		return c.trade(args)
+	case "orders":
+		return c.orders(args)
+	case "backtest":
+		return c.backtest(args)
+	case "report":
+		return c.report(args)
+	}
+
+	return fmt.Errorf("unknown command %q, see trader help", command)
+}
+
+func (c cli) manager() trade.Manager {
+	return trade.NewManager(c.logger, trade.NewRepository(c.db, c.logger), order.NewRepository(c.db, c.logger))
+}
+
+func (c cli) trade(args []string) error {
+	if len(args) == 0 {
+		return errors.New("trade command is missing, see trader help")
+	}
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "add":
+		return c.tradeAdd(args)
+	case "list":
+		return c.tradeList(args)
+	case "show":
+		return c.tradeShow(args)
+	case "pause":
+		return c.tradeChange(command, args, c.manager().Pause)
+	case "resume":
+		return c.tradeChange(command, args, c.manager().Resume)
+	case "cancel":
+		return c.tradeChange(command, args, c.manager().Cancel)
+	case "reset":
+		return c.tradeChange(command, args, c.manager().Reset)
+	}
+
+	return fmt.Errorf("unknown trade command %q, see trader help", command)
+}
+
+func (c cli) tradeAdd(args []string) error {
+	fs, output := c.flagSet("trade add")
+	base := fs.String("base", "", "currency which is sold or bought, e.g. BNB")
+	quote := fs.String("quote", "", "currency of the price, e.g. USDT")
+	side := fs.String("side", string(exchange.SideSell), "SELL or BUY")
+	size := fs.String("size", "", "size in the base currency")
+	price := fs.String("price", "", "take-profit limit price in the quote currency")
+	stop := fs.String("stop", "", "stop-loss price in the quote currency")
+	stopOffset := fs.String("stop-offset", "", "how far beyond the stop price the stop may still order")
+	trail := fs.String("trail", "", "trailing stop distance from the peak, in the quote currency or in percent with a % suffix")
+	trailActivation := fs.String("trail-activation", "", "price the trailing stop begins at")
+
+	style := fs.String("style", string(trade.StyleImmediate), "IMMEDIATE, TWAP or ICEBERG")
+	slice := fs.String("slice", "", "most a single order of a TWAP or iceberg trade may take")
+	sliceInterval := fs.Duration("slice-interval", 0, "least time between orders of a TWAP or iceberg trade, e.g. 1m")
+	twapDuration := fs.Duration("twap-duration", 0, "time TWAP spreads the size over, e.g. 1h")
+	start := fs.String("start", "", "time the trade begins at, RFC 3339 or a duration from now, e.g. 2h")
+	expires := fs.String("expires", "", "time the trade expires at, RFC 3339 or a duration from now, e.g. 24h")
+	maxSpread := fs.String("max-spread", "", "widest bid/ask spread in percent of the mid price the trade may order on")
+	maxDeviation := fs.String("max-deviation", "", "how far in percent the order price may be worse than the price which triggered it")
+
+	var levels levelsFlag
+	fs.Var(&levels, "level", "take-profit ladder level as price:size or price:percent%, may be repeated instead of -price")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// size of a ladder may be given by its levels
+	parseSize := parseDecimalFlag
+	if len(levels) > 0 {
+		parseSize = parseOptionalDecimalFlag
+	}
+
+	orderSize, err := parseSize("size", *size)
+	if err != nil {
+		return err
+	}
+
+	if *price == "" && *stop == "" && *trail == "" && len(levels) == 0 {
+		return errors.New("-price, -level, -stop or -trail is required")
+	}
+
+	orderPrice, err := parseOptionalDecimalFlag("price", *price)
+	if err != nil {
+		return err
+	}
+
+	stopPrice, err := parseOptionalDecimalFlag("stop", *stop)
+	if err != nil {
+		return err
+	}
+
+	stopLimitOffset, err := parseOptionalDecimalFlag("stop-offset", *stopOffset)
+	if err != nil {
+		return err
+	}
+
+	trailingDistance, err := parseOptionalDecimalFlag("trail", strings.TrimSuffix(*trail, "%"))
+	if err != nil {
+		return err
+	}
+
+	var trailingPercent decimal.Decimal
+	if strings.HasSuffix(*trail, "%") {
+		trailingDistance, trailingPercent = decimal.Zero, trailingDistance
+	}
+
+	trailingActivationPrice, err := parseOptionalDecimalFlag("trail-activation", *trailActivation)
+	if err != nil {
+		return err
+	}
+
+	sliceSize, err := parseOptionalDecimalFlag("slice", *slice)
+	if err != nil {
+		return err
+	}
+
+	startAt, err := parseTimeFlag("start", *start)
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := parseTimeFlag("expires", *expires)
+	if err != nil {
+		return err
+	}
+
+	maxSpreadPercent, err := parseOptionalDecimalFlag("max-spread", *maxSpread)
+	if err != nil {
+		return err
+	}
+
+	maxDeviationPercent, err := parseOptionalDecimalFlag("max-deviation", *maxDeviation)
+	if err != nil {
+		return err
+	}
+
+	t, err := c.manager().Add(trade.Trade{
+		OrderSize:               orderSize,
+		OrderSizeCurrency:       *base,
+		OrderPrice:              orderPrice,
+		OrderPriceCurrency:      *quote,
+		StopPrice:               stopPrice,
+		StopLimitOffset:         stopLimitOffset,
+		TrailingDistance:        trailingDistance,
+		TrailingPercent:         trailingPercent,
+		TrailingActivationPrice: trailingActivationPrice,
+		Levels:                  levels,
+		Style:                   trade.Style(*style),
+		SliceSize:               sliceSize,
+		SliceInterval:           *sliceInterval,
+		TwapDuration:            *twapDuration,
+		StartAt:                 startAt,
+		ExpiresAt:               expiresAt,
+		MaxSpreadPercent:        maxSpreadPercent,
+		MaxDeviationPercent:     maxDeviationPercent,
+		Side:                    exchange.Side(*side),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.printTrades(*output, *t)
+}
+
+func (c cli) tradeList(args []string) error {
+	fs, output := c.flagSet("trade list")
+	status := fs.String("status", "", "only trades with the status, e.g. ACTIVE")
+	symbol := fs.String("symbol", "", "only trades of the symbol, e.g. BNBUSDT")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	trades, err := trade.NewRepository(c.db, c.logger).FindAll(trade.Filter{
+		Status: trade.Status(strings.ToUpper(*status)),
+		Symbol: strings.ToUpper(*symbol),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.printTrades(*output, trades...)
+}
+
+func (c cli) tradeShow(args []string) error {
+	fs, output := c.flagSet("trade show")
+
+	id, err := parseWithId(fs, args)
+	if err != nil {
+		return err
+	}
+
+	t, err := trade.NewRepository(c.db, c.logger).FindOneById(id)
+	if err != nil {
+		return err
+	}
+
+	orders, err := order.NewRepository(c.db, c.logger).FindAll(order.Filter{TradeId: id})
+	if err != nil {
+		return err
+	}
+
+	proceeds := trade.NewProceeds(*t, orders)
+	t.Proceeds = &proceeds
+
+	if *output == outputJSON {
+		for i := range orders {
+			t.Orders = append(t.Orders, &orders[i])
+		}
+
+		return c.printJSON(t)
+	}
+
+	if err := c.printTrades(*output, *t); err != nil {
+		return err
+	}
+
+	if t.IsLadder() {
+		fmt.Fprintln(c.out)
+
+		if err := c.printLevels(t.Levels); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(c.out, "\nGross %s %s, fees %s, net %s\n", proceeds.Gross, t.OrderPriceCurrency, proceeds.Fees, proceeds.Net)
+
+	assets := make([]string, 0, len(proceeds.OtherFees))
+	for asset := range proceeds.OtherFees {
+		assets = append(assets, asset)
+	}
+
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		fmt.Fprintf(c.out, "Fees in %s: %s\n", asset, proceeds.OtherFees[asset])
+	}
+
+	if proceeds.UnknownFees > 0 {
+		fmt.Fprintf(c.out, "Fees of %d orders are not known\n", proceeds.UnknownFees)
+	}
+
+	if t.LastSkipReason != "" && t.LastSkippedAt != nil {
+		fmt.Fprintf(c.out, "\nSkipped since %s: %s\n", t.LastSkippedAt.Format("2006-01-02 15:04:05"), t.LastSkipReason)
+	}
+
+	fmt.Fprintln(c.out)
+
+	return c.printOrders(*output, orders...)
+}
+
+func (c cli) tradeChange(command string, args []string, change func(id uuid.UUID) (*trade.Trade, error)) error {
+	fs, output := c.flagSet("trade " + command)
+
+	id, err := parseWithId(fs, args)
+	if err != nil {
+		return err
+	}
+
+	t, err := change(id)
+	if err != nil {
+		return err
+	}
+
+	return c.printTrades(*output, *t)
+}
+
+func (c cli) orders(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return errors.New("orders command is missing, see trader help")
+	}
+
+	fs, output := c.flagSet("orders list")
+	tradeId := fs.String("trade", "", "only orders of the trade")
+	symbol := fs.String("symbol", "", "only orders of the symbol, e.g. BNBUSDT")
+	status := fs.String("status", "", "only orders with the status, e.g. FILLED")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	filter := order.Filter{
+		Symbol: strings.ToUpper(*symbol),
+		Status: exchange.OrderStatus(strings.ToUpper(*status)),
+	}
+
+	if *tradeId != "" {
+		id, err := uuid.Parse(*tradeId)
+		if err != nil {
+			return fmt.Errorf("invalid trade id %q: %w", *tradeId, err)
+		}
+
+		filter.TradeId = id
+	}
+
+	orders, err := order.NewRepository(c.db, c.logger).FindAll(filter)
+	if err != nil {
+		return err
+	}
+
+	return c.printOrders(*output, orders...)
+}
+
+// flagSet returns flags of a command with the common output flag
+func (c cli) flagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	output := fs.String("output", outputTable, "output format, table or json")
+
+	return fs, output
+}
+
+// parseWithId parses flags of a command taking a trade id, the id may come before or after the flags
+func parseWithId(fs *flag.FlagSet, args []string) (uuid.UUID, error) {
+	var id string
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, args = args[0], args[1:]
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return uuid.Nil, err
+	}
+
+	if id == "" && fs.NArg() > 0 {
+		id = fs.Arg(0)
+	}
+
+	if id == "" {
+		return uuid.Nil, fmt.Errorf("%s: trade id is required", fs.Name())
+	}
+
+	res, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid trade id %q: %w", id, err)
+	}
+
+	return res, nil
+}
+
+func parseDecimalFlag(name string, v string) (decimal.Decimal, error) {
+	if v == "" {
+		return decimal.Zero, fmt.Errorf("-%s is required", name)
+	}
+
+	res, err := decimal.NewFromString(v)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid -%s %q", name, v)
+	}
+
+	return res, nil
+}
+
+// parseOptionalDecimalFlag returns zero when the flag is not given
+func parseOptionalDecimalFlag(name string, v string) (decimal.Decimal, error) {
+	if v == "" {
+		return decimal.Zero, nil
+	}
+
+	return parseDecimalFlag(name, v)
+}
+
+// parseTimeFlag reads an RFC 3339 time, a UTC date or a duration from now, nil when the flag is not given
+func parseTimeFlag(name string, v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	if d, err := time.ParseDuration(v); err == nil {
+		res := time.Now().Add(d)
+
+		return &res, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if res, err := time.Parse(layout, v); err == nil {
+			return &res, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid -%s %q", name, v)
+}
+
+// levelsFlag collects repeated -level flags, a level is price:size or price:percent%
+type levelsFlag []trade.Level
+
+func (f *levelsFlag) String() string {
+	return ""
+}
+
+func (f *levelsFlag) Set(v string) error {
+	price, size, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("level %q is not price:size", v)
+	}
+
+	l := trade.Level{}
+
+	var err error
+	if l.Price, err = decimal.NewFromString(price); err != nil {
+		return fmt.Errorf("invalid level price %q", price)
+	}
+
+	if strings.HasSuffix(size, "%") {
+		if l.Fraction, err = decimal.NewFromString(strings.TrimSuffix(size, "%")); err != nil {
+			return fmt.Errorf("invalid level percent %q", size)
+		}
+
+		l.Fraction = l.Fraction.Div(decimal.NewFromInt(100))
+	} else if l.Size, err = decimal.NewFromString(size); err != nil {
+		return fmt.Errorf("invalid level size %q", size)
+	}
+
+	*f = append(*f, l)
+
+	return nil
+}
+
+func (c cli) printTrades(output string, trades ...trade.Trade) error {
+	if output == outputJSON {
+		return c.printJSON(trades)
+	}
+
+	if output != outputTable {
+		return fmt.Errorf("unknown output %q", output)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSYMBOL\tSIDE\tSTATUS\tSIZE\tSIZE LEFT\tPRICE\tSTOP\tCREATED")
+
+	for _, t := range trades {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.GetSymbol(), t.GetSide(), t.Status, t.OrderSize, t.OrderSizeLeft, t.OrderPrice, t.StopPrice, t.CreatedAt.Format("2006-01-02 15:04:05"),
+		)
+	}
+
+	return w.Flush()
+}
+
+func (c cli) printLevels(levels []trade.Level) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LEVEL PRICE\tSIZE\tSIZE LEFT")
+
+	for _, l := range levels {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Price, l.Size, l.SizeLeft)
+	}
+
+	return w.Flush()
+}
+
+func (c cli) printOrders(output string, orders ...order.Order) error {
+	if output == outputJSON {
+		return c.printJSON(orders)
+	}
+
+	if output != outputTable {
+		return fmt.Errorf("unknown output %q", output)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER ID\tTRADE ID\tSYMBOL\tSIDE\tTRIGGER\tSTATUS\tSIZE\tPRICE\tEXECUTED\tQUOTE QTY\tFEE\tCREATED")
+
+	for _, o := range orders {
+		fee := "-"
+		if o.FeeAsset != "" {
+			fee = o.Fee.String() + " " + o.FeeAsset
+
+			assets := make([]string, 0, len(o.OtherFees))
+			for asset := range o.OtherFees {
+				assets = append(assets, asset)
+			}
+
+			sort.Strings(assets)
+
+			for _, asset := range assets {
+				fee += " + " + o.OtherFees[asset].String() + " " + asset
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			o.OrderId, o.TradeId, o.Symbol, o.Side, o.Trigger, o.Status, o.OrderSize, o.OrderPrice, o.ExecutedSize, o.CumulativeQuoteQty, fee, o.CreatedAt.Format("2006-01-02 15:04:05"),
+		)
+	}
+
+	return w.Flush()
+}
+
+func (c cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/backtest"
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/migration"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testLogger = &logus.TestLogger{}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, migration.NewMigrator(db, testLogger).Migrate())
+
+	return db
+}
+
+func execute(t *testing.T, db *gorm.DB, args ...string) (string, error) {
+	var out bytes.Buffer
+
+	err := newCli(db, testLogger, &out).execute(args[0], args[1:])
+
+	return out.String(), err
+}
+
+func TestCli_Trade(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "bnb", "-quote", "usdt", "-size", "1.5", "-price", "115", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, "BNBUSDT", added[0].GetSymbol())
+	assert.Equal(t, "1.5", added[0].OrderSizeLeft.String())
+
+	id := added[0].ID.String()
+
+	out, err = execute(t, db, "trade", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "SIZE LEFT")
+	assert.Contains(t, out, id)
+
+	out, err = execute(t, db, "trade", "pause", id)
+	require.NoError(t, err)
+	assert.Contains(t, out, string(trade.StatusPaused))
+
+	out, err = execute(t, db, "trade", "list", "-status", "active")
+	require.NoError(t, err)
+	assert.NotContains(t, out, id)
+
+	_, err = execute(t, db, "trade", "pause", id)
+	assert.ErrorIs(t, err, trade.ErrInvalidStatus)
+
+	require.NoError(t, order.NewRepository(db, testLogger).Create(order.Order{
+		OrderId: "1",
+		TradeId: added[0].ID,
+		Symbol:  "BNBUSDT",
+		Status:  exchange.OrderStatusFilled,
+	}))
+
+	out, err = execute(t, db, "trade", "show", "-output", "json", id)
+	require.NoError(t, err)
+
+	var shown trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &shown))
+	assert.Equal(t, trade.StatusPaused, shown.Status)
+	require.Len(t, shown.Orders, 1)
+	assert.Equal(t, "1", shown.Orders[0].OrderId)
+
+	out, err = execute(t, db, "orders", "list", "-trade", id, "-status", "filled")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	for _, command := range []string{"cancel", "reset"} {
+		_, err = execute(t, db, "trade", command, id)
+		require.NoError(t, err)
+	}
+}
+
+func TestCli_TradeAdd_Stops(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-trail", "2.5%", "-trail-activation", "120", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, "2.5", added[0].TrailingPercent.String())
+	assert.Equal(t, "0", added[0].TrailingDistance.String())
+	assert.Equal(t, "120", added[0].TrailingActivationPrice.String())
+
+	out, err = execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-stop", "100", "-trail", "3", "-output", "json")
+	require.NoError(t, err)
+
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, "100", added[0].StopPrice.String())
+	assert.Equal(t, "3", added[0].TrailingDistance.String())
+}
+
+func TestCli_TradeAdd_Ladder(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "3", "-level", "120:50%", "-level", "115:1", "-level", "130:0.5", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, "115", added[0].OrderPrice.String())
+	require.Len(t, added[0].Levels, 3)
+	assert.Equal(t, "1.5", added[0].Levels[0].Size.String())
+	assert.Equal(t, "0.5", added[0].Levels[0].Fraction.String())
+
+	out, err = execute(t, db, "trade", "show", added[0].ID.String())
+	require.NoError(t, err)
+	assert.Contains(t, out, "LEVEL PRICE")
+	assert.Contains(t, out, "130")
+}
+
+func TestCli_TradeAdd_Style(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "10", "-price", "115", "-style", "twap", "-twap-duration", "1h", "-slice-interval", "5m", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, trade.StyleTWAP, added[0].Style)
+	assert.Equal(t, time.Hour, added[0].TwapDuration)
+	assert.Equal(t, 5*time.Minute, added[0].SliceInterval)
+
+	_, err = execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "10", "-price", "115", "-style", "ICEBERG")
+	assert.ErrorIs(t, err, trade.ErrInvalidTrade)
+}
+
+func TestCli_TradeAdd_Guard(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "10", "-price", "115", "-max-spread", "0.5", "-max-deviation", "1", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+	assert.Equal(t, "0.5", added[0].MaxSpreadPercent.String())
+	assert.Equal(t, "1", added[0].MaxDeviationPercent.String())
+
+	_, err = execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "10", "-price", "115", "-max-spread", "-1")
+	assert.ErrorIs(t, err, trade.ErrInvalidTrade)
+}
+
+func TestCli_Backtest(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "30", "-price", "111", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+
+	id := added[0].ID.String()
+
+	// a trade which is not traded yet is tried out first
+	_, err = execute(t, db, "trade", "pause", id)
+	require.NoError(t, err)
+
+	data := filepath.Join(t.TempDir(), "tickers.csv")
+	require.NoError(t, os.WriteFile(data, []byte(`time,bidPrice,bidQty,askPrice,askQty
+2024-01-01T00:00:00Z,115,20,116,5
+2024-01-01T00:01:00Z,112,50,113,5
+`), 0o600))
+
+	_, err = execute(t, db, "backtest", "-data", data, "-symbol", "BNBUSDT")
+	assert.ErrorContains(t, err, "no trades to replay")
+
+	out, err = execute(t, db, "backtest", "-data", data, "-symbol", "BNBUSDT", "-status", "paused")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Replayed 2 tickers")
+	assert.Contains(t, out, id)
+	assert.Contains(t, out, "1m0s")
+
+	out, err = execute(t, db, "backtest", "-data", data, "-symbol", "BNBUSDT", "-status", "paused", "-output", "json")
+	require.NoError(t, err)
+
+	var report backtest.Report
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	require.Len(t, report.Trades, 1)
+	assert.Equal(t, "114", report.Trades[0].AveragePrice.String())
+
+	// the database is not changed
+	stored, err := trade.NewRepository(db, testLogger).FindOneById(added[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "30", stored.OrderSizeLeft.String())
+	assert.Equal(t, trade.StatusPaused, stored.Status)
+}
+
+func TestCli_Validation(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "unknown command", args: []string{"sell"}, want: "unknown command"},
+		{name: "missing trade command", args: []string{"trade"}, want: "trade command is missing"},
+		{name: "missing size", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-price", "115"}, want: "-size is required"},
+		{name: "invalid price", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-price", "cheap"}, want: "invalid -price"},
+		{name: "missing price", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1"}, want: "-price, -level, -stop or -trail is required"},
+		{name: "invalid expires", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-price", "115", "-expires", "tomorrow"}, want: "invalid -expires"},
+		{name: "invalid level", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-level", "115"}, want: "is not price:size"},
+		{name: "invalid trail", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-trail", "2%%"}, want: "invalid -trail"},
+		{name: "invalid stop", args: []string{"trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "1", "-price", "115", "-stop", "120"}, want: "has to be below price"},
+		{name: "invalid trade", args: []string{"trade", "add", "-base", "BNB", "-size", "1", "-price", "115"}, want: trade.ErrInvalidTrade.Error()},
+		{name: "missing id", args: []string{"trade", "pause"}, want: "trade id is required"},
+		{name: "invalid id", args: []string{"trade", "show", "42"}, want: "invalid trade id"},
+		{name: "unknown output", args: []string{"trade", "list", "-output", "xml"}, want: "unknown output"},
+		{name: "missing backtest data", args: []string{"backtest"}, want: "-data is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := execute(t, db, tt.args...)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestCli_Report(t *testing.T) {
+	db := newTestDB(t)
+
+	out, err := execute(t, db, "trade", "add", "-base", "BNB", "-quote", "USDT", "-size", "2", "-price", "100", "-output", "json")
+	require.NoError(t, err)
+
+	var added []trade.Trade
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	require.Len(t, added, 1)
+
+	require.NoError(t, order.NewRepository(db, testLogger).Create(order.Order{
+		OrderId:            "1",
+		TradeId:            added[0].ID,
+		Symbol:             "BNBUSDT",
+		Status:             exchange.OrderStatusFilled,
+		ExecutedSize:       decimal.RequireFromString("2"),
+		CumulativeQuoteQty: decimal.RequireFromString("198"),
+		Fee:                decimal.RequireFromString("0.198"),
+		FeeAsset:           "USDT",
+		ExchangeUpdatedAt:  time.Now(),
+	}))
+
+	out, err = execute(t, db, "report", "-symbol", "bnbusdt")
+	require.NoError(t, err)
+	assert.Contains(t, out, added[0].ID.String())
+	assert.Contains(t, out, "SLIPPAGE %")
+	assert.Contains(t, out, "197.802")
+	assert.Contains(t, out, "OVERALL")
+
+	out, err = execute(t, db, "report", "-output", "csv")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "row,trade_id,symbol"))
+	assert.True(t, strings.HasPrefix(lines[1], "trade,"+added[0].ID.String()))
+	assert.True(t, strings.HasPrefix(lines[2], "total,,BNBUSDT,SELL"))
+	// sold at 99 on average against the order price of 100
+	assert.True(t, strings.HasPrefix(lines[3], "overall,,,,,,,1,1,,,,,,,1,"), lines[3])
+	assert.True(t, strings.HasSuffix(lines[3], ",198,0.198,197.802"), lines[3])
+
+	out, err = execute(t, db, "report", "-from", "2000-01-01", "-to", "-24h", "-output", "json")
+	require.NoError(t, err)
+
+	var report struct{ Trades []any }
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Empty(t, report.Trades)
+
+	_, err = execute(t, db, "report", "-from", "yesterday")
+	assert.ErrorContains(t, err, "invalid -from")
+}
@@ -1,17 +1,14 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os"
-	"time"
+	"path/filepath"
+	"strings"
 
-	"github.com/adshao/go-binance/v2"
 	"github.com/beng90/trader/internal/config"
-	"github.com/beng90/trader/internal/order"
-	"github.com/beng90/trader/internal/orderbookticker"
-	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/internal/migration"
 	"github.com/beng90/trader/pkg/logus"
 	"github.com/kelseyhightower/envconfig"
 	"gorm.io/driver/sqlite"
@@ -28,35 +25,105 @@ func init() {
 	}
 }
 
+const usage = `Usage: trader <command> [arguments]
+
+Commands:
+  run                  watch tickers and trade active trades, default when no command is given
+  trade add            add a trade: -base BNB -quote USDT -size 1.5 -price 115 [-side SELL|BUY]
+                       [-stop 100 [-stop-offset 2]] [-trail 2|2% [-trail-activation 120]]
+                       [-style TWAP -twap-duration 1h -slice-interval 5m | -style ICEBERG -slice 0.5 [-slice-interval 1m]]
+                       [-start 2h] [-expires 2024-01-02T00:00:00Z] [-max-spread 0.5] [-max-deviation 1],
+                       -price may be left out when a stop is given, -level 120:1 or -level 120:50% may be
+                       repeated instead of -price for a take-profit ladder
+  trade list           list trades [-status ACTIVE] [-symbol BNBUSDT]
+  trade show <id>      show a trade with its orders
+  trade pause <id>     stop trading an active trade
+  trade resume <id>    trade a paused trade again
+  trade cancel <id>    stop trading a trade for good
+  trade reset <id>     make the whole size of a trade available again
+  orders list          list orders [-trade <id>] [-symbol BNBUSDT] [-status FILLED]
+  backtest             replay recorded tickers through trades: -data tickers.csv [-status ACTIVE] [-symbol BNBUSDT]
+                       [-policy fifo] [-filters]
+  report               how trades were executed, each of them and totals by symbol and side [-symbol BNBUSDT]
+                       [-from 2024-01-01] [-to 2024-02-01T00:00:00Z], -output csv prints CSV
+
+Commands other than run print a table, -output json prints JSON instead. With TRADER_MODE=paper all commands
+work with paper trades, their orders are never sent to Binance.
+`
+
 func main() {
-	fmt.Println("Start trader...")
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+
+		return
+	}
 
-	ctx := context.Background()
-	l := log.New(os.Stdout, "", 5)
+	// output of the management commands is kept apart from the logs
+	logOutput := os.Stderr
+	if command == "run" {
+		logOutput = os.Stdout
+	}
+
+	l := log.New(logOutput, "", 5)
 	stdLogger := logus.NewStdLogger(l)
 
-	l.Println("DB path:", cfg.Db.Path)
+	path, err := dbPath(cfg)
+	checkErr(err)
+
+	l.Println("DB path:", path)
 	dbConfig := &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)}
-	db, err := gorm.Open(sqlite.Open(cfg.Db.Path), dbConfig)
+	db, err := gorm.Open(sqlite.Open(path), dbConfig)
 	checkErr(err)
 
-	err = db.AutoMigrate(&trade.Trade{}, &order.Order{})
+	err = migration.NewMigrator(db, stdLogger).Migrate()
 	checkErr(err)
 
-	client := binance.NewClient(cfg.Binance.ApiKey, cfg.Binance.ApiSecret)
+	cli := newCli(db, stdLogger, os.Stdout)
+	err = cli.execute(command, args)
+
+	if closeErr := closeDB(db); closeErr != nil {
+		stdLogger.Error(closeErr)
+	}
 
-	orderBookTickerRepository := orderbookticker.NewRepository(client, stdLogger)
-	tradeRepository := trade.NewRepository(db, stdLogger)
-	orderRepository := order.NewRepository(db, stdLogger)
-	orderCreator := trade.NewOrderCreator(stdLogger, tradeRepository, orderRepository)
-	trader := trade.NewTrader(stdLogger, orderBookTickerRepository, tradeRepository, orderCreator)
+	// logs are written unbuffered, syncing only makes sure they reach the disk when redirected to a file
+	_ = logOutput.Sync()
 
-	for range time.Tick(time.Millisecond * time.Duration(cfg.Frequency)) {
-		err = trader.Watch(ctx)
-		if err != nil {
-			_ = fmt.Errorf("%s\n", err)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// dbPath is the database of the mode, every command works with paper trades in paper mode
+func dbPath(cfg config.Config) (string, error) {
+	switch cfg.Mode {
+	case config.ModeLive:
+		return cfg.Db.Path, nil
+	case config.ModePaper:
+		if cfg.Paper.Db.Path != "" {
+			return cfg.Paper.Db.Path, nil
 		}
+
+		ext := filepath.Ext(cfg.Db.Path)
+
+		return strings.TrimSuffix(cfg.Db.Path, ext) + "-paper" + ext, nil
 	}
+
+	return "", fmt.Errorf("unknown mode %q", cfg.Mode)
+}
+
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
 }
 
 func checkErr(err error) {
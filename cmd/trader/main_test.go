@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/beng90/trader/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDbPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr bool
+	}{
+		{name: "live", cfg: config.Config{Mode: config.ModeLive, Db: config.Db{Path: "data/sqlite.db"}}, want: "data/sqlite.db"},
+		{name: "paper", cfg: config.Config{Mode: config.ModePaper, Db: config.Db{Path: "data/sqlite.db"}}, want: "data/sqlite-paper.db"},
+		{name: "paper path", cfg: config.Config{Mode: config.ModePaper, Db: config.Db{Path: "sqlite.db"}, Paper: config.Paper{Db: config.Db{Path: "paper.db"}}}, want: "paper.db"},
+		{name: "unknown mode", cfg: config.Config{Mode: "demo"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dbPath(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/report"
+	"github.com/beng90/trader/internal/trade"
+)
+
+// report tells how trades of the database were executed, each of them, totals by symbol and side and the overall total
+func (c cli) report(args []string) error {
+	fs, output := c.flagSet("report")
+	fs.Lookup("output").Usage = "output format, table, csv or json"
+	symbol := fs.String("symbol", "", "only trades of the symbol")
+	from := fs.String("from", "", "only trades created at or after, RFC 3339, a date or a duration from now, e.g. -24h")
+	to := fs.String("to", "", "only trades created before, RFC 3339, a date or a duration from now")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := report.Filter{Symbol: strings.ToUpper(*symbol)}
+
+	fromTime, err := parseTimeFlag("from", *from)
+	if err != nil {
+		return err
+	}
+
+	if fromTime != nil {
+		filter.From = *fromTime
+	}
+
+	toTime, err := parseTimeFlag("to", *to)
+	if err != nil {
+		return err
+	}
+
+	if toTime != nil {
+		filter.To = *toTime
+	}
+
+	reporter := report.NewReporter(c.logger, trade.NewRepository(c.db, c.logger), order.NewRepository(c.db, c.logger))
+
+	res, err := reporter.Report(filter)
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case outputJSON:
+		return c.printJSON(res)
+	case outputCSV:
+		return c.printExecutionCSV(res)
+	case outputTable:
+		return c.printExecution(res)
+	}
+
+	return fmt.Errorf("unknown output %q", *output)
+}
+
+func (c cli) printExecution(res *report.Report) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRADE ID\tSYMBOL\tSIDE\tSTATUS\tSIZE\tFILLED\tREMAINING\tPRICE\tREF PRICE\tAVG PRICE\tSLIPPAGE %\tORDERS\tTIME TO COMPLETE\tGROSS\tFEES\tNET")
+
+	for _, t := range res.Trades {
+		timeToComplete := "-"
+		if t.CompletedAt != nil {
+			timeToComplete = t.TimeToComplete.String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			t.TradeId, t.Symbol, t.Side, t.Status, t.Size, t.Filled, t.Remaining, t.OrderPrice, t.ReferencePrice.Round(8), t.AveragePrice.Round(8), t.SlippagePercent.StringFixed(2),
+			t.Orders, timeToComplete, t.Proceeds.Gross.Round(8), t.Proceeds.Fees.Round(8), t.Proceeds.Net.Round(8),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out)
+
+	w = tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tSIDE\tTRADES\tCOMPLETED\tORDERS\tFILLED\tREMAINING\tAVG PRICE\tSLIPPAGE %\tAVG TIME TO COMPLETE\tGROSS\tFEES\tNET")
+
+	for _, t := range res.Totals {
+		timeToComplete := "-"
+		if t.Completed > 0 {
+			timeToComplete = t.AverageTimeToComplete.String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Symbol, t.Side, t.Trades, t.Completed, t.Orders, t.Filled, t.Remaining, t.AveragePrice.Round(8), t.SlippagePercent.StringFixed(2),
+			timeToComplete, t.Proceeds.Gross.Round(8), t.Proceeds.Fees.Round(8), t.Proceeds.Net.Round(8),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	o := res.Overall
+
+	timeToComplete := "-"
+	if o.Completed > 0 {
+		timeToComplete = o.AverageTimeToComplete.String()
+	}
+
+	fmt.Fprintln(c.out)
+
+	// slippage and fees of trades quoted in several currencies cannot be summed
+	if o.QuoteCurrency == "" {
+		_, err := fmt.Fprintf(c.out, "OVERALL  trades: %d, completed: %d, orders: %d, avg time to complete: %s\n",
+			o.Trades, o.Completed, o.Orders, timeToComplete)
+
+		return err
+	}
+
+	_, err := fmt.Fprintf(c.out, "OVERALL  trades: %d, completed: %d, orders: %d, avg time to complete: %s, slippage %%: %s, gross: %s, fees: %s, net: %s %s\n",
+		o.Trades, o.Completed, o.Orders, timeToComplete, o.SlippagePercent.StringFixed(2), o.Gross.Round(8), o.Fees.Round(8), o.Net.Round(8), o.QuoteCurrency)
+
+	return err
+}
+
+// printExecutionCSV writes trades, totals and the overall total as rows of a single table, total rows have no trade
+// id and the overall row has no symbol, sizes or prices. Gross and net of the overall row are negative when buys
+// paid more than sells received.
+func (c cli) printExecutionCSV(res *report.Report) error {
+	w := csv.NewWriter(c.out)
+
+	records := [][]string{{
+		"row", "trade_id", "symbol", "side", "status", "created_at", "completed_at", "trades", "orders", "size", "filled", "remaining",
+		"order_price", "reference_price", "average_price", "slippage_percent", "time_to_complete_seconds", "gross", "fees", "net",
+	}}
+
+	for _, t := range res.Trades {
+		completedAt, timeToComplete := "", ""
+		if t.CompletedAt != nil {
+			completedAt = t.CompletedAt.Format(time.RFC3339)
+			timeToComplete = seconds(t.TimeToComplete)
+		}
+
+		records = append(records, []string{
+			"trade", t.TradeId.String(), t.Symbol, string(t.Side), string(t.Status), t.CreatedAt.Format(time.RFC3339), completedAt, "1",
+			strconv.Itoa(t.Orders), t.Size.String(), t.Filled.String(), t.Remaining.String(), t.OrderPrice.String(), t.ReferencePrice.Round(8).String(), t.AveragePrice.Round(8).String(),
+			t.SlippagePercent.Round(8).String(), timeToComplete, t.Proceeds.Gross.Round(8).String(), t.Proceeds.Fees.Round(8).String(), t.Proceeds.Net.Round(8).String(),
+		})
+	}
+
+	for _, t := range res.Totals {
+		timeToComplete := ""
+		if t.Completed > 0 {
+			timeToComplete = seconds(t.AverageTimeToComplete)
+		}
+
+		records = append(records, []string{
+			"total", "", t.Symbol, string(t.Side), "", "", "", strconv.Itoa(t.Trades),
+			strconv.Itoa(t.Orders), t.Size.String(), t.Filled.String(), t.Remaining.String(), "", "", t.AveragePrice.Round(8).String(),
+			t.SlippagePercent.Round(8).String(), timeToComplete, t.Proceeds.Gross.Round(8).String(), t.Proceeds.Fees.Round(8).String(), t.Proceeds.Net.Round(8).String(),
+		})
+	}
+
+	o := res.Overall
+
+	overall := []string{"overall", "", "", "", "", "", "", strconv.Itoa(o.Trades), strconv.Itoa(o.Orders), "", "", "", "", "", "", "", "", "", "", ""}
+	if o.Completed > 0 {
+		overall[16] = seconds(o.AverageTimeToComplete)
+	}
+
+	if o.QuoteCurrency != "" {
+		overall[15] = o.SlippagePercent.Round(8).String()
+		overall[17] = o.Gross.Round(8).String()
+		overall[18] = o.Fees.Round(8).String()
+		overall[19] = o.Net.Round(8).String()
+	}
+
+	records = append(records, overall)
+
+	return w.WriteAll(records)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/internal/api"
+	"github.com/beng90/trader/internal/config"
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/metrics"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/symbol"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+)
+
+// run watches tickers and trades active trades until SIGINT or SIGTERM. Trading which is in progress then is
+// finished, it is canceled only when it does not finish within the shutdown timeout.
+func (c cli) run() error {
+	fmt.Println("Start trader...")
+
+	// ctx stops new ticks, workCtx is given to trading, reconciling and expiring so an order is not interrupted
+	// between the exchange and the database when the signal comes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
+	allocationPolicy, err := trade.ParseAllocationPolicy(cfg.AllocationPolicy)
+	if err != nil {
+		return err
+	}
+
+	guard := trade.Guard{MaxSpreadPercent: cfg.Guard.MaxSpreadPercent, MaxDeviationPercent: cfg.Guard.MaxDeviationPercent}
+	fees := exchange.FeeSchedule{MakerPercent: cfg.Fees.MakerPercent, TakerPercent: cfg.Fees.TakerPercent}
+
+	client := binance.NewClient(cfg.Binance.ApiKey, cfg.Binance.ApiSecret)
+
+	binanceExchange := exchange.NewBinance(client, c.logger)
+
+	// tickers are always live, orders go to the exchange the mode trades on
+	var orderExchange exchange.Exchange = binanceExchange
+	if cfg.Mode == config.ModePaper {
+		fmt.Println("Paper trading, orders are not sent to Binance")
+
+		orderExchange = exchange.NewPaper(binanceExchange, c.logger, fees)
+	}
+
+	tradeRepository := trade.NewRepository(c.db, c.logger)
+	orderRepository := order.NewRepository(c.db, c.logger)
+	m := metrics.NewMetrics(c.logger, tradeRepository)
+	unitOfWork := m.UnitOfWork(trade.NewUnitOfWork(c.db, c.logger))
+	symbolRepository := symbol.NewRepository(orderExchange, c.logger, time.Millisecond*time.Duration(cfg.SymbolCacheTtl))
+	orderCreator := trade.NewOrderCreator(c.logger, tradeRepository, orderRepository, symbolRepository, orderExchange, unitOfWork, time.Now)
+	reconciler := trade.NewReconciler(c.logger, orderRepository, orderExchange, unitOfWork)
+	expirer := trade.NewExpirer(c.logger, tradeRepository, orderRepository, orderExchange, unitOfWork, time.Now)
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		reconciler.Run(ctx, workCtx, time.Millisecond*time.Duration(cfg.ReconcileFrequency))
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		expirer.Run(ctx, workCtx, time.Millisecond*time.Duration(cfg.ExpireFrequency))
+	}()
+
+	var server *http.Server
+
+	if cfg.Http.Addr != "" {
+		manager := trade.NewManager(c.logger, tradeRepository, orderRepository)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		mux.Handle("/", api.NewServer(c.logger, tradeRepository, orderRepository, manager).Handler())
+
+		server = &http.Server{
+			Addr:              cfg.Http.Addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				c.logger.Error(err)
+			}
+		}()
+	}
+
+	l := loop{
+		logger:    c.logger,
+		metrics:   m,
+		frequency: time.Millisecond * time.Duration(cfg.Frequency),
+	}
+
+	if cfg.TickerSource == config.TickerSourceStream {
+		stream := orderbookticker.NewStream(cfg.Binance.StreamUrl, c.logger)
+		l.trader = trade.NewTrader(c.logger, stream, tradeRepository, orderCreator, allocationPolicy, guard, time.Now)
+		// periodic watch picks up new trades and subscribes their symbols, updates trade on fresh tickers
+		l.updates = stream.Updates()
+
+		go func() {
+			if err := stream.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				c.logger.Error(err)
+			}
+		}()
+	} else {
+		orderBookTickerRepository := m.OrderBookTickerRepository(orderbookticker.NewRepository(binanceExchange, c.logger))
+		l.trader = trade.NewTrader(c.logger, orderBookTickerRepository, tradeRepository, orderCreator, allocationPolicy, guard, time.Now)
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		l.run(ctx, workCtx)
+	}()
+
+	<-ctx.Done()
+
+	timeout := time.Millisecond * time.Duration(cfg.ShutdownTimeout)
+	c.logger.Debugf("Shutting down, waiting up to %s for trading in progress\n", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if server != nil {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			c.logger.Error(err)
+		}
+	}
+
+	return wait(shutdownCtx, &wg, cancelWork)
+}
+
+// TraderInterface is the part of trade.Trader the loop calls
+type TraderInterface interface {
+	Watch(ctx context.Context) error
+	WatchSymbol(ctx context.Context, symbol string) error
+}
+
+// loop watches trades on every tick and on every symbol update until ctx is done
+type loop struct {
+	logger    logus.Logger
+	metrics   *metrics.Metrics
+	trader    TraderInterface
+	frequency time.Duration
+	// updates are symbols with a fresh ticker, nil when tickers are polled
+	updates <-chan string
+}
+
+func (l loop) run(ctx context.Context, workCtx context.Context) {
+	ticker := time.NewTicker(l.frequency)
+	defer ticker.Stop()
+
+	for {
+		var watch func() error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			watch = func() error { return l.trader.Watch(workCtx) }
+		case symbol := <-l.updates:
+			watch = func() error { return l.trader.WatchSymbol(workCtx, symbol) }
+		}
+
+		// a tick may be ready together with the signal and select picks either of them, no watch starts after it
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := l.metrics.Watch(watch)
+		if errors.Is(err, trade.ErrNothingToTrade) {
+			l.logger.Debug(err)
+		} else if err != nil {
+			l.logger.Error(err)
+		}
+	}
+}
+
+// wait waits for wg until ctx is done, then work still in progress is canceled and waited for again
+func wait(ctx context.Context, wg *sync.WaitGroup, cancelWork context.CancelFunc) error {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	cancelWork()
+	<-done
+
+	return errors.New("trading in progress did not finish within the shutdown timeout, it was canceled")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/metrics"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/stretchr/testify/assert"
+)
+
+// traderStub blocks every watch until release is closed and records the context error of the first one
+type traderStub struct {
+	started chan struct{}
+	release chan struct{}
+	symbols chan string
+	ctxErr  chan error
+}
+
+func (s traderStub) Watch(ctx context.Context) error {
+	send(s.started, struct{}{})
+
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+	}
+
+	send(s.ctxErr, ctx.Err())
+
+	return trade.ErrNothingToTrade
+}
+
+func (s traderStub) WatchSymbol(ctx context.Context, symbol string) error {
+	send(s.symbols, symbol)
+
+	return nil
+}
+
+// send drops v when nobody waits for it and the buffer is full
+func send[T any](ch chan T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+func newTraderStub() traderStub {
+	return traderStub{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+		symbols: make(chan string, 1),
+		ctxErr:  make(chan error, 1),
+	}
+}
+
+func runLoop(trader traderStub, updates <-chan string) (cancel context.CancelFunc, cancelWork context.CancelFunc, wg *sync.WaitGroup) {
+	ctx, cancel := context.WithCancel(context.Background())
+	workCtx, cancelWork := context.WithCancel(context.Background())
+
+	l := loop{
+		logger:    testLogger,
+		metrics:   metrics.NewMetrics(testLogger, nil),
+		trader:    trader,
+		frequency: time.Millisecond,
+		updates:   updates,
+	}
+
+	wg = &sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		l.run(ctx, workCtx)
+	}()
+
+	return cancel, cancelWork, wg
+}
+
+func TestLoop_FinishesWatchInProgress(t *testing.T) {
+	trader := newTraderStub()
+	updates := make(chan string)
+	cancel, cancelWork, wg := runLoop(trader, updates)
+
+	updates <- "BNBUSDT"
+	assert.Equal(t, "BNBUSDT", <-trader.symbols)
+
+	<-trader.started
+	cancel()
+
+	// watch in progress is not interrupted by the signal
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+	defer cancelShutdown()
+
+	close(trader.release)
+	assert.NoError(t, wait(shutdownCtx, wg, cancelWork))
+	assert.NoError(t, <-trader.ctxErr)
+}
+
+func TestLoop_CancelsWatchAfterTimeout(t *testing.T) {
+	trader := newTraderStub()
+	cancel, cancelWork, wg := runLoop(trader, nil)
+
+	<-trader.started
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShutdown()
+
+	assert.Error(t, wait(shutdownCtx, wg, cancelWork))
+	assert.True(t, errors.Is(<-trader.ctxErr, context.Canceled))
+}
+
+func TestLoop_NoWatchAfterSignal(t *testing.T) {
+	trader := newTraderStub()
+	updates := make(chan string, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l := loop{
+		logger:    testLogger,
+		metrics:   metrics.NewMetrics(testLogger, nil),
+		trader:    trader,
+		frequency: time.Millisecond,
+		updates:   updates,
+	}
+
+	// the update is ready together with the signal, select would pick it in about half of the runs
+	for i := 0; i < 100; i++ {
+		send(updates, "BNBUSDT")
+		l.run(ctx, context.Background())
+	}
+
+	assert.Empty(t, trader.symbols)
+}
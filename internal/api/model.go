@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// amounts are sent as strings, JSON numbers would be read as floats by most clients
+
+type addTradeRequest struct {
+	OrderSize          decimal.Decimal `json:"orderSize"`
+	OrderSizeCurrency  string          `json:"orderSizeCurrency"`
+	OrderPrice         decimal.Decimal `json:"orderPrice"`
+	OrderPriceCurrency string          `json:"orderPriceCurrency"`
+	StopPrice          decimal.Decimal `json:"stopPrice"`
+	StopLimitOffset    decimal.Decimal `json:"stopLimitOffset"`
+	// TrailingDistance or TrailingPercent make a trailing stop
+	TrailingDistance        decimal.Decimal `json:"trailingDistance"`
+	TrailingPercent         decimal.Decimal `json:"trailingPercent"`
+	TrailingActivationPrice decimal.Decimal `json:"trailingActivationPrice"`
+	// Levels make a take-profit ladder instead of OrderPrice
+	Levels []levelRequest `json:"levels"`
+	// Style is IMMEDIATE when it is not given
+	Style         string          `json:"style"`
+	SliceSize     decimal.Decimal `json:"sliceSize"`
+	SliceInterval duration        `json:"sliceInterval"`
+	TwapDuration  duration        `json:"twapDuration"`
+	// MaxSpreadPercent and MaxDeviationPercent replace the global guard limits for the trade
+	MaxSpreadPercent    decimal.Decimal `json:"maxSpreadPercent"`
+	MaxDeviationPercent decimal.Decimal `json:"maxDeviationPercent"`
+	// StartAt and ExpiresAt are the window the trade is traded in, RFC 3339 times
+	StartAt   *time.Time `json:"startAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	// Side is SELL when it is not given
+	Side string `json:"side"`
+}
+
+// levelRequest is a ladder level given either as a size or as a fraction of the trade size
+type levelRequest struct {
+	Price    decimal.Decimal `json:"price"`
+	Size     decimal.Decimal `json:"size"`
+	Fraction decimal.Decimal `json:"fraction"`
+}
+
+// editTradeRequest changes only the given fields
+type editTradeRequest struct {
+	OrderSize               *decimal.Decimal `json:"orderSize"`
+	OrderPrice              *decimal.Decimal `json:"orderPrice"`
+	StopPrice               *decimal.Decimal `json:"stopPrice"`
+	StopLimitOffset         *decimal.Decimal `json:"stopLimitOffset"`
+	TrailingDistance        *decimal.Decimal `json:"trailingDistance"`
+	TrailingPercent         *decimal.Decimal `json:"trailingPercent"`
+	TrailingActivationPrice *decimal.Decimal `json:"trailingActivationPrice"`
+	Status                  *string          `json:"status"`
+}
+
+type tradeResponse struct {
+	ID                      uuid.UUID       `json:"id"`
+	CreatedAt               time.Time       `json:"createdAt"`
+	UpdatedAt               time.Time       `json:"updatedAt"`
+	Symbol                  string          `json:"symbol"`
+	Side                    string          `json:"side"`
+	Status                  string          `json:"status"`
+	OrderSize               decimal.Decimal `json:"orderSize"`
+	OrderSizeLeft           decimal.Decimal `json:"orderSizeLeft"`
+	OrderSizeCurrency       string          `json:"orderSizeCurrency"`
+	OrderPrice              decimal.Decimal `json:"orderPrice"`
+	OrderPriceCurrency      string          `json:"orderPriceCurrency"`
+	StopPrice               decimal.Decimal `json:"stopPrice"`
+	StopLimitOffset         decimal.Decimal `json:"stopLimitOffset"`
+	TrailingDistance        decimal.Decimal `json:"trailingDistance"`
+	TrailingPercent         decimal.Decimal `json:"trailingPercent"`
+	TrailingActivationPrice decimal.Decimal `json:"trailingActivationPrice"`
+	// TrailingPeak is the best price seen by the trailing stop, zero until trailing begins
+	TrailingPeak        decimal.Decimal `json:"trailingPeak"`
+	Levels              []levelResponse `json:"levels,omitempty"`
+	Style               string          `json:"style"`
+	SliceSize           decimal.Decimal `json:"sliceSize"`
+	SliceInterval       duration        `json:"sliceInterval"`
+	TwapDuration        duration        `json:"twapDuration"`
+	ScheduleStartedAt   *time.Time      `json:"scheduleStartedAt"`
+	LastSliceAt         *time.Time      `json:"lastSliceAt"`
+	StartAt             *time.Time      `json:"startAt"`
+	ExpiresAt           *time.Time      `json:"expiresAt"`
+	MaxSpreadPercent    decimal.Decimal `json:"maxSpreadPercent"`
+	MaxDeviationPercent decimal.Decimal `json:"maxDeviationPercent"`
+	// LastSkipKind is what the guard skips the trade for since LastSkippedAt, LastSkipReason tells the prices of
+	// the first of those skips
+	LastSkipKind   trade.SkipKind  `json:"lastSkipKind,omitempty"`
+	LastSkipReason string          `json:"lastSkipReason,omitempty"`
+	LastSkippedAt  *time.Time      `json:"lastSkippedAt,omitempty"`
+	Version        int64           `json:"version"`
+	Orders         []orderResponse `json:"orders,omitempty"`
+	// Proceeds are sent with the orders of the trade
+	Proceeds *proceedsResponse `json:"proceeds,omitempty"`
+}
+
+type levelResponse struct {
+	Price    decimal.Decimal `json:"price"`
+	Size     decimal.Decimal `json:"size"`
+	SizeLeft decimal.Decimal `json:"sizeLeft"`
+	Fraction decimal.Decimal `json:"fraction"`
+}
+
+func toTradeResponse(t trade.Trade) tradeResponse {
+	res := tradeResponse{
+		ID:                      t.ID,
+		CreatedAt:               t.CreatedAt,
+		UpdatedAt:               t.UpdatedAt,
+		Symbol:                  t.GetSymbol(),
+		Side:                    string(t.GetSide()),
+		Status:                  string(t.Status),
+		OrderSize:               t.OrderSize,
+		OrderSizeLeft:           t.OrderSizeLeft,
+		OrderSizeCurrency:       t.OrderSizeCurrency,
+		OrderPrice:              t.OrderPrice,
+		OrderPriceCurrency:      t.OrderPriceCurrency,
+		StopPrice:               t.StopPrice,
+		StopLimitOffset:         t.StopLimitOffset,
+		TrailingDistance:        t.TrailingDistance,
+		TrailingPercent:         t.TrailingPercent,
+		TrailingActivationPrice: t.TrailingActivationPrice,
+		TrailingPeak:            t.TrailingPeak,
+		Style:                   string(t.GetStyle()),
+		SliceSize:               t.SliceSize,
+		SliceInterval:           duration(t.SliceInterval),
+		TwapDuration:            duration(t.TwapDuration),
+		ScheduleStartedAt:       t.ScheduleStartedAt,
+		LastSliceAt:             t.LastSliceAt,
+		StartAt:                 t.StartAt,
+		ExpiresAt:               t.ExpiresAt,
+		MaxSpreadPercent:        t.MaxSpreadPercent,
+		MaxDeviationPercent:     t.MaxDeviationPercent,
+		LastSkipKind:            t.LastSkipKind,
+		LastSkipReason:          t.LastSkipReason,
+		LastSkippedAt:           t.LastSkippedAt,
+		Version:                 t.Version,
+	}
+
+	for _, l := range t.Levels {
+		res.Levels = append(res.Levels, levelResponse{Price: l.Price, Size: l.Size, SizeLeft: l.SizeLeft, Fraction: l.Fraction})
+	}
+
+	return res
+}
+
+// proceedsResponse is in the quote currency of the trade
+type proceedsResponse struct {
+	Gross       decimal.Decimal            `json:"gross"`
+	Fees        decimal.Decimal            `json:"fees"`
+	Net         decimal.Decimal            `json:"net"`
+	OtherFees   map[string]decimal.Decimal `json:"otherFees,omitempty"`
+	UnknownFees int                        `json:"unknownFees,omitempty"`
+}
+
+func toProceedsResponse(p trade.Proceeds) *proceedsResponse {
+	return &proceedsResponse{
+		Gross:       p.Gross,
+		Fees:        p.Fees,
+		Net:         p.Net,
+		OtherFees:   p.OtherFees,
+		UnknownFees: p.UnknownFees,
+	}
+}
+
+type orderResponse struct {
+	OrderId            string                     `json:"orderId"`
+	CreatedAt          time.Time                  `json:"createdAt"`
+	UpdatedAt          time.Time                  `json:"updatedAt"`
+	TradeId            uuid.UUID                  `json:"tradeId"`
+	ClientOrderId      string                     `json:"clientOrderId"`
+	Symbol             string                     `json:"symbol"`
+	Side               string                     `json:"side"`
+	Trigger            string                     `json:"trigger"`
+	ReferencePrice     decimal.Decimal            `json:"referencePrice"`
+	Status             string                     `json:"status"`
+	OrderSize          decimal.Decimal            `json:"orderSize"`
+	OrderPrice         decimal.Decimal            `json:"orderPrice"`
+	ExecutedSize       decimal.Decimal            `json:"executedSize"`
+	CumulativeQuoteQty decimal.Decimal            `json:"cumulativeQuoteQty"`
+	Fee                decimal.Decimal            `json:"fee"`
+	FeeAsset           string                     `json:"feeAsset"`
+	OtherFees          map[string]decimal.Decimal `json:"otherFees,omitempty"`
+	AllocationPolicy   string                     `json:"allocationPolicy"`
+	AllocatedSize      decimal.Decimal            `json:"allocatedSize"`
+	TransactedAt       time.Time                  `json:"transactedAt"`
+	ExchangeUpdatedAt  time.Time                  `json:"exchangeUpdatedAt"`
+}
+
+func toOrderResponse(o order.Order) orderResponse {
+	return orderResponse{
+		OrderId:            o.OrderId,
+		CreatedAt:          o.CreatedAt,
+		UpdatedAt:          o.UpdatedAt,
+		TradeId:            o.TradeId,
+		ClientOrderId:      o.ClientOrderId,
+		Symbol:             o.Symbol,
+		Side:               string(o.Side),
+		Trigger:            o.Trigger,
+		ReferencePrice:     o.ReferencePrice,
+		Status:             string(o.Status),
+		OrderSize:          o.OrderSize,
+		OrderPrice:         o.OrderPrice,
+		ExecutedSize:       o.ExecutedSize,
+		CumulativeQuoteQty: o.CumulativeQuoteQty,
+		Fee:                o.Fee,
+		FeeAsset:           o.FeeAsset,
+		OtherFees:          o.OtherFees,
+		AllocationPolicy:   o.AllocationPolicy,
+		AllocatedSize:      o.AllocatedSize,
+		TransactedAt:       o.TransactedAt,
+		ExchangeUpdatedAt:  o.ExchangeUpdatedAt,
+	}
+}
+
+// page is a part of a listing, Total counts all items matching the filter
+type page[T any] struct {
+	Items  []T   `json:"items"`
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// duration is sent as a Go duration string, e.g. "1h30m"
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+
+	return nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+var errNotFound = errors.New("not found")
+
+// Server exposes trades and orders over HTTP with JSON bodies
+type Server struct {
+	logger    logus.Logger
+	tradeRepo trade.RepositoryInterface
+	orderRepo order.RepositoryInterface
+	manager   trade.ManagerInterface
+}
+
+func NewServer(
+	logger logus.Logger,
+	tradeRepo trade.RepositoryInterface,
+	orderRepo order.RepositoryInterface,
+	manager trade.ManagerInterface,
+) Server {
+	return Server{
+		logger:    logger,
+		tradeRepo: tradeRepo,
+		orderRepo: orderRepo,
+		manager:   manager,
+	}
+}
+
+// Handler routes
+//
+//	GET    /trades              list trades, ?status=&symbol=&limit=&offset=
+//	POST   /trades              add a trade
+//	GET    /trades/{id}         trade with its orders
+//	PATCH  /trades/{id}         change size, price or status (ACTIVE, PAUSED, CANCELED)
+//	DELETE /trades/{id}         cancel a trade, trades are kept with their orders
+//	POST   /trades/{id}/reset   make the whole size of a trade available again
+//	GET    /orders              list orders, ?trade_id=&symbol=&status=&limit=&offset=
+func (s Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/trades", s.handleTrades)
+	mux.HandleFunc("/trades/", s.handleTrade)
+	mux.HandleFunc("/orders", s.handleOrders)
+
+	return mux
+}
+
+func (s Server) handleTrades(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTrades(w, r)
+	case http.MethodPost:
+		s.addTrade(w, r)
+	default:
+		s.writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s Server) handleTrade(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/trades/"), "/")
+
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: trade %q", errNotFound, parts[0]))
+
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.showTrade(w, id)
+	case len(parts) == 1 && r.Method == http.MethodPatch:
+		s.editTrade(w, r, id)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.writeTrade(w, http.StatusOK)(s.manager.Cancel(id))
+	case len(parts) == 1:
+		s.writeMethodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	case len(parts) == 2 && parts[1] == "reset" && r.Method == http.MethodPost:
+		s.writeTrade(w, http.StatusOK)(s.manager.Reset(id))
+	case len(parts) == 2 && parts[1] == "reset":
+		s.writeMethodNotAllowed(w, http.MethodPost)
+	default:
+		s.writeError(w, fmt.Errorf("%w: %s", errNotFound, r.URL.Path))
+	}
+}
+
+func (s Server) listTrades(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, offset, err := pagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	filter := trade.Filter{
+		Status: trade.Status(strings.ToUpper(query.Get("status"))),
+		Symbol: strings.ToUpper(query.Get("symbol")),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	trades, err := s.tradeRepo.FindAll(filter)
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	total, err := s.tradeRepo.Count(filter)
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	items := make([]tradeResponse, 0, len(trades))
+	for i := range trades {
+		items = append(items, toTradeResponse(trades[i]))
+	}
+
+	s.writeJSON(w, http.StatusOK, page[tradeResponse]{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+func (s Server) addTrade(w http.ResponseWriter, r *http.Request) {
+	var req addTradeRequest
+	if err := decode(r, &req); err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	levels := make([]trade.Level, 0, len(req.Levels))
+	for _, l := range req.Levels {
+		levels = append(levels, trade.Level{Price: l.Price, Size: l.Size, Fraction: l.Fraction})
+	}
+
+	s.writeTrade(w, http.StatusCreated)(s.manager.Add(trade.Trade{
+		OrderSize:               req.OrderSize,
+		OrderSizeCurrency:       req.OrderSizeCurrency,
+		OrderPrice:              req.OrderPrice,
+		OrderPriceCurrency:      req.OrderPriceCurrency,
+		StopPrice:               req.StopPrice,
+		StopLimitOffset:         req.StopLimitOffset,
+		TrailingDistance:        req.TrailingDistance,
+		TrailingPercent:         req.TrailingPercent,
+		TrailingActivationPrice: req.TrailingActivationPrice,
+		Levels:                  levels,
+		Style:                   trade.Style(req.Style),
+		SliceSize:               req.SliceSize,
+		SliceInterval:           time.Duration(req.SliceInterval),
+		TwapDuration:            time.Duration(req.TwapDuration),
+		StartAt:                 req.StartAt,
+		ExpiresAt:               req.ExpiresAt,
+		MaxSpreadPercent:        req.MaxSpreadPercent,
+		MaxDeviationPercent:     req.MaxDeviationPercent,
+		Side:                    exchange.Side(req.Side),
+	}))
+}
+
+func (s Server) showTrade(w http.ResponseWriter, id uuid.UUID) {
+	t, err := s.tradeRepo.FindOneById(id)
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	orders, err := s.orderRepo.FindAll(order.Filter{TradeId: id})
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	res := toTradeResponse(*t)
+	res.Proceeds = toProceedsResponse(trade.NewProceeds(*t, orders))
+	res.Orders = make([]orderResponse, 0, len(orders))
+
+	for i := range orders {
+		res.Orders = append(res.Orders, toOrderResponse(orders[i]))
+	}
+
+	s.writeJSON(w, http.StatusOK, res)
+}
+
+func (s Server) editTrade(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	var req editTradeRequest
+	if err := decode(r, &req); err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	var (
+		t   *trade.Trade
+		err error
+	)
+
+	edit := trade.Edit{
+		OrderSize:               req.OrderSize,
+		OrderPrice:              req.OrderPrice,
+		StopPrice:               req.StopPrice,
+		StopLimitOffset:         req.StopLimitOffset,
+		TrailingDistance:        req.TrailingDistance,
+		TrailingPercent:         req.TrailingPercent,
+		TrailingActivationPrice: req.TrailingActivationPrice,
+	}
+
+	if edit != (trade.Edit{}) {
+		if t, err = s.manager.Edit(id, edit); err != nil {
+			s.writeError(w, err)
+
+			return
+		}
+	}
+
+	if req.Status != nil {
+		t, err = s.changeStatus(id, trade.Status(strings.ToUpper(*req.Status)))
+	}
+
+	if t == nil && err == nil {
+		t, err = s.tradeRepo.FindOneById(id)
+	}
+
+	s.writeTrade(w, http.StatusOK)(t, err)
+}
+
+// changeStatus moves the trade to status, a trade which already has it is kept as it is
+func (s Server) changeStatus(id uuid.UUID, status trade.Status) (*trade.Trade, error) {
+	t, err := s.tradeRepo.FindOneById(id)
+	if err != nil || t.Status == status {
+		return t, err
+	}
+
+	switch status {
+	case trade.StatusActive:
+		return s.manager.Resume(id)
+	case trade.StatusPaused:
+		return s.manager.Pause(id)
+	case trade.StatusCanceled:
+		return s.manager.Cancel(id)
+	}
+
+	return nil, fmt.Errorf("%w: status has to be one of %s, %s, %s", trade.ErrInvalidTrade, trade.StatusActive, trade.StatusPaused, trade.StatusCanceled)
+}
+
+func (s Server) handleOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeMethodNotAllowed(w, http.MethodGet)
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit, offset, err := pagination(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	filter := order.Filter{
+		Symbol: strings.ToUpper(query.Get("symbol")),
+		Status: exchange.OrderStatus(strings.ToUpper(query.Get("status"))),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if tradeId := query.Get("trade_id"); tradeId != "" {
+		if filter.TradeId, err = uuid.Parse(tradeId); err != nil {
+			s.writeError(w, badRequest("invalid trade_id %q", tradeId))
+
+			return
+		}
+	}
+
+	orders, err := s.orderRepo.FindAll(filter)
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	total, err := s.orderRepo.Count(filter)
+	if err != nil {
+		s.writeError(w, err)
+
+		return
+	}
+
+	items := make([]orderResponse, 0, len(orders))
+	for i := range orders {
+		items = append(items, toOrderResponse(orders[i]))
+	}
+
+	s.writeJSON(w, http.StatusOK, page[orderResponse]{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+// writeTrade returns a writer of the manager results, so they can be passed to it directly
+func (s Server) writeTrade(w http.ResponseWriter, status int) func(t *trade.Trade, err error) {
+	return func(t *trade.Trade, err error) {
+		if err != nil {
+			s.writeError(w, err)
+
+			return
+		}
+
+		s.writeJSON(w, status, toTradeResponse(*t))
+	}
+}
+
+func (s Server) writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	s.writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+}
+
+// writeError maps errors to status codes, unexpected errors are logged and their details are not exposed
+func (s Server) writeError(w http.ResponseWriter, err error) {
+	var badRequestErr requestError
+
+	switch {
+	case errors.As(err, &badRequestErr), errors.Is(err, trade.ErrInvalidTrade):
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, errNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		s.writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, trade.ErrInvalidStatus), errors.Is(err, trade.ErrConcurrentUpdate):
+		s.writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	default:
+		s.logger.Error(err)
+		s.writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "internal error"})
+	}
+}
+
+func (s Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// requestError is an invalid request which the client has to fix
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, v ...any) error {
+	return requestError{fmt.Sprintf(format, v...)}
+}
+
+// decode reads a JSON body, unknown fields are rejected so typos do not go unnoticed
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid body: %s", err)
+	}
+
+	return nil
+}
+
+func pagination(limitParam string, offsetParam string) (limit int, offset int, err error) {
+	limit = defaultLimit
+
+	if limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, badRequest("limit has to be between 1 and %d", maxLimit)
+		}
+	}
+
+	if offsetParam != "" {
+		if offset, err = strconv.Atoi(offsetParam); err != nil || offset < 0 {
+			return 0, 0, badRequest("offset has to be 0 or more")
+		}
+	}
+
+	return limit, offset, nil
+}
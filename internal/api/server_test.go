@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/migration"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testLogger = &logus.TestLogger{}
+
+func newTestServer(t *testing.T) (*httptest.Server, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, migration.NewMigrator(db, testLogger).Migrate())
+
+	tradeRepo := trade.NewRepository(db, testLogger)
+	orderRepo := order.NewRepository(db, testLogger)
+
+	server := httptest.NewServer(NewServer(testLogger, tradeRepo, orderRepo, trade.NewManager(testLogger, tradeRepo, orderRepo)).Handler())
+	t.Cleanup(server.Close)
+
+	return server, db
+}
+
+// call sends body as JSON and decodes the response into res when it is given
+func call(t *testing.T, server *httptest.Server, method string, path string, body string, res any) int {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	if res != nil {
+		require.NoError(t, json.Unmarshal(raw, res), string(raw))
+	}
+
+	return resp.StatusCode
+}
+
+func TestServer_Trades(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	var created tradeResponse
+	status := call(t, server, http.MethodPost, "/trades", `{"orderSize": "1.5", "orderSizeCurrency": "bnb", "orderPrice": "115", "orderPriceCurrency": "usdt"}`, &created)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "BNBUSDT", created.Symbol)
+	assert.Equal(t, "SELL", created.Side)
+	assert.Equal(t, "ACTIVE", created.Status)
+	assert.Equal(t, "1.5", created.OrderSizeLeft.String())
+
+	id := created.ID.String()
+
+	var shown tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades/"+id, "", &shown))
+	assert.Equal(t, created.ID, shown.ID)
+
+	var edited tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodPatch, "/trades/"+id, `{"orderPrice": "120", "status": "paused"}`, &edited))
+	assert.Equal(t, "120", edited.OrderPrice.String())
+	assert.Equal(t, "PAUSED", edited.Status)
+
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodPatch, "/trades/"+id, `{"status": "ACTIVE"}`, &edited))
+	assert.Equal(t, "ACTIVE", edited.Status)
+
+	var reset tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodPost, "/trades/"+id+"/reset", "", &reset))
+	assert.Equal(t, "1.5", reset.OrderSizeLeft.String())
+
+	var canceled tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodDelete, "/trades/"+id, "", &canceled))
+	assert.Equal(t, "CANCELED", canceled.Status)
+
+	// canceled trades are kept
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades/"+id, "", &shown))
+	assert.Equal(t, "CANCELED", shown.Status)
+}
+
+func TestServer_Trades_Ladder(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	var created tradeResponse
+	status := call(t, server, http.MethodPost, "/trades", `{"orderSizeCurrency": "BNB", "orderPriceCurrency": "USDT", "levels": [{"price": "115", "size": "1"}, {"price": "120", "size": "2"}]}`, &created)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "3", created.OrderSize.String())
+	assert.Equal(t, "115", created.OrderPrice.String())
+	require.Len(t, created.Levels, 2)
+	assert.Equal(t, "2", created.Levels[1].SizeLeft.String())
+
+	status = call(t, server, http.MethodPatch, "/trades/"+created.ID.String(), `{"orderPrice": "120"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestServer_Trades_Style(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	var created tradeResponse
+	status := call(t, server, http.MethodPost, "/trades", `{"orderSize": "10", "orderSizeCurrency": "BNB", "orderPrice": "115", "orderPriceCurrency": "USDT", "style": "twap", "twapDuration": "1h", "sliceInterval": "5m"}`, &created)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "TWAP", created.Style)
+	assert.Equal(t, duration(time.Hour), created.TwapDuration)
+	assert.Nil(t, created.ScheduleStartedAt)
+
+	status = call(t, server, http.MethodPost, "/trades", `{"orderSize": "10", "orderSizeCurrency": "BNB", "orderPrice": "115", "orderPriceCurrency": "USDT", "style": "TWAP", "twapDuration": "hour"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestServer_Trades_Proceeds(t *testing.T) {
+	server, db := newTestServer(t)
+
+	var created tradeResponse
+	status := call(t, server, http.MethodPost, "/trades", `{"orderSize": "10", "orderSizeCurrency": "BNB", "orderPrice": "115", "orderPriceCurrency": "USDT"}`, &created)
+	require.Equal(t, http.StatusCreated, status)
+
+	require.NoError(t, order.NewRepository(db, testLogger).Create(order.Order{
+		OrderId:            "1",
+		TradeId:            created.ID,
+		Symbol:             "BNBUSDT",
+		Status:             exchange.OrderStatusFilled,
+		ExecutedSize:       decimal.RequireFromString("2"),
+		CumulativeQuoteQty: decimal.RequireFromString("240"),
+		Fee:                decimal.RequireFromString("0.24"),
+		FeeAsset:           "USDT",
+	}))
+
+	var shown tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades/"+created.ID.String(), "", &shown))
+	require.Len(t, shown.Orders, 1)
+	assert.Equal(t, "USDT", shown.Orders[0].FeeAsset)
+	require.NotNil(t, shown.Proceeds)
+	assert.Equal(t, "240", shown.Proceeds.Gross.String())
+	assert.Equal(t, "0.24", shown.Proceeds.Fees.String())
+	assert.Equal(t, "239.76", shown.Proceeds.Net.String())
+}
+
+func TestServer_Trades_Errors(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	var created tradeResponse
+	require.Equal(t, http.StatusCreated, call(t, server, http.MethodPost, "/trades", `{"orderSize": "1", "orderSizeCurrency": "BNB", "orderPrice": "115", "orderPriceCurrency": "USDT"}`, &created))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "invalid json", method: http.MethodPost, path: "/trades", body: `{"orderSize":`, want: http.StatusBadRequest},
+		{name: "unknown field", method: http.MethodPost, path: "/trades", body: `{"size": "1"}`, want: http.StatusBadRequest},
+		{name: "invalid trade", method: http.MethodPost, path: "/trades", body: `{"orderSize": "-1", "orderSizeCurrency": "BNB", "orderPrice": "115", "orderPriceCurrency": "USDT"}`, want: http.StatusBadRequest},
+		{name: "unknown status", method: http.MethodPatch, path: "/trades/" + created.ID.String(), body: `{"status": "DONE"}`, want: http.StatusBadRequest},
+		{name: "status transition", method: http.MethodPatch, path: "/trades/" + created.ID.String(), body: `{"status": "CANCELED"}`, want: http.StatusOK},
+		{name: "edit canceled trade", method: http.MethodPatch, path: "/trades/" + created.ID.String(), body: `{"orderPrice": "100"}`, want: http.StatusConflict},
+		{name: "unknown trade", method: http.MethodGet, path: "/trades/6f1d9a8e-8d4b-4a3e-9a43-36b1f0b8a2d4", want: http.StatusNotFound},
+		{name: "invalid id", method: http.MethodGet, path: "/trades/42", want: http.StatusNotFound},
+		{name: "unknown action", method: http.MethodPost, path: "/trades/" + created.ID.String() + "/sell", want: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPut, path: "/trades", want: http.StatusMethodNotAllowed},
+		{name: "invalid limit", method: http.MethodGet, path: "/trades?limit=0", want: http.StatusBadRequest},
+		{name: "invalid offset", method: http.MethodGet, path: "/orders?offset=-1", want: http.StatusBadRequest},
+		{name: "invalid trade id filter", method: http.MethodGet, path: "/orders?trade_id=42", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res errorResponse
+			assert.Equal(t, tt.want, call(t, server, tt.method, tt.path, tt.body, &res))
+
+			if tt.want != http.StatusOK {
+				assert.NotEmpty(t, res.Error)
+			}
+		})
+	}
+}
+
+func TestServer_Pagination(t *testing.T) {
+	server, db := newTestServer(t)
+
+	var created tradeResponse
+	for _, currency := range []string{"BNB", "ETH", "BTC"} {
+		require.Equal(t, http.StatusCreated, call(t, server, http.MethodPost, "/trades", `{"orderSize": "1", "orderSizeCurrency": "`+currency+`", "orderPrice": "115", "orderPriceCurrency": "USDT"}`, &created))
+	}
+
+	var trades page[tradeResponse]
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades?limit=2&offset=2", "", &trades))
+	assert.Equal(t, int64(3), trades.Total)
+	assert.Len(t, trades.Items, 1)
+
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades?symbol=ethusdt", "", &trades))
+	assert.Equal(t, int64(1), trades.Total)
+	assert.Equal(t, "ETHUSDT", trades.Items[0].Symbol)
+
+	orderRepo := order.NewRepository(db, testLogger)
+	for _, o := range []order.Order{
+		{OrderId: "1", TradeId: created.ID, Symbol: "BTCUSDT", Status: exchange.OrderStatusFilled},
+		{OrderId: "2", TradeId: created.ID, Symbol: "BTCUSDT", Status: exchange.OrderStatusExpired},
+		{OrderId: "3", TradeId: trades.Items[0].ID, Symbol: "ETHUSDT", Status: exchange.OrderStatusFilled},
+	} {
+		require.NoError(t, orderRepo.Create(o))
+	}
+
+	var orders page[orderResponse]
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/orders?trade_id="+created.ID.String(), "", &orders))
+	assert.Equal(t, int64(2), orders.Total)
+
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/orders?status=filled&limit=1", "", &orders))
+	assert.Equal(t, int64(2), orders.Total)
+	assert.Equal(t, 1, orders.Limit)
+	assert.Len(t, orders.Items, 1)
+
+	var shown tradeResponse
+	require.Equal(t, http.StatusOK, call(t, server, http.MethodGet, "/trades/"+created.ID.String(), "", &shown))
+	assert.Len(t, shown.Orders, 2)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, call(t, server, http.MethodPost, "/orders", "", nil))
+}
@@ -0,0 +1,256 @@
+package backtest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/symbol"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Backtest replays ticks through trade.Trader and trade.OrderCreator. Trades and orders are kept in an in-memory
+// database and orders are matched by exchange.Simulated. The clock of all of them is the time of the replayed tick,
+// so slices of TWAP and iceberg trades follow the recorded time.
+type Backtest struct {
+	logger           logus.Logger
+	allocationPolicy trade.AllocationPolicy
+	guard            trade.Guard
+	// fees are charged for every fill
+	fees exchange.FeeSchedule
+	// symbols are rules orders have to follow, symbols which are not given have no filters
+	symbols map[string]exchange.SymbolInfo
+}
+
+func NewBacktest(
+	logger logus.Logger,
+	allocationPolicy trade.AllocationPolicy,
+	guard trade.Guard,
+	fees exchange.FeeSchedule,
+	symbols []exchange.SymbolInfo,
+) Backtest {
+	b := Backtest{
+		logger:           logger,
+		allocationPolicy: allocationPolicy,
+		guard:            guard,
+		fees:             fees,
+		symbols:          map[string]exchange.SymbolInfo{},
+	}
+
+	for _, info := range symbols {
+		b.symbols[info.Symbol] = info
+	}
+
+	return b
+}
+
+// Run trades the whole size of trades on ticks and reports what they would have done, trades are copied and
+// never changed
+func (b Backtest) Run(ctx context.Context, trades []trade.Trade, ticks []Tick) (*Report, error) {
+	db, err := openStore()
+	if err != nil {
+		b.logger.Error(err)
+
+		return nil, err
+	}
+
+	defer closeStore(db)
+
+	var now time.Time
+
+	clock := func() time.Time { return now }
+
+	sim := exchange.NewSimulated(b.logger)
+	sim.SetClock(clock)
+	sim.SetFees(b.fees)
+
+	tradeRepo := trade.NewRepository(db, b.logger)
+
+	orderRepo := order.NewRepository(db, b.logger)
+	symbolRepo := symbol.NewRepository(sim, b.logger, time.Hour)
+	orderCreator := trade.NewOrderCreator(b.logger, tradeRepo, orderRepo, symbolRepo, sim, trade.NewUnitOfWork(db, b.logger), clock)
+
+	trader := trade.NewTrader(b.logger, orderbookticker.NewRepository(sim, b.logger), tradeRepo, orderCreator, b.allocationPolicy, b.guard, clock)
+
+	expirer := trade.NewExpirer(b.logger, tradeRepo, orderRepo, sim, trade.NewUnitOfWork(db, b.logger), clock)
+
+	traded := map[string]bool{}
+
+	for _, t := range trades {
+		t.OrderSizeLeft = t.OrderSize
+		t.TrailingPeak = decimal.Zero
+		t.ScheduleStartedAt, t.LastSliceAt = nil, nil
+		t.LastSkipKind, t.LastSkipReason, t.LastSkippedAt = "", "", nil
+		t.Status = trade.StatusActive
+		t.Version = 0
+		t.Orders = nil
+		t.Levels = append([]trade.Level{}, t.Levels...)
+
+		for i := range t.Levels {
+			t.Levels[i].ID = 0
+		}
+
+		if err := tradeRepo.Create(t); err != nil {
+			return nil, err
+		}
+
+		sim.SetSymbol(b.symbolInfo(t))
+		traded[t.GetSymbol()] = true
+	}
+
+	report := &Report{}
+
+	for _, tick := range ticks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if report.Ticks == 0 {
+			report.From = tick.Time
+		}
+
+		report.To = tick.Time
+		report.Ticks++
+
+		if !traded[tick.Symbol] {
+			continue
+		}
+
+		now = tick.Time
+		sim.SetBook(tick.Symbol, level(tick.BidPrice, tick.BidQty), level(tick.AskPrice, tick.AskQty))
+
+		if err := expirer.Expire(ctx); err != nil {
+			return nil, err
+		}
+
+		if err := trader.WatchSymbol(ctx, tick.Symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range trades {
+		tradeReport, err := b.tradeReport(tradeRepo, orderRepo, t, report.From)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Trades = append(report.Trades, *tradeReport)
+	}
+
+	return report, nil
+}
+
+func (b Backtest) symbolInfo(t trade.Trade) exchange.SymbolInfo {
+	if info, ok := b.symbols[t.GetSymbol()]; ok {
+		return info
+	}
+
+	return exchange.SymbolInfo{
+		Symbol:     t.GetSymbol(),
+		Status:     "TRADING",
+		BaseAsset:  t.OrderSizeCurrency,
+		QuoteAsset: t.OrderPriceCurrency,
+	}
+}
+
+func (b Backtest) tradeReport(tradeRepo trade.RepositoryInterface, orderRepo order.RepositoryInterface, t trade.Trade, from time.Time) (*TradeReport, error) {
+	stored, err := tradeRepo.FindOneById(t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := orderRepo.FindAll(order.Filter{TradeId: t.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	// orders are stored with the wall clock, the simulated one is kept in TransactedAt
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].TransactedAt.Before(orders[j].TransactedAt) })
+
+	r := &TradeReport{
+		TradeId: t.ID,
+		Symbol:  t.GetSymbol(),
+		Side:    t.GetSide(),
+		Status:  stored.Status,
+		Size:    t.OrderSize,
+		Price:   t.OrderPrice,
+	}
+
+	quote := decimal.Zero
+
+	for _, o := range orders {
+		if !o.ExecutedSize.IsPositive() {
+			continue
+		}
+
+		r.Filled = r.Filled.Add(o.ExecutedSize)
+		quote = quote.Add(o.CumulativeQuoteQty)
+
+		r.Fills = append(r.Fills, Fill{
+			OrderId:  o.OrderId,
+			Time:     o.TransactedAt,
+			Quantity: o.ExecutedSize,
+			Price:    o.CumulativeQuoteQty.Div(o.ExecutedSize),
+		})
+	}
+
+	r.Unfilled = r.Size.Sub(r.Filled)
+	r.Proceeds = trade.NewProceeds(t, orders)
+
+	if r.Filled.IsPositive() {
+		r.AveragePrice = quote.Div(r.Filled)
+	}
+
+	if !r.Unfilled.IsPositive() && len(r.Fills) > 0 {
+		r.Completed = true
+		r.TimeToComplete = r.Fills[len(r.Fills)-1].Time.Sub(from)
+	}
+
+	return r, nil
+}
+
+// level is a book side of a single price level, the side is empty when nothing is offered
+func level(price decimal.Decimal, qty decimal.Decimal) []exchange.PriceLevel {
+	if !price.IsPositive() || !qty.IsPositive() {
+		return nil
+	}
+
+	return []exchange.PriceLevel{{Price: price, Quantity: qty}}
+}
+
+// openStore opens a migrated in-memory database, single connection keeps all queries on the same memory db
+func openStore() (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&trade.Trade{}, &trade.Level{}, &order.Order{}); err != nil {
+		_ = sqlDB.Close()
+
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func closeStore(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package backtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = &logus.TestLogger{}
+
+func dec(v string) decimal.Decimal {
+	return decimal.RequireFromString(v)
+}
+
+func TestBacktest_Run(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sell := trade.Trade{ID: uuid.New(), OrderSize: dec("50"), OrderSizeLeft: dec("5"), OrderSizeCurrency: "BNB", OrderPrice: dec("111"), OrderPriceCurrency: "USDT"}
+	buy := trade.Trade{ID: uuid.New(), OrderSize: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT", Side: exchange.SideBuy}
+
+	ticks := []Tick{
+		{Time: start, Symbol: "BNBUSDT", BidPrice: dec("110"), BidQty: dec("100"), AskPrice: dec("111"), AskQty: dec("100")},
+		{Time: start.Add(time.Second), Symbol: "BNBUSDT", BidPrice: dec("115"), BidQty: dec("20"), AskPrice: dec("116"), AskQty: dec("100")},
+		{Time: start.Add(2 * time.Second), Symbol: "ETHUSDT", BidPrice: dec("2000"), BidQty: dec("1")},
+		{Time: start.Add(3 * time.Second), Symbol: "BNBUSDT", BidPrice: dec("112"), BidQty: dec("40"), AskPrice: dec("99"), AskQty: dec("4")},
+		{Time: start.Add(4 * time.Second), Symbol: "BNBUSDT", BidPrice: dec("120"), BidQty: dec("40")},
+	}
+
+	fees := exchange.FeeSchedule{MakerPercent: dec("0.1"), TakerPercent: dec("0.1")}
+
+	report, err := NewBacktest(testLogger, trade.AllocationFIFO, trade.Guard{}, fees, nil).Run(context.Background(), []trade.Trade{sell, buy}, ticks)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, report.Ticks)
+	assert.Equal(t, start, report.From)
+	assert.Equal(t, start.Add(4*time.Second), report.To)
+	require.Len(t, report.Trades, 2)
+
+	// the whole size is traded regardless of the size left
+	s := report.Trades[0]
+	assert.Equal(t, sell.ID, s.TradeId)
+	assert.True(t, s.Completed)
+	assert.Equal(t, 3*time.Second, s.TimeToComplete)
+	assert.Equal(t, "50", s.Filled.String())
+	assert.Equal(t, "0", s.Unfilled.String())
+	assert.Equal(t, "113.2", s.AveragePrice.String())
+	require.Len(t, s.Fills, 2)
+	assert.Equal(t, start.Add(time.Second), s.Fills[0].Time)
+	assert.Equal(t, "20", s.Fills[0].Quantity.String())
+	assert.Equal(t, "115", s.Fills[0].Price.String())
+	assert.Equal(t, "30", s.Fills[1].Quantity.String())
+	assert.Equal(t, "112", s.Fills[1].Price.String())
+	assert.Equal(t, "5660", s.Proceeds.Gross.String())
+	assert.Equal(t, "5.66", s.Proceeds.Fees.String())
+	assert.Equal(t, "5654.34", s.Proceeds.Net.String())
+
+	b := report.Trades[1]
+	assert.False(t, b.Completed)
+	assert.Equal(t, trade.StatusActive, b.Status)
+	assert.Equal(t, "4", b.Filled.String())
+	assert.Equal(t, "6", b.Unfilled.String())
+	assert.Equal(t, "99", b.AveragePrice.String())
+	assert.Equal(t, time.Duration(0), b.TimeToComplete)
+}
+
+func TestBacktest_Run_SymbolFilters(t *testing.T) {
+	info := exchange.SymbolInfo{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT", StepSize: dec("1"), MinQty: dec("2")}
+	sell := trade.Trade{ID: uuid.New(), OrderSize: dec("5.5"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+
+	ticks := []Tick{
+		{Time: time.Unix(0, 0), Symbol: "BNBUSDT", BidPrice: dec("100"), BidQty: dec("5")},
+		{Time: time.Unix(1, 0), Symbol: "BNBUSDT", BidPrice: dec("100"), BidQty: dec("5")},
+	}
+
+	report, err := NewBacktest(testLogger, trade.AllocationFIFO, trade.Guard{}, exchange.FeeSchedule{}, []exchange.SymbolInfo{info}).Run(context.Background(), []trade.Trade{sell}, ticks)
+	require.NoError(t, err)
+	require.Len(t, report.Trades, 1)
+
+	// rest of 0.5 is below the minimum of 2
+	assert.Equal(t, trade.StatusDust, report.Trades[0].Status)
+	assert.Equal(t, "5", report.Trades[0].Filled.String())
+	assert.Equal(t, "0.5", report.Trades[0].Unfilled.String())
+}
+
+func TestBacktest_Run_Expiry(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(2 * time.Second)
+
+	sell := trade.Trade{ID: uuid.New(), OrderSize: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT", ExpiresAt: &expiresAt}
+
+	ticks := []Tick{
+		{Time: start, Symbol: "BNBUSDT", BidPrice: dec("100"), BidQty: dec("4")},
+		{Time: start.Add(time.Second), Symbol: "BNBUSDT", BidPrice: dec("99"), BidQty: dec("100")},
+		{Time: start.Add(2 * time.Second), Symbol: "BNBUSDT", BidPrice: dec("100"), BidQty: dec("100")},
+	}
+
+	report, err := NewBacktest(testLogger, trade.AllocationFIFO, trade.Guard{}, exchange.FeeSchedule{}, nil).Run(context.Background(), []trade.Trade{sell}, ticks)
+	require.NoError(t, err)
+	require.Len(t, report.Trades, 1)
+
+	// the book came back after the trade expired
+	r := report.Trades[0]
+	assert.Equal(t, trade.StatusExpired, r.Status)
+	assert.Equal(t, "4", r.Filled.String())
+	assert.Equal(t, "6", r.Unfilled.String())
+}
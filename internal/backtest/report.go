@@ -0,0 +1,45 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Report is the outcome of a backtest, From and To are times of the first and the last replayed tick
+type Report struct {
+	From   time.Time
+	To     time.Time
+	Ticks  int
+	Trades []TradeReport
+}
+
+type TradeReport struct {
+	TradeId uuid.UUID
+	Symbol  string
+	Side    exchange.Side
+	// Status is ACTIVE when the trade was not finished, DUST when its rest was below the symbol minimum
+	Status       trade.Status
+	Size         decimal.Decimal
+	Price        decimal.Decimal
+	Filled       decimal.Decimal
+	Unfilled     decimal.Decimal
+	AveragePrice decimal.Decimal
+	Fills        []Fill
+	// Proceeds are charged by the fee schedule of the backtest
+	Proceeds  trade.Proceeds
+	Completed bool
+	// TimeToComplete is measured from the first replayed tick to the fill which completed the trade
+	TimeToComplete time.Duration
+}
+
+// Fill is an order which was at least partially executed
+type Fill struct {
+	OrderId  string
+	Time     time.Time
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+}
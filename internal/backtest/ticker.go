@@ -0,0 +1,248 @@
+package backtest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Tick is a recorded top of the book of a symbol
+type Tick struct {
+	Time     time.Time
+	Symbol   string
+	BidPrice decimal.Decimal
+	BidQty   decimal.Decimal
+	AskPrice decimal.Decimal
+	AskQty   decimal.Decimal
+}
+
+type Format string
+
+const (
+	// FormatCSV has a header row naming the columns, see ReadTicks
+	FormatCSV Format = "csv"
+	// FormatJSONL has a JSON object on every line with time, symbol, bidPrice, bidQty, askPrice and askQty
+	FormatJSONL Format = "jsonl"
+)
+
+// timeColumns are CSV columns the tick time is read from, in the order of preference
+var timeColumns = []string{"time", "timestamp", "transactiontime", "eventtime"}
+
+// ReadTicksFile reads ticks from a .csv or .jsonl file, symbol is used for ticks which do not name one
+func ReadTicksFile(path string, symbol string) ([]Tick, error) {
+	format := FormatCSV
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+	case ".jsonl", ".json":
+		format = FormatJSONL
+	default:
+		return nil, fmt.Errorf("unknown format of %s, .csv or .jsonl is expected", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ticks, err := ReadTicks(f, format, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return ticks, nil
+}
+
+// ReadTicks reads ticks sorted by time. CSV columns are matched by name regardless of case, underscores and a best
+// prefix, so both bidPrice and Binance best_bid_price work. Time is either RFC 3339 or Unix milliseconds, the symbol
+// column may be left out when symbol is given.
+func ReadTicks(r io.Reader, format Format, symbol string) ([]Tick, error) {
+	var (
+		ticks []Tick
+		err   error
+	)
+
+	switch format {
+	case FormatCSV:
+		ticks, err = readCSV(r)
+	case FormatJSONL:
+		ticks, err = readJSONL(r)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ticks {
+		if ticks[i].Symbol == "" {
+			ticks[i].Symbol = symbol
+		}
+
+		if ticks[i].Symbol == "" {
+			return nil, fmt.Errorf("tick %d: symbol is missing", i+1)
+		}
+
+		ticks[i].Symbol = strings.ToUpper(ticks[i].Symbol)
+	}
+
+	// files joined from several recordings are not ordered
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+
+	return ticks, nil
+}
+
+func readCSV(r io.Reader) ([]Tick, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[columnName(name)] = i
+	}
+
+	timeColumn := -1
+
+	for _, name := range timeColumns {
+		if i, ok := columns[name]; ok {
+			timeColumn = i
+
+			break
+		}
+	}
+
+	if timeColumn < 0 {
+		return nil, errors.New("time column is missing")
+	}
+
+	for _, name := range []string{"bidprice", "bidqty", "askprice", "askqty"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s column is missing", name)
+		}
+	}
+
+	var ticks []Tick
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return ticks, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		tick := Tick{}
+
+		if i, ok := columns["symbol"]; ok {
+			tick.Symbol = record[i]
+		}
+
+		if tick.Time, err = parseTime(record[timeColumn]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		for name, v := range map[string]*decimal.Decimal{
+			"bidprice": &tick.BidPrice,
+			"bidqty":   &tick.BidQty,
+			"askprice": &tick.AskPrice,
+			"askqty":   &tick.AskQty,
+		} {
+			if *v, err = decimal.NewFromString(record[columns[name]]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line, name, record[columns[name]])
+			}
+		}
+
+		ticks = append(ticks, tick)
+	}
+}
+
+// columnName normalizes a CSV column name, best_bid_price and bidPrice are both bidprice
+func columnName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer("_", "", "-", "", " ", "").Replace(name)
+
+	return strings.TrimPrefix(name, "best")
+}
+
+type jsonTick struct {
+	Time     json.RawMessage `json:"time"`
+	Symbol   string          `json:"symbol"`
+	BidPrice decimal.Decimal `json:"bidPrice"`
+	BidQty   decimal.Decimal `json:"bidQty"`
+	AskPrice decimal.Decimal `json:"askPrice"`
+	AskQty   decimal.Decimal `json:"askQty"`
+}
+
+func readJSONL(r io.Reader) ([]Tick, error) {
+	var ticks []Tick
+
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var t jsonTick
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		tickTime, err := parseTime(strings.Trim(string(t.Time), `"`))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		ticks = append(ticks, Tick{
+			Time:     tickTime,
+			Symbol:   t.Symbol,
+			BidPrice: t.BidPrice,
+			BidQty:   t.BidQty,
+			AskPrice: t.AskPrice,
+			AskQty:   t.AskQty,
+		})
+	}
+
+	return ticks, scanner.Err()
+}
+
+// parseTime reads RFC 3339 time or Unix milliseconds as Binance records them
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, errors.New("time is missing")
+	}
+
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", v)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTicks_CSV(t *testing.T) {
+	// columns of Binance historical book ticker data, the file is per symbol
+	data := `update_id,best_bid_price,best_bid_qty,best_ask_price,best_ask_qty,transaction_time,event_time
+2,115.5,3,115.6,4,1700000001000,1700000001005
+1,115.4,1.5,115.5,2,1700000000000,1700000000005
+`
+
+	ticks, err := ReadTicks(strings.NewReader(data), FormatCSV, "bnbusdt")
+	require.NoError(t, err)
+	require.Len(t, ticks, 2)
+
+	assert.Equal(t, time.UnixMilli(1700000000000).UTC(), ticks[0].Time)
+	assert.Equal(t, "BNBUSDT", ticks[0].Symbol)
+	assert.Equal(t, "115.4", ticks[0].BidPrice.String())
+	assert.Equal(t, "1.5", ticks[0].BidQty.String())
+	assert.Equal(t, "115.5", ticks[0].AskPrice.String())
+	assert.Equal(t, "2", ticks[0].AskQty.String())
+	assert.Equal(t, "115.5", ticks[1].BidPrice.String())
+
+	_, err = ReadTicks(strings.NewReader(data), FormatCSV, "")
+	assert.Error(t, err)
+
+	_, err = ReadTicks(strings.NewReader("time,symbol,bidPrice,bidQty,askPrice\n"), FormatCSV, "")
+	assert.ErrorContains(t, err, "askqty column is missing")
+
+	_, err = ReadTicks(strings.NewReader("time,symbol,bidPrice,bidQty,askPrice,askQty\n2023-11-14T22:13:20Z,BNBUSDT,abc,1,2,3\n"), FormatCSV, "")
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestReadTicks_JSONL(t *testing.T) {
+	data := `{"time":"2023-11-14T22:13:21Z","symbol":"BNBUSDT","bidPrice":"115.5","bidQty":"3","askPrice":"115.6","askQty":"4"}
+
+{"time":1700000000000,"symbol":"ETHUSDT","bidPrice":2000,"bidQty":1,"askPrice":2001,"askQty":2}
+`
+
+	ticks, err := ReadTicks(strings.NewReader(data), FormatJSONL, "")
+	require.NoError(t, err)
+	require.Len(t, ticks, 2)
+
+	assert.Equal(t, "ETHUSDT", ticks[0].Symbol)
+	assert.Equal(t, "2000", ticks[0].BidPrice.String())
+	assert.Equal(t, "BNBUSDT", ticks[1].Symbol)
+	assert.Equal(t, time.Date(2023, 11, 14, 22, 13, 21, 0, time.UTC), ticks[1].Time)
+
+	_, err = ReadTicks(strings.NewReader(`{"symbol":"BNBUSDT"}`), FormatJSONL, "")
+	assert.ErrorContains(t, err, "time is missing")
+}
@@ -1,17 +1,77 @@
 package config
 
+import "github.com/shopspring/decimal"
+
+const (
+	TickerSourceRest   = "rest"
+	TickerSourceStream = "stream"
+)
+
+const (
+	ModeLive  = "live"
+	ModePaper = "paper"
+)
+
 type Config struct {
 	Db        Db
 	LogLevel  string
 	Binance   Binance
 	Frequency int
+	// Mode is "live" to send orders to Binance or "paper" to match them by a simulated exchange against live tickers
+	Mode string `default:"live"`
+	// Paper is used instead of Db in paper mode, paper trades and orders never mix with live ones
+	Paper Paper
+	// TickerSource is either "rest" to poll tickers every Frequency or "stream" to trade on every ticker update
+	TickerSource string `default:"rest"`
+	// ReconcileFrequency is how often in milliseconds open orders are refreshed from the exchange
+	ReconcileFrequency int `default:"5000"`
+	// ExpireFrequency is how often in milliseconds trades past their ExpiresAt are expired
+	ExpireFrequency int `default:"5000"`
+	// AllocationPolicy shares ticker quantity by trades on the same symbol, one of "fifo", "pro_rata", "best_price"
+	AllocationPolicy string `default:"fifo"`
+	// Guard limits the book trades order on, trades may set their own limits
+	Guard Guard
+	// Fees are charged for fills of paper trades and backtests, live fees come from the exchange
+	Fees Fees
+	// SymbolCacheTtl is how long in milliseconds symbol filters read from the exchange are kept
+	SymbolCacheTtl int `default:"3600000"`
+	// ShutdownTimeout is how long in milliseconds trading in progress may take after SIGINT or SIGTERM
+	ShutdownTimeout int `default:"10000"`
+	// Http is the REST API started with the watch loop
+	Http Http
+}
+
+// Guard limits are in percent, zero limits are not checked
+type Guard struct {
+	// MaxSpreadPercent is the widest bid/ask spread trades order on
+	MaxSpreadPercent decimal.Decimal
+	// MaxDeviationPercent is how much worse than the stop or take-profit price a trade may order
+	MaxDeviationPercent decimal.Decimal
+}
+
+// Fees are in percent of a fill, Binance spot fees by default
+type Fees struct {
+	MakerPercent decimal.Decimal `default:"0.1"`
+	TakerPercent decimal.Decimal `default:"0.1"`
 }
 
 type Db struct {
 	Path string
 }
 
+type Paper struct {
+	// Db Path defaults to Db Path with a -paper suffix
+	Db Db
+}
+
+type Http struct {
+	// Addr is the address the REST API listens on, empty Addr disables it. The API is not authenticated and changes
+	// live trades, it listens on the loopback interface unless another address is configured.
+	Addr string `default:"127.0.0.1:8080"`
+}
+
 type Binance struct {
 	ApiKey    string
 	ApiSecret string
+	StreamUrl string `default:"wss://stream.binance.com:9443"`
 }
@@ -0,0 +1,470 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+)
+
+type Binance struct {
+	client *binance.Client
+	logger logus.Logger
+}
+
+func NewBinance(
+	client *binance.Client,
+	logger logus.Logger,
+) Binance {
+	return Binance{client, logger}
+}
+
+func (e Binance) BookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	results, err := e.client.NewListBookTickersService().
+		Symbol(symbol).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrSymbolNotFound
+	}
+
+	return e.toBookTicker(results[0])
+}
+
+// BookTickers lists tickers of all symbols and keeps the requested ones, the client cannot ask for a set of symbols
+func (e Binance) BookTickers(ctx context.Context, symbols ...string) ([]BookTicker, error) {
+	results, err := e.client.NewListBookTickersService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, symbol := range symbols {
+		wanted[symbol] = true
+	}
+
+	tickers := make([]BookTicker, 0, len(results))
+
+	for _, result := range results {
+		if len(wanted) > 0 && !wanted[result.Symbol] {
+			continue
+		}
+
+		ticker, err := e.toBookTicker(result)
+		if err != nil {
+			return nil, err
+		}
+
+		tickers = append(tickers, *ticker)
+	}
+
+	return tickers, nil
+}
+
+func (e Binance) Depth(ctx context.Context, symbol string, limit int) (*Depth, error) {
+	res, err := e.client.NewDepthService().
+		Symbol(symbol).
+		Limit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bids, err := e.toPriceLevels(res.Bids)
+	if err != nil {
+		return nil, err
+	}
+
+	asks, err := e.toPriceLevels(res.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Depth{
+		Symbol: symbol,
+		Bids:   bids,
+		Asks:   asks,
+	}, nil
+}
+
+// PlaceOrder sends a limit order, IOC is used unless the request asks for another time in force
+func (e Binance) PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error) {
+	timeInForce := req.TimeInForce
+	if timeInForce == "" {
+		timeInForce = TimeInForceIOC
+	}
+
+	res, err := e.client.NewCreateOrderService().
+		Symbol(req.Symbol).
+		Side(binance.SideType(req.Side)).
+		Type(binance.OrderTypeLimit).
+		TimeInForce(binance.TimeInForceType(timeInForce)).
+		Quantity(req.Quantity.String()).
+		Price(req.Price.String()).
+		NewClientOrderID(req.ClientOrderId).
+		NewOrderRespType(binance.NewOrderRespTypeFULL).
+		Do(ctx)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	placed, err := e.toOrderResponse(binance.Order{
+		Symbol:                   res.Symbol,
+		OrderID:                  res.OrderID,
+		ClientOrderID:            res.ClientOrderID,
+		Price:                    res.Price,
+		OrigQuantity:             res.OrigQuantity,
+		ExecutedQuantity:         res.ExecutedQuantity,
+		CummulativeQuoteQuantity: res.CummulativeQuoteQuantity,
+		Status:                   res.Status,
+		Side:                     res.Side,
+	}, res.TransactTime, res)
+	if err != nil {
+		return nil, err
+	}
+
+	// the full response lists the fills of the order with their fees
+	for _, f := range res.Fills {
+		if err := e.addCommission(placed, f.Commission, f.CommissionAsset); err != nil {
+			return nil, err
+		}
+	}
+
+	return placed, nil
+}
+
+func (e Binance) CancelOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	id, err := strconv.ParseInt(orderId, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.client.NewCancelOrderService().
+		Symbol(symbol).
+		OrderID(id).
+		Do(ctx)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	canceled, err := e.toOrderResponse(binance.Order{
+		Symbol:                   res.Symbol,
+		OrderID:                  res.OrderID,
+		ClientOrderID:            res.OrigClientOrderID,
+		Price:                    res.Price,
+		OrigQuantity:             res.OrigQuantity,
+		ExecutedQuantity:         res.ExecutedQuantity,
+		CummulativeQuoteQuantity: res.CummulativeQuoteQuantity,
+		Status:                   res.Status,
+		Side:                     res.Side,
+	}, res.TransactTime, res)
+	if err != nil {
+		return nil, err
+	}
+
+	// the order is canceled already, its fee is left unknown rather than failing the cancel
+	if err := e.queryCommission(ctx, canceled); err != nil {
+		canceled.Commission, canceled.CommissionAsset, canceled.OtherCommissions = decimal.Zero, "", nil
+	}
+
+	return canceled, nil
+}
+
+func (e Binance) QueryOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	id, err := strconv.ParseInt(orderId, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.client.NewGetOrderService().
+		Symbol(symbol).
+		OrderID(id).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queried, err := e.toOrderResponse(*res, res.UpdateTime, res)
+	if err != nil {
+		return nil, err
+	}
+
+	// the state of the order is returned with an unknown fee rather than lost
+	if err := e.queryCommission(ctx, queried); err != nil {
+		queried.Commission, queried.CommissionAsset, queried.OtherCommissions = decimal.Zero, "", nil
+	}
+
+	return queried, nil
+}
+
+// queryCommission reads fees of an executed order from its account trades, order queries do not list fills
+func (e Binance) queryCommission(ctx context.Context, res *OrderResponse) error {
+	if !res.ExecutedQty.IsPositive() {
+		return nil
+	}
+
+	id, err := strconv.ParseInt(res.OrderId, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	trades, err := e.client.NewListTradesService().
+		Symbol(res.Symbol).
+		OrderId(id).
+		Do(ctx)
+	if err != nil {
+		e.logger.Error(err)
+
+		return err
+	}
+
+	for _, t := range trades {
+		if err := e.addCommission(res, t.Commission, t.CommissionAsset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCommission adds the fee of a single fill. Binance charges an order in one asset unless BNB runs out while
+// it is filled, fees in another asset than the first one are added to OtherCommissions.
+func (e Binance) addCommission(res *OrderResponse, commission string, asset string) error {
+	fee, err := parseDecimal(commission)
+	if err != nil {
+		e.logger.Error(err)
+
+		return err
+	}
+
+	if res.CommissionAsset != "" && res.CommissionAsset != asset {
+		if res.OtherCommissions == nil {
+			res.OtherCommissions = map[string]decimal.Decimal{}
+		}
+
+		res.OtherCommissions[asset] = res.OtherCommissions[asset].Add(fee)
+
+		return nil
+	}
+
+	res.Commission = res.Commission.Add(fee)
+	res.CommissionAsset = asset
+
+	return nil
+}
+
+func (e Binance) Balances(ctx context.Context) ([]Balance, error) {
+	res, err := e.client.NewGetAccountService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]Balance, 0, len(res.Balances))
+
+	for _, b := range res.Balances {
+		free, err := parseDecimal(b.Free)
+		if err != nil {
+			return nil, err
+		}
+
+		locked, err := parseDecimal(b.Locked)
+		if err != nil {
+			return nil, err
+		}
+
+		balances = append(balances, Balance{
+			Asset:  b.Asset,
+			Free:   free,
+			Locked: locked,
+		})
+	}
+
+	return balances, nil
+}
+
+func (e Binance) SymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error) {
+	res, err := e.client.NewExchangeInfoService().
+		Symbol(symbol).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range res.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+
+		return e.toSymbolInfo(s)
+	}
+
+	return nil, ErrSymbolNotFound
+}
+
+func (e Binance) toSymbolInfo(s binance.Symbol) (*SymbolInfo, error) {
+	info := &SymbolInfo{
+		Symbol:     s.Symbol,
+		Status:     s.Status,
+		BaseAsset:  s.BaseAsset,
+		QuoteAsset: s.QuoteAsset,
+	}
+
+	fields := map[string]map[string]*decimal.Decimal{
+		"LOT_SIZE":     {"stepSize": &info.StepSize, "minQty": &info.MinQty},
+		"PRICE_FILTER": {"tickSize": &info.TickSize},
+		// MIN_NOTIONAL was replaced by NOTIONAL, symbols have one of them
+		"MIN_NOTIONAL": {"minNotional": &info.MinNotional},
+		"NOTIONAL":     {"minNotional": &info.MinNotional},
+	}
+
+	for _, filter := range s.Filters {
+		filterType, _ := filter["filterType"].(string)
+
+		for key, dst := range fields[filterType] {
+			v, _ := filter[key].(string)
+
+			value, err := parseDecimal(v)
+			if err != nil {
+				e.logger.Error(err)
+
+				return nil, err
+			}
+
+			*dst = value
+		}
+	}
+
+	return info, nil
+}
+
+func (e Binance) toBookTicker(t *binance.BookTicker) (*BookTicker, error) {
+	bidPrice, err := parseDecimal(t.BidPrice)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	bidQty, err := parseDecimal(t.BidQuantity)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	askPrice, err := parseDecimal(t.AskPrice)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	askQty, err := parseDecimal(t.AskQuantity)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	return &BookTicker{
+		Symbol:   t.Symbol,
+		BidPrice: bidPrice,
+		BidQty:   bidQty,
+		AskPrice: askPrice,
+		AskQty:   askQty,
+	}, nil
+}
+
+func (e Binance) toPriceLevels(levels []binance.Bid) ([]PriceLevel, error) {
+	res := make([]PriceLevel, 0, len(levels))
+
+	for i := range levels {
+		price, err := parseDecimal(levels[i].Price)
+		if err != nil {
+			e.logger.Error(err)
+
+			return nil, err
+		}
+
+		qty, err := parseDecimal(levels[i].Quantity)
+		if err != nil {
+			e.logger.Error(err)
+
+			return nil, err
+		}
+
+		res = append(res, PriceLevel{Price: price, Quantity: qty})
+	}
+
+	return res, nil
+}
+
+// toOrderResponse converts any of the binance order representations, raw is stored as it was received
+func (e Binance) toOrderResponse(o binance.Order, transactTime int64, raw any) (*OrderResponse, error) {
+	rawJSON, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := parseDecimal(o.Price)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	origQty, err := parseDecimal(o.OrigQuantity)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	executedQty, err := parseDecimal(o.ExecutedQuantity)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	cumulativeQuoteQty, err := parseDecimal(o.CummulativeQuoteQuantity)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	return &OrderResponse{
+		OrderId:            strconv.FormatInt(o.OrderID, 10),
+		ClientOrderId:      o.ClientOrderID,
+		Symbol:             o.Symbol,
+		Side:               Side(o.Side),
+		Status:             OrderStatus(o.Status),
+		Price:              price,
+		OrigQty:            origQty,
+		ExecutedQty:        executedQty,
+		CumulativeQuoteQty: cumulativeQuoteQty,
+		TransactTime:       time.UnixMilli(transactTime),
+		Raw:                string(rawJSON),
+	}, nil
+}
+
+// parseDecimal treats an empty string as zero, exchange omits some fields depending on the response type
+func parseDecimal(v string) (decimal.Decimal, error) {
+	if v == "" {
+		return decimal.Zero, nil
+	}
+
+	return decimal.NewFromString(v)
+}
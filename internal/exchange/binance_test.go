@@ -0,0 +1,248 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/beng90/trader/internal/exchange/binancetest"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = &logus.TestLogger{}
+
+func TestBinance_BookTicker(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetBookTicker(binance.BookTicker{
+		Symbol:      "BNBUSDT",
+		BidPrice:    "115.5",
+		BidQuantity: "12",
+		AskPrice:    "115.6",
+		AskQuantity: "3.5",
+	})
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	ticker, err := e.BookTicker(context.Background(), "BNBUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "BNBUSDT", ticker.Symbol)
+	assertDecimal(t, "115.5", ticker.BidPrice)
+	assertDecimal(t, "12", ticker.BidQty)
+	assertDecimal(t, "115.6", ticker.AskPrice)
+	assertDecimal(t, "3.5", ticker.AskQty)
+
+	_, err = e.BookTicker(context.Background(), "ETHUSDT")
+	assert.Error(t, err)
+}
+
+func TestBinance_BookTickers(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	for _, symbol := range []string{"BNBUSDT", "ETHUSDT", "BTCUSDT"} {
+		server.SetBookTicker(binance.BookTicker{Symbol: symbol, BidPrice: "1", BidQuantity: "2", AskPrice: "3", AskQuantity: "4"})
+	}
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	tickers, err := e.BookTickers(context.Background(), "BNBUSDT", "ETHUSDT", "XRPUSDT")
+	assert.NoError(t, err)
+
+	var symbols []string
+
+	for _, ticker := range tickers {
+		symbols = append(symbols, ticker.Symbol)
+		assertDecimal(t, "1", ticker.BidPrice)
+		assertDecimal(t, "4", ticker.AskQty)
+	}
+
+	assert.ElementsMatch(t, []string{"BNBUSDT", "ETHUSDT"}, symbols)
+
+	tickers, err = e.BookTickers(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tickers, 3)
+
+	assert.Equal(t, 2, server.Requests("/api/v3/ticker/bookTicker"))
+}
+
+func TestBinance_Depth(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetDepth("BNBUSDT", binance.DepthResponse{
+		Bids: []binance.Bid{{Price: "115", Quantity: "1"}, {Price: "114", Quantity: "2"}},
+		Asks: []binance.Ask{{Price: "116", Quantity: "3"}},
+	})
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	depth, err := e.Depth(context.Background(), "BNBUSDT", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "BNBUSDT", depth.Symbol)
+	assert.Equal(t, []string{"115 1", "114 2"}, levelStrings(depth.Bids))
+	assert.Equal(t, []string{"116 3"}, levelStrings(depth.Asks))
+}
+
+func TestBinance_PlaceOrder(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetLiquidity("BNBUSDT", dec("4"))
+	server.SetCommission(dec("0.00075"), "BNB")
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:        "BNBUSDT",
+		Side:          SideSell,
+		Quantity:      dec("10"),
+		Price:         dec("115"),
+		ClientOrderId: "client-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res.OrderId)
+	assert.Equal(t, "client-1", res.ClientOrderId)
+	assert.Equal(t, SideSell, res.Side)
+	assert.Equal(t, OrderStatusExpired, res.Status)
+	assertDecimal(t, "10", res.OrigQty)
+	assertDecimal(t, "4", res.ExecutedQty)
+	assertDecimal(t, "460", res.CumulativeQuoteQty)
+	assertDecimal(t, "0.003", res.Commission)
+	assert.Equal(t, "BNB", res.CommissionAsset)
+
+	sent := server.Orders()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, binance.TimeInForceTypeIOC, sent[0].TimeInForce)
+}
+
+func TestBinance_PlaceOrderError(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetError(-1013, "Filter failure: LOT_SIZE")
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:   "BNBUSDT",
+		Side:     SideSell,
+		Quantity: dec("10"),
+		Price:    dec("115"),
+	})
+	assert.Nil(t, res)
+
+	apiErr, ok := err.(*common.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1013), apiErr.Code)
+}
+
+func TestBinance_QueryAndCancelOrder(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetOpenOrder(binance.Order{
+		Symbol:           "BNBUSDT",
+		OrderID:          7,
+		ClientOrderID:    "client-7",
+		Price:            "120",
+		OrigQuantity:     "10",
+		ExecutedQuantity: "2",
+		Status:           binance.OrderStatusTypePartiallyFilled,
+		Side:             binance.SideTypeSell,
+	})
+	server.SetTrades(7, []*binance.TradeV3{
+		{ID: 1, Symbol: "BNBUSDT", OrderID: 7, Price: "120", Quantity: "1.5", Commission: "0.18", CommissionAsset: "USDT"},
+		{ID: 2, Symbol: "BNBUSDT", OrderID: 7, Price: "120", Quantity: "0.5", Commission: "0.06", CommissionAsset: "USDT"},
+		{ID: 3, Symbol: "BNBUSDT", OrderID: 7, Price: "120", Quantity: "0", Commission: "0.01", CommissionAsset: "BNB"},
+	})
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	res, err := e.QueryOrder(context.Background(), "BNBUSDT", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPartiallyFilled, res.Status)
+	assertDecimal(t, "2", res.ExecutedQty)
+	// fees in another asset than the first fill are kept apart
+	assertDecimal(t, "0.24", res.Commission)
+	assert.Equal(t, "USDT", res.CommissionAsset)
+	require.Len(t, res.OtherCommissions, 1)
+	assertDecimal(t, "0.01", res.OtherCommissions["BNB"])
+
+	res, err = e.CancelOrder(context.Background(), "BNBUSDT", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusCanceled, res.Status)
+	assert.Equal(t, "client-7", res.ClientOrderId)
+	assertDecimal(t, "0.24", res.Commission)
+
+	_, err = e.QueryOrder(context.Background(), "BNBUSDT", "8")
+	assert.Error(t, err)
+
+	// the order state is returned when its fee cannot be read
+	server.SetOpenOrder(binance.Order{
+		Symbol:           "BNBUSDT",
+		OrderID:          9,
+		Price:            "120",
+		OrigQuantity:     "10",
+		ExecutedQuantity: "2",
+		Status:           binance.OrderStatusTypePartiallyFilled,
+		Side:             binance.SideTypeSell,
+	})
+	server.SetTrades(9, []*binance.TradeV3{
+		{ID: 4, Symbol: "BNBUSDT", OrderID: 9, Price: "120", Quantity: "2", Commission: "invalid", CommissionAsset: "USDT"},
+	})
+
+	res, err = e.QueryOrder(context.Background(), "BNBUSDT", "9")
+	require.NoError(t, err)
+	assertDecimal(t, "2", res.ExecutedQty)
+	assertDecimal(t, "0", res.Commission)
+	assert.Empty(t, res.CommissionAsset)
+	assert.Empty(t, res.OtherCommissions)
+}
+
+func TestBinance_BalancesAndSymbolInfo(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetBalances([]binance.Balance{
+		{Asset: "BNB", Free: "10.5", Locked: "1"},
+		{Asset: "USDT", Free: "100", Locked: "0"},
+	})
+	server.SetSymbols([]binance.Symbol{
+		{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT", Filters: []map[string]interface{}{
+			{"filterType": "PRICE_FILTER", "minPrice": "0.10000000", "maxPrice": "100000.00000000", "tickSize": "0.10000000"},
+			{"filterType": "LOT_SIZE", "minQty": "0.00100000", "maxQty": "900000.00000000", "stepSize": "0.00100000"},
+			{"filterType": "NOTIONAL", "minNotional": "5.00000000", "applyMinToMarket": true},
+		}},
+	})
+
+	e := NewBinance(server.NewClient(), testLogger)
+
+	balances, err := e.Balances(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, balances, 2)
+	assert.Equal(t, "BNB", balances[0].Asset)
+	assertDecimal(t, "10.5", balances[0].Free)
+	assertDecimal(t, "1", balances[0].Locked)
+	assert.Equal(t, "USDT", balances[1].Asset)
+	assertDecimal(t, "100", balances[1].Free)
+	assertDecimal(t, "0", balances[1].Locked)
+
+	info, err := e.SymbolInfo(context.Background(), "BNBUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "BNBUSDT", info.Symbol)
+	assert.Equal(t, "TRADING", info.Status)
+	assert.Equal(t, "BNB", info.BaseAsset)
+	assert.Equal(t, "USDT", info.QuoteAsset)
+	assertDecimal(t, "0.001", info.StepSize)
+	assertDecimal(t, "0.001", info.MinQty)
+	assertDecimal(t, "0.1", info.TickSize)
+	assertDecimal(t, "5", info.MinNotional)
+
+	_, err = e.SymbolInfo(context.Background(), "ETHUSDT")
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+}
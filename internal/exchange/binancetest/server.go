@@ -0,0 +1,440 @@
+package binancetest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/shopspring/decimal"
+)
+
+// Server is a local stand-in for the Binance REST API
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	nextOrderId int64
+	liquidity   map[string]decimal.Decimal
+	apiErr      *common.APIError
+	orders      []binance.CreateOrderResponse
+	bookTickers map[string]binance.BookTicker
+	depths      map[string]binance.DepthResponse
+	balances    []binance.Balance
+	symbols     []binance.Symbol
+	openOrders  map[int64]binance.Order
+	requests    map[string]int
+	// commission is charged in commissionAsset for every fill, in proportion to its quantity
+	commission      decimal.Decimal
+	commissionAsset string
+	// trades are fills of orders listed by /api/v3/myTrades
+	trades map[int64][]*binance.TradeV3
+}
+
+func NewServer() *Server {
+	s := &Server{
+		nextOrderId: 1,
+		liquidity:   map[string]decimal.Decimal{},
+		bookTickers: map[string]binance.BookTicker{},
+		depths:      map[string]binance.DepthResponse{},
+		openOrders:  map[int64]binance.Order{},
+		requests:    map[string]int{},
+		trades:      map[int64][]*binance.TradeV3{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/order", s.handleOrder)
+	mux.HandleFunc("/api/v3/ticker/bookTicker", s.handleBookTicker)
+	mux.HandleFunc("/api/v3/depth", s.handleDepth)
+	mux.HandleFunc("/api/v3/account", s.handleAccount)
+	mux.HandleFunc("/api/v3/exchangeInfo", s.handleExchangeInfo)
+	mux.HandleFunc("/api/v3/myTrades", s.handleMyTrades)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// Requests returns how many requests were sent to path
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// NewClient returns a binance client pointed at the server
+func (s *Server) NewClient() *binance.Client {
+	client := binance.NewClient("test-api-key", "test-api-secret")
+	client.BaseURL = s.URL
+
+	return client
+}
+
+// SetLiquidity limits how much can be executed for symbol, by default every order is filled completely
+func (s *Server) SetLiquidity(symbol string, qty decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.liquidity[symbol] = qty
+}
+
+// SetCommission charges rate of the executed quantity in asset for every order filled by the server
+func (s *Server) SetCommission(rate decimal.Decimal, asset string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commission = rate
+	s.commissionAsset = asset
+}
+
+// SetTrades stores fills of an order, they are listed with their fees by /api/v3/myTrades
+func (s *Server) SetTrades(orderId int64, trades []*binance.TradeV3) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trades[orderId] = trades
+}
+
+// SetError makes every following request fail with given code and message
+func (s *Server) SetError(code int64, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiErr = &common.APIError{Code: code, Message: message}
+}
+
+func (s *Server) SetBookTicker(ticker binance.BookTicker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bookTickers[ticker.Symbol] = ticker
+}
+
+func (s *Server) SetDepth(symbol string, depth binance.DepthResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.depths[symbol] = depth
+}
+
+func (s *Server) SetBalances(balances []binance.Balance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balances = balances
+}
+
+func (s *Server) SetSymbols(symbols []binance.Symbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.symbols = symbols
+}
+
+// SetOpenOrder stores an order which can be queried and canceled
+func (s *Server) SetOpenOrder(o binance.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.openOrders[o.OrderID] = o
+}
+
+// Orders returns all orders accepted by the server
+func (s *Server) Orders() []binance.CreateOrderResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]binance.CreateOrderResponse{}, s.orders...)
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.apiErr != nil {
+		writeJSON(w, http.StatusBadRequest, s.apiErr)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.createOrder(w, r)
+	case http.MethodGet:
+		s.getOrder(w, r)
+	case http.MethodDelete:
+		s.cancelOrder(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, common.APIError{Code: -1000, Message: "method not allowed"})
+	}
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1000, Message: err.Error()})
+
+		return
+	}
+
+	symbol := r.PostForm.Get("symbol")
+
+	qty, err := decimal.NewFromString(r.PostForm.Get("quantity"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1100, Message: "illegal characters found in parameter 'quantity'"})
+
+		return
+	}
+
+	price, err := decimal.NewFromString(r.PostForm.Get("price"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1100, Message: "illegal characters found in parameter 'price'"})
+
+		return
+	}
+
+	executed := qty
+	if available, ok := s.liquidity[symbol]; ok {
+		executed = decimal.Min(qty, available)
+		s.liquidity[symbol] = available.Sub(executed)
+	}
+
+	status := binance.OrderStatusTypeFilled
+	if executed.LessThan(qty) {
+		status = binance.OrderStatusTypeExpired
+	}
+
+	res := binance.CreateOrderResponse{
+		Symbol:                   symbol,
+		OrderID:                  s.nextOrderId,
+		ClientOrderID:            r.PostForm.Get("newClientOrderId"),
+		TransactTime:             time.Now().UnixMilli(),
+		Price:                    r.PostForm.Get("price"),
+		OrigQuantity:             r.PostForm.Get("quantity"),
+		ExecutedQuantity:         executed.String(),
+		CummulativeQuoteQuantity: executed.Mul(price).String(),
+		Status:                   status,
+		TimeInForce:              binance.TimeInForceType(r.PostForm.Get("timeInForce")),
+		Type:                     binance.OrderType(r.PostForm.Get("type")),
+		Side:                     binance.SideType(r.PostForm.Get("side")),
+	}
+
+	if executed.IsPositive() && s.commissionAsset != "" {
+		fill := &binance.Fill{
+			TradeID:         int(res.OrderID),
+			Price:           res.Price,
+			Quantity:        res.ExecutedQuantity,
+			Commission:      executed.Mul(s.commission).String(),
+			CommissionAsset: s.commissionAsset,
+		}
+
+		res.Fills = append(res.Fills, fill)
+		s.trades[res.OrderID] = append(s.trades[res.OrderID], &binance.TradeV3{
+			ID:              res.OrderID,
+			Symbol:          symbol,
+			OrderID:         res.OrderID,
+			Price:           fill.Price,
+			Quantity:        fill.Quantity,
+			Commission:      fill.Commission,
+			CommissionAsset: fill.CommissionAsset,
+		})
+	}
+
+	s.nextOrderId++
+	s.orders = append(s.orders, res)
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	o, ok := s.findOrder(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -2013, Message: "Order does not exist."})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (s *Server) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	o, ok := s.findOrder(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -2011, Message: "Unknown order sent."})
+
+		return
+	}
+
+	o.Status = binance.OrderStatusTypeCanceled
+	s.openOrders[o.OrderID] = o
+
+	writeJSON(w, http.StatusOK, binance.CancelOrderResponse{
+		Symbol:                   o.Symbol,
+		OrigClientOrderID:        o.ClientOrderID,
+		OrderID:                  o.OrderID,
+		TransactTime:             time.Now().UnixMilli(),
+		Price:                    o.Price,
+		OrigQuantity:             o.OrigQuantity,
+		ExecutedQuantity:         o.ExecutedQuantity,
+		CummulativeQuoteQuantity: o.CummulativeQuoteQuantity,
+		Status:                   o.Status,
+		TimeInForce:              o.TimeInForce,
+		Type:                     o.Type,
+		Side:                     o.Side,
+	})
+}
+
+func (s *Server) findOrder(r *http.Request) (binance.Order, bool) {
+	params := r.URL.Query()
+
+	// net/http does not parse the body of DELETE requests, binance client sends cancel params there
+	if body, err := io.ReadAll(r.Body); err == nil {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for k, v := range form {
+				params[k] = v
+			}
+		}
+	}
+
+	id, err := strconv.ParseInt(params.Get("orderId"), 10, 64)
+	if err != nil {
+		return binance.Order{}, false
+	}
+
+	o, ok := s.openOrders[id]
+	if !ok || o.Symbol != params.Get("symbol") {
+		return binance.Order{}, false
+	}
+
+	return o, true
+}
+
+func (s *Server) handleBookTicker(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.apiErr != nil {
+		writeJSON(w, http.StatusBadRequest, s.apiErr)
+
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		tickers := make([]binance.BookTicker, 0, len(s.bookTickers))
+		for _, t := range s.bookTickers {
+			tickers = append(tickers, t)
+		}
+
+		writeJSON(w, http.StatusOK, tickers)
+
+		return
+	}
+
+	ticker, ok := s.bookTickers[symbol]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1121, Message: "Invalid symbol."})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ticker)
+}
+
+func (s *Server) handleDepth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	depth, ok := s.depths[r.URL.Query().Get("symbol")]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1121, Message: "Invalid symbol."})
+
+		return
+	}
+
+	levels := func(levels []common.PriceLevel) [][]string {
+		res := make([][]string, 0, len(levels))
+		for _, l := range levels {
+			res = append(res, []string{l.Price, l.Quantity})
+		}
+
+		return res
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"lastUpdateId": depth.LastUpdateID,
+		"bids":         levels(depth.Bids),
+		"asks":         levels(depth.Asks),
+	})
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, binance.Account{
+		CanTrade: true,
+		Balances: s.balances,
+	})
+}
+
+func (s *Server) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := r.URL.Query().Get("symbol")
+
+	symbols := make([]binance.Symbol, 0, len(s.symbols))
+	for _, sym := range s.symbols {
+		if symbol == "" || sym.Symbol == symbol {
+			symbols = append(symbols, sym)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, binance.ExchangeInfo{
+		Timezone:   "UTC",
+		ServerTime: time.Now().UnixMilli(),
+		Symbols:    symbols,
+	})
+}
+
+func (s *Server) handleMyTrades(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("orderId"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, common.APIError{Code: -1100, Message: "illegal characters found in parameter 'orderId'"})
+
+		return
+	}
+
+	trades := []*binance.TradeV3{}
+
+	for _, t := range s.trades[id] {
+		if t.Symbol == r.URL.Query().Get("symbol") {
+			trades = append(trades, t)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, trades)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
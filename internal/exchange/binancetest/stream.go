@@ -0,0 +1,157 @@
+package binancetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/gorilla/websocket"
+)
+
+// StreamServer is a local stand-in for the Binance combined WebSocket stream
+type StreamServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	upgrader    websocket.Upgrader
+	conns       map[*websocket.Conn]map[string]bool
+	connections int
+	connected   chan struct{}
+}
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Id     int64    `json:"id"`
+}
+
+type streamMessage struct {
+	Stream string `json:"stream"`
+	Data   any    `json:"data"`
+}
+
+func NewStreamServer() *StreamServer {
+	s := &StreamServer{
+		conns:     map[*websocket.Conn]map[string]bool{},
+		connected: make(chan struct{}, 16),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", s.handleStream)
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// WsURL is the base url the stream client should connect to
+func (s *StreamServer) WsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// Connected receives a value every time a client connects
+func (s *StreamServer) Connected() <-chan struct{} {
+	return s.connected
+}
+
+// Connections returns how many times clients connected
+func (s *StreamServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connections
+}
+
+// Subscribed tells if any connected client listens to stream
+func (s *StreamServer) Subscribed(stream string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, streams := range s.conns {
+		if streams[stream] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PublishBookTicker sends the event to every client subscribed to its @bookTicker stream
+func (s *StreamServer) PublishBookTicker(event binance.WsBookTickerEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream := strings.ToLower(event.Symbol) + "@bookTicker"
+
+	for conn, streams := range s.conns {
+		if streams[stream] {
+			_ = conn.WriteJSON(streamMessage{Stream: stream, Data: event})
+		}
+	}
+}
+
+// DropConnections closes all client connections, clients are expected to reconnect
+func (s *StreamServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func (s *StreamServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	streams := map[string]bool{}
+	if q := r.URL.Query().Get("streams"); q != "" {
+		for _, stream := range strings.Split(q, "/") {
+			streams[stream] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.conns[conn] = streams
+	s.connections++
+	s.mu.Unlock()
+
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var req streamRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if subscribed, ok := s.conns[conn]; ok && req.Method == "SUBSCRIBE" {
+			for _, stream := range req.Params {
+				subscribed[stream] = true
+			}
+
+			_ = conn.WriteJSON(map[string]any{"result": nil, "id": req.Id})
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	_ = conn.Close()
+}
@@ -0,0 +1,26 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrSymbolNotFound = errors.New("cannot find symbol")
+	ErrOrderNotFound  = errors.New("cannot find order")
+	ErrFilterFailure  = errors.New("order does not pass symbol filters")
+)
+
+// Exchange is an external trading system market data is read from and orders are sent to
+type Exchange interface {
+	BookTicker(ctx context.Context, symbol string) (*BookTicker, error)
+	// BookTickers returns tickers of symbols in a single request, all tickers when no symbol is given.
+	// Unknown symbols are left out.
+	BookTickers(ctx context.Context, symbols ...string) ([]BookTicker, error)
+	Depth(ctx context.Context, symbol string, limit int) (*Depth, error)
+	PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error)
+	CancelOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error)
+	QueryOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error)
+	Balances(ctx context.Context) ([]Balance, error)
+	SymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error)
+}
@@ -0,0 +1,172 @@
+package exchange
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Side string
+
+const (
+	SideBuy  Side = "BUY"
+	SideSell Side = "SELL"
+)
+
+type TimeInForce string
+
+const (
+	// TimeInForceIOC expires whatever is not matched immediately, it is used when none is given
+	TimeInForceIOC TimeInForce = "IOC"
+	// TimeInForceGTC keeps the unmatched part of the order in the book until it is filled or canceled
+	TimeInForceGTC TimeInForce = "GTC"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusNew             OrderStatus = "NEW"
+	OrderStatusPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderStatusFilled          OrderStatus = "FILLED"
+	OrderStatusCanceled        OrderStatus = "CANCELED"
+	OrderStatusRejected        OrderStatus = "REJECTED"
+	OrderStatusExpired         OrderStatus = "EXPIRED"
+)
+
+// IsOpen tells if the order can still be executed
+func (s OrderStatus) IsOpen() bool {
+	return s == OrderStatusNew || s == OrderStatusPartiallyFilled
+}
+
+type BookTicker struct {
+	Symbol   string
+	BidPrice decimal.Decimal
+	BidQty   decimal.Decimal
+	AskPrice decimal.Decimal
+	AskQty   decimal.Decimal
+}
+
+type PriceLevel struct {
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+}
+
+type Depth struct {
+	Symbol string
+	// Bids are sorted from the highest price
+	Bids []PriceLevel
+	// Asks are sorted from the lowest price
+	Asks []PriceLevel
+}
+
+type Balance struct {
+	Asset  string
+	Free   decimal.Decimal
+	Locked decimal.Decimal
+}
+
+// SymbolInfo holds symbol rules orders have to follow, zero filter values mean no limit
+type SymbolInfo struct {
+	Symbol     string
+	Status     string
+	BaseAsset  string
+	QuoteAsset string
+	// StepSize and MinQty come from the LOT_SIZE filter
+	StepSize decimal.Decimal
+	MinQty   decimal.Decimal
+	// TickSize comes from the PRICE_FILTER filter
+	TickSize decimal.Decimal
+	// MinNotional comes from the MIN_NOTIONAL or NOTIONAL filter
+	MinNotional decimal.Decimal
+}
+
+// RoundQuantity rounds qty down to the step size
+func (i SymbolInfo) RoundQuantity(qty decimal.Decimal) decimal.Decimal {
+	if !i.StepSize.IsPositive() {
+		return qty
+	}
+
+	return qty.Div(i.StepSize).Floor().Mul(i.StepSize)
+}
+
+// RoundPrice rounds price to the nearest tick
+func (i SymbolInfo) RoundPrice(price decimal.Decimal) decimal.Decimal {
+	if !i.TickSize.IsPositive() {
+		return price
+	}
+
+	return price.Div(i.TickSize).Round(0).Mul(i.TickSize)
+}
+
+// MinQuantity is the smallest quantity which can be ordered at price
+func (i SymbolInfo) MinQuantity(price decimal.Decimal) decimal.Decimal {
+	qty := i.MinQty
+
+	if i.MinNotional.IsPositive() && price.IsPositive() {
+		notionalQty := i.MinNotional.Div(price)
+		if i.StepSize.IsPositive() {
+			notionalQty = notionalQty.Div(i.StepSize).Ceil().Mul(i.StepSize)
+		}
+
+		qty = decimal.Max(qty, notionalQty)
+	}
+
+	return qty
+}
+
+// Check tells if an order of qty at price passes the symbol filters
+func (i SymbolInfo) Check(qty decimal.Decimal, price decimal.Decimal) error {
+	if !qty.IsPositive() || qty.LessThan(i.MinQuantity(price)) {
+		return fmt.Errorf("%w: quantity %s of %s is below minimum %s", ErrFilterFailure, qty, i.Symbol, i.MinQuantity(price))
+	}
+
+	if !i.RoundQuantity(qty).Equal(qty) {
+		return fmt.Errorf("%w: quantity %s of %s is not a multiple of step size %s", ErrFilterFailure, qty, i.Symbol, i.StepSize)
+	}
+
+	if !i.RoundPrice(price).Equal(price) {
+		return fmt.Errorf("%w: price %s of %s is not a multiple of tick size %s", ErrFilterFailure, price, i.Symbol, i.TickSize)
+	}
+
+	return nil
+}
+
+type OrderRequest struct {
+	Symbol        string
+	Side          Side
+	Quantity      decimal.Decimal
+	Price         decimal.Decimal
+	TimeInForce   TimeInForce
+	ClientOrderId string
+}
+
+type OrderResponse struct {
+	OrderId            string
+	ClientOrderId      string
+	Symbol             string
+	Side               Side
+	Status             OrderStatus
+	Price              decimal.Decimal
+	OrigQty            decimal.Decimal
+	ExecutedQty        decimal.Decimal
+	CumulativeQuoteQty decimal.Decimal
+	// Commission is the fee charged for the fills so far in CommissionAsset, the asset is empty when the fee is
+	// not known
+	Commission      decimal.Decimal
+	CommissionAsset string
+	// OtherCommissions are fees charged in other assets than CommissionAsset, Binance charges them when BNB runs
+	// out while the order is filled
+	OtherCommissions map[string]decimal.Decimal
+	// TransactTime is the time of the last change of the order
+	TransactTime time.Time
+	// Raw is the response body as returned by the exchange
+	Raw string
+}
+
+// FeeSchedule is the fee the simulated exchange charges in percent of a fill. Orders filled when they are placed
+// take liquidity and pay the taker fee, resting orders filled later pay the maker fee.
+type FeeSchedule struct {
+	MakerPercent decimal.Decimal
+	TakerPercent decimal.Decimal
+}
@@ -0,0 +1,107 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolInfo(t *testing.T) {
+	info := SymbolInfo{Symbol: "BNBUSDT", StepSize: dec("0.01"), MinQty: dec("0.05"), TickSize: dec("0.1"), MinNotional: dec("10")}
+
+	tests := []struct {
+		name        string
+		qty         string
+		price       string
+		wantQty     string
+		wantPrice   string
+		wantMinimum string
+		wantErr     bool
+	}{
+		{
+			name:        "quantity is rounded down to step",
+			qty:         "1.239",
+			price:       "115.3",
+			wantQty:     "1.23",
+			wantPrice:   "115.3",
+			wantMinimum: "0.09",
+			wantErr:     true,
+		},
+		{
+			name:        "multiple of step is kept",
+			qty:         "0.3",
+			price:       "115.3",
+			wantQty:     "0.3",
+			wantPrice:   "115.3",
+			wantMinimum: "0.09",
+		},
+		{
+			name:        "price is rounded to tick",
+			qty:         "1",
+			price:       "115.26",
+			wantQty:     "1",
+			wantPrice:   "115.3",
+			wantMinimum: "0.09",
+			wantErr:     true,
+		},
+		{
+			name:        "minimum quantity wins over notional at high price",
+			qty:         "0.05",
+			price:       "1000",
+			wantQty:     "0.05",
+			wantPrice:   "1000",
+			wantMinimum: "0.05",
+		},
+		{
+			name:        "below notional",
+			qty:         "0.08",
+			price:       "115.3",
+			wantQty:     "0.08",
+			wantPrice:   "115.3",
+			wantMinimum: "0.09",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qty, price := dec(tt.qty), dec(tt.price)
+
+			assertDecimal(t, tt.wantQty, info.RoundQuantity(qty))
+			assertDecimal(t, tt.wantPrice, info.RoundPrice(price))
+			assertDecimal(t, tt.wantMinimum, info.MinQuantity(price))
+
+			err := info.Check(qty, price)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrFilterFailure)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// symbols without filters accept everything
+	assertDecimal(t, "1.239", SymbolInfo{}.RoundQuantity(dec("1.239")))
+	assert.NoError(t, SymbolInfo{}.Check(dec("1.239"), dec("115.26")))
+}
+
+func dec(v string) decimal.Decimal {
+	return decimal.RequireFromString(v)
+}
+
+// assertDecimal compares values, not representations, 1.50 equals 1.5
+func assertDecimal(t *testing.T, want string, got decimal.Decimal) {
+	t.Helper()
+
+	assert.Equal(t, dec(want).String(), got.String())
+}
+
+func levelStrings(levels []PriceLevel) []string {
+	res := make([]string, 0, len(levels))
+	for _, l := range levels {
+		res = append(res, l.Price.String()+" "+l.Quantity.String())
+	}
+
+	return res
+}
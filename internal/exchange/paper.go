@@ -0,0 +1,118 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beng90/trader/pkg/logus"
+)
+
+// Paper reads market data and symbol rules from the live exchange but never sends orders to it. Orders are
+// matched by a Simulated exchange against the live book ticker at the time they are placed, the live book is
+// not consumed by them. Fills are charged by fees.
+type Paper struct {
+	live   Exchange
+	logger logus.Logger
+	sim    *Simulated
+
+	mu      sync.Mutex
+	symbols map[string]bool
+}
+
+func NewPaper(
+	live Exchange,
+	logger logus.Logger,
+	fees FeeSchedule,
+) *Paper {
+	sim := NewSimulated(logger)
+	sim.fees = fees
+	// paper orders are stored, their ids have to differ from ids of orders placed before a restart
+	sim.nextOrderId = time.Now().UnixMilli()
+
+	return &Paper{
+		live:    live,
+		logger:  logger,
+		sim:     sim,
+		symbols: map[string]bool{},
+	}
+}
+
+func (e *Paper) BookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	return e.live.BookTicker(ctx, symbol)
+}
+
+func (e *Paper) BookTickers(ctx context.Context, symbols ...string) ([]BookTicker, error) {
+	return e.live.BookTickers(ctx, symbols...)
+}
+
+func (e *Paper) Depth(ctx context.Context, symbol string, limit int) (*Depth, error) {
+	return e.live.Depth(ctx, symbol, limit)
+}
+
+func (e *Paper) SymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error) {
+	return e.live.SymbolInfo(ctx, symbol)
+}
+
+// PlaceOrder matches the order against the current live book ticker of its symbol
+func (e *Paper) PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error) {
+	if err := e.setSymbol(ctx, req.Symbol); err != nil {
+		return nil, err
+	}
+
+	ticker, err := e.live.BookTicker(ctx, req.Symbol)
+	if err != nil {
+		e.logger.Error(err)
+
+		return nil, err
+	}
+
+	var bids, asks []PriceLevel
+
+	if ticker.BidQty.IsPositive() {
+		bids = []PriceLevel{{Price: ticker.BidPrice, Quantity: ticker.BidQty}}
+	}
+
+	if ticker.AskQty.IsPositive() {
+		asks = []PriceLevel{{Price: ticker.AskPrice, Quantity: ticker.AskQty}}
+	}
+
+	e.sim.SetBook(req.Symbol, bids, asks)
+
+	return e.sim.PlaceOrder(ctx, req)
+}
+
+func (e *Paper) CancelOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	return e.sim.CancelOrder(ctx, symbol, orderId)
+}
+
+func (e *Paper) QueryOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	return e.sim.QueryOrder(ctx, symbol, orderId)
+}
+
+// Balances are not known, paper orders are not limited by them
+func (e *Paper) Balances(ctx context.Context) ([]Balance, error) {
+	return e.sim.Balances(ctx)
+}
+
+// setSymbol gives the simulated exchange live rules of symbol, so paper orders pass the same filters
+func (e *Paper) setSymbol(ctx context.Context, symbol string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.symbols[symbol] {
+		return nil
+	}
+
+	info, err := e.live.SymbolInfo(ctx, symbol)
+	if err != nil {
+		e.logger.Error(err)
+
+		return err
+	}
+
+	e.sim.SetSymbol(*info)
+	e.symbols[symbol] = true
+
+	return nil
+}
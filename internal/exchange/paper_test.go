@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaper_PlaceOrder(t *testing.T) {
+	live := newTestSimulated()
+	e := NewPaper(live, testLogger, FeeSchedule{})
+	ctx := context.Background()
+
+	res, err := e.PlaceOrder(ctx, OrderRequest{Symbol: "BNBUSDT", Side: SideSell, Quantity: dec("3"), Price: dec("110")})
+	require.NoError(t, err)
+
+	// only the top of the live book is matched
+	assert.Equal(t, OrderStatusExpired, res.Status)
+	assertDecimal(t, "2", res.ExecutedQty)
+	assertDecimal(t, "230", res.CumulativeQuoteQty)
+
+	// nothing was sent to the live exchange
+	ticker, err := live.BookTicker(ctx, "BNBUSDT")
+	require.NoError(t, err)
+	assertDecimal(t, "2", ticker.BidQty)
+
+	_, err = live.QueryOrder(ctx, "BNBUSDT", res.OrderId)
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+
+	queried, err := e.QueryOrder(ctx, "BNBUSDT", res.OrderId)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusExpired, queried.Status)
+
+	next, err := e.PlaceOrder(ctx, OrderRequest{Symbol: "BNBUSDT", Side: SideBuy, Quantity: dec("1"), Price: dec("116")})
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusFilled, next.Status)
+	assert.NotEqual(t, res.OrderId, next.OrderId)
+}
+
+func TestPaper_PlaceOrder_SymbolFilters(t *testing.T) {
+	live := newTestSimulated()
+	live.SetSymbol(SymbolInfo{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT", MinQty: dec("5")})
+
+	_, err := NewPaper(live, testLogger, FeeSchedule{}).PlaceOrder(context.Background(), OrderRequest{Symbol: "BNBUSDT", Side: SideSell, Quantity: dec("1"), Price: dec("110")})
+	assert.ErrorIs(t, err, ErrFilterFailure)
+
+	_, err = NewPaper(live, testLogger, FeeSchedule{}).PlaceOrder(context.Background(), OrderRequest{Symbol: "ETHUSDT", Side: SideSell, Quantity: dec("1"), Price: dec("110")})
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+}
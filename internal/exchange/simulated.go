@@ -0,0 +1,439 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInsufficientBalance = errors.New("account has insufficient balance for requested action")
+
+// Simulated is an in-memory exchange. Every symbol has a single order book set by SetBook, incoming
+// orders are matched against it and consume its liquidity, GTC leftovers wait for the next book update.
+type Simulated struct {
+	mu     sync.Mutex
+	logger logus.Logger
+	now    func() time.Time
+	fees   FeeSchedule
+
+	nextOrderId int64
+	symbols     map[string]SymbolInfo
+	books       map[string]*Depth
+	balances    map[string]*Balance
+	orders      map[string]*OrderResponse
+	// resting keeps ids of GTC orders waiting in the book, in the order they were placed
+	resting []string
+}
+
+func NewSimulated(logger logus.Logger) *Simulated {
+	return &Simulated{
+		logger:      logger,
+		now:         time.Now,
+		nextOrderId: 1,
+		symbols:     map[string]SymbolInfo{},
+		books:       map[string]*Depth{},
+		balances:    map[string]*Balance{},
+		orders:      map[string]*OrderResponse{},
+	}
+}
+
+// SetClock replaces the clock used for order timestamps
+func (e *Simulated) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.now = now
+}
+
+// SetFees sets the fees charged for fills, nothing is charged until they are set
+func (e *Simulated) SetFees(fees FeeSchedule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.fees = fees
+}
+
+// SetSymbol sets symbol rules, orders of the symbol are rejected when they do not pass its filters
+func (e *Simulated) SetSymbol(info SymbolInfo) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.symbols[info.Symbol] = info
+}
+
+// SetBalance sets free balance of asset, balances are checked only for assets which were set
+func (e *Simulated) SetBalance(asset string, free decimal.Decimal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.balances[asset] = &Balance{Asset: asset, Free: free}
+}
+
+// SetBook replaces the order book of symbol and matches resting orders against it
+func (e *Simulated) SetBook(symbol string, bids []PriceLevel, asks []PriceLevel) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book := &Depth{
+		Symbol: symbol,
+		Bids:   append([]PriceLevel{}, bids...),
+		Asks:   append([]PriceLevel{}, asks...),
+	}
+
+	sort.SliceStable(book.Bids, func(i, j int) bool { return book.Bids[i].Price.GreaterThan(book.Bids[j].Price) })
+	sort.SliceStable(book.Asks, func(i, j int) bool { return book.Asks[i].Price.LessThan(book.Asks[j].Price) })
+
+	e.books[symbol] = book
+
+	resting := e.resting[:0]
+
+	for _, id := range e.resting {
+		o := e.orders[id]
+		if o.Symbol == symbol {
+			e.match(o, book, e.fees.MakerPercent)
+		}
+
+		if o.Status.IsOpen() {
+			resting = append(resting, id)
+		}
+	}
+
+	e.resting = resting
+}
+
+func (e *Simulated) BookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book, ok := e.books[symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+
+	ticker := e.bookTicker(book)
+
+	return &ticker, nil
+}
+
+func (e *Simulated) BookTickers(ctx context.Context, symbols ...string) ([]BookTicker, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(symbols) == 0 {
+		for symbol := range e.books {
+			symbols = append(symbols, symbol)
+		}
+
+		sort.Strings(symbols)
+	}
+
+	tickers := make([]BookTicker, 0, len(symbols))
+
+	for _, symbol := range symbols {
+		if book, ok := e.books[symbol]; ok {
+			tickers = append(tickers, e.bookTicker(book))
+		}
+	}
+
+	return tickers, nil
+}
+
+func (e *Simulated) Depth(ctx context.Context, symbol string, limit int) (*Depth, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book, ok := e.books[symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+
+	bids := book.Bids
+	if limit > 0 && len(bids) > limit {
+		bids = bids[:limit]
+	}
+
+	asks := book.Asks
+	if limit > 0 && len(asks) > limit {
+		asks = asks[:limit]
+	}
+
+	return &Depth{
+		Symbol: symbol,
+		Bids:   append([]PriceLevel{}, bids...),
+		Asks:   append([]PriceLevel{}, asks...),
+	}, nil
+}
+
+func (e *Simulated) PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book, ok := e.books[req.Symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+
+	if !req.Quantity.IsPositive() || !req.Price.IsPositive() {
+		return nil, errors.New("order quantity and price must be positive")
+	}
+
+	if info, ok := e.symbols[req.Symbol]; ok {
+		if err := info.Check(req.Quantity, req.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.reserve(req.Symbol, req.Side, req.Quantity, req.Price); err != nil {
+		return nil, err
+	}
+
+	o := &OrderResponse{
+		OrderId:       strconv.FormatInt(e.nextOrderId, 10),
+		ClientOrderId: req.ClientOrderId,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		Status:        OrderStatusNew,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		TransactTime:  e.now(),
+	}
+
+	e.nextOrderId++
+	e.orders[o.OrderId] = o
+
+	e.match(o, book, e.fees.TakerPercent)
+
+	if o.Status.IsOpen() {
+		if req.TimeInForce == TimeInForceGTC {
+			e.resting = append(e.resting, o.OrderId)
+		} else {
+			e.close(o, OrderStatusExpired)
+		}
+	}
+
+	res := *o
+
+	return &res, nil
+}
+
+func (e *Simulated) CancelOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, id := range e.resting {
+		o := e.orders[id]
+		if id != orderId || o.Symbol != symbol {
+			continue
+		}
+
+		e.resting = append(e.resting[:i], e.resting[i+1:]...)
+		e.close(o, OrderStatusCanceled)
+
+		res := *o
+
+		return &res, nil
+	}
+
+	return nil, ErrOrderNotFound
+}
+
+func (e *Simulated) QueryOrder(ctx context.Context, symbol string, orderId string) (*OrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, ok := e.orders[orderId]
+	if !ok || o.Symbol != symbol {
+		return nil, ErrOrderNotFound
+	}
+
+	res := *o
+
+	return &res, nil
+}
+
+func (e *Simulated) Balances(ctx context.Context) ([]Balance, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	balances := make([]Balance, 0, len(e.balances))
+	for _, b := range e.balances {
+		balances = append(balances, *b)
+	}
+
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Asset < balances[j].Asset })
+
+	return balances, nil
+}
+
+func (e *Simulated) SymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	info, ok := e.symbols[symbol]
+	if !ok {
+		return nil, ErrSymbolNotFound
+	}
+
+	return &info, nil
+}
+
+func (e *Simulated) bookTicker(book *Depth) BookTicker {
+	ticker := BookTicker{Symbol: book.Symbol}
+
+	if len(book.Bids) > 0 {
+		ticker.BidPrice = book.Bids[0].Price
+		ticker.BidQty = book.Bids[0].Quantity
+	}
+
+	if len(book.Asks) > 0 {
+		ticker.AskPrice = book.Asks[0].Price
+		ticker.AskQty = book.Asks[0].Quantity
+	}
+
+	return ticker
+}
+
+// match fills the order against the opposite side of the book, fills happen at book prices and pay feePercent
+func (e *Simulated) match(o *OrderResponse, book *Depth, feePercent decimal.Decimal) {
+	levels := &book.Bids
+	crosses := func(price decimal.Decimal) bool { return price.GreaterThanOrEqual(o.Price) }
+
+	if o.Side == SideBuy {
+		levels = &book.Asks
+		crosses = func(price decimal.Decimal) bool { return price.LessThanOrEqual(o.Price) }
+	}
+
+	for len(*levels) > 0 && o.ExecutedQty.LessThan(o.OrigQty) {
+		level := &(*levels)[0]
+		if !crosses(level.Price) {
+			break
+		}
+
+		qty := decimal.Min(level.Quantity, o.OrigQty.Sub(o.ExecutedQty))
+
+		o.ExecutedQty = o.ExecutedQty.Add(qty)
+		o.CumulativeQuoteQty = o.CumulativeQuoteQty.Add(qty.Mul(level.Price))
+		o.TransactTime = e.now()
+		level.Quantity = level.Quantity.Sub(qty)
+
+		e.settle(o, qty, level.Price, feePercent)
+
+		if !level.Quantity.IsPositive() {
+			*levels = (*levels)[1:]
+		}
+	}
+
+	switch {
+	case o.ExecutedQty.GreaterThanOrEqual(o.OrigQty):
+		o.Status = OrderStatusFilled
+	case o.ExecutedQty.IsPositive():
+		o.Status = OrderStatusPartiallyFilled
+	}
+}
+
+// close moves an unfilled order to a final status and releases what was reserved for its remainder
+func (e *Simulated) close(o *OrderResponse, status OrderStatus) {
+	o.Status = status
+	o.TransactTime = e.now()
+
+	base, quote := e.assets(o.Symbol)
+	left := o.OrigQty.Sub(o.ExecutedQty)
+
+	if o.Side == SideSell {
+		e.release(base, left)
+	} else {
+		e.release(quote, left.Mul(o.Price))
+	}
+}
+
+// reserve locks the amount the order can spend, it fails when a set balance is too low
+func (e *Simulated) reserve(symbol string, side Side, qty decimal.Decimal, price decimal.Decimal) error {
+	base, quote := e.assets(symbol)
+
+	asset, amount := base, qty
+	if side == SideBuy {
+		asset, amount = quote, qty.Mul(price)
+	}
+
+	b, ok := e.balances[asset]
+	if !ok {
+		return nil
+	}
+
+	if b.Free.LessThan(amount) {
+		return ErrInsufficientBalance
+	}
+
+	b.Free = b.Free.Sub(amount)
+	b.Locked = b.Locked.Add(amount)
+
+	return nil
+}
+
+func (e *Simulated) release(asset string, amount decimal.Decimal) {
+	if b, ok := e.balances[asset]; ok {
+		b.Locked = b.Locked.Sub(amount)
+		b.Free = b.Free.Add(amount)
+	}
+}
+
+// settle moves balances for a single fill, the fee is charged in the asset the fill gives, as Binance does when
+// it is not paid in BNB. Fees of symbols whose assets are not known are not charged.
+func (e *Simulated) settle(o *OrderResponse, qty decimal.Decimal, price decimal.Decimal, feePercent decimal.Decimal) {
+	base, quote := e.assets(o.Symbol)
+
+	if o.Side == SideSell {
+		fee := e.charge(o, quote, qty.Mul(price), feePercent)
+
+		if b, ok := e.balances[base]; ok {
+			b.Locked = b.Locked.Sub(qty)
+		}
+
+		if b, ok := e.balances[quote]; ok {
+			b.Free = b.Free.Add(qty.Mul(price)).Sub(fee)
+		}
+
+		return
+	}
+
+	fee := e.charge(o, base, qty, feePercent)
+
+	if b, ok := e.balances[quote]; ok {
+		// buying below the limit price gives back the difference
+		b.Locked = b.Locked.Sub(qty.Mul(o.Price))
+		b.Free = b.Free.Add(qty.Mul(o.Price.Sub(price)))
+	}
+
+	if b, ok := e.balances[base]; ok {
+		b.Free = b.Free.Add(qty).Sub(fee)
+	}
+}
+
+// charge adds the fee of a fill giving amount of asset to the order
+func (e *Simulated) charge(o *OrderResponse, asset string, amount decimal.Decimal, feePercent decimal.Decimal) decimal.Decimal {
+	if asset == "" {
+		return decimal.Zero
+	}
+
+	fee := amount.Mul(feePercent).Div(decimal.NewFromInt(100))
+
+	o.Commission = o.Commission.Add(fee)
+	o.CommissionAsset = asset
+
+	return fee
+}
+
+func (e *Simulated) assets(symbol string) (string, string) {
+	info, ok := e.symbols[symbol]
+	if !ok {
+		return "", ""
+	}
+
+	return info.BaseAsset, info.QuoteAsset
+}
@@ -0,0 +1,159 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSimulated() *Simulated {
+	e := NewSimulated(testLogger)
+	e.SetSymbol(SymbolInfo{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT"})
+	e.SetBook("BNBUSDT",
+		[]PriceLevel{{Price: dec("114"), Quantity: dec("5")}, {Price: dec("115"), Quantity: dec("2")}, {Price: dec("100"), Quantity: dec("50")}},
+		[]PriceLevel{{Price: dec("116"), Quantity: dec("3")}, {Price: dec("117"), Quantity: dec("10")}},
+	)
+
+	return e
+}
+
+func TestSimulated_BookTicker(t *testing.T) {
+	e := newTestSimulated()
+
+	ticker, err := e.BookTicker(context.Background(), "BNBUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "BNBUSDT", ticker.Symbol)
+	assertDecimal(t, "115", ticker.BidPrice)
+	assertDecimal(t, "2", ticker.BidQty)
+	assertDecimal(t, "116", ticker.AskPrice)
+	assertDecimal(t, "3", ticker.AskQty)
+
+	_, err = e.BookTicker(context.Background(), "ETHUSDT")
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+}
+
+func TestSimulated_PlaceOrderIOC(t *testing.T) {
+	e := newTestSimulated()
+
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:   "BNBUSDT",
+		Side:     SideSell,
+		Quantity: dec("10"),
+		Price:    dec("114"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusExpired, res.Status)
+	assertDecimal(t, "7", res.ExecutedQty)
+	assertDecimal(t, "800", res.CumulativeQuoteQty)
+
+	// matched liquidity is gone from the book
+	ticker, err := e.BookTicker(context.Background(), "BNBUSDT")
+	assert.NoError(t, err)
+	assertDecimal(t, "100", ticker.BidPrice)
+	assertDecimal(t, "50", ticker.BidQty)
+
+	res, err = e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:   "BNBUSDT",
+		Side:     SideBuy,
+		Quantity: dec("5"),
+		Price:    dec("117"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusFilled, res.Status)
+	assertDecimal(t, "582", res.CumulativeQuoteQty)
+}
+
+func TestSimulated_PlaceOrderGTC(t *testing.T) {
+	e := newTestSimulated()
+
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:      "BNBUSDT",
+		Side:        SideSell,
+		Quantity:    dec("4"),
+		Price:       dec("115"),
+		TimeInForce: TimeInForceGTC,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPartiallyFilled, res.Status)
+	assertDecimal(t, "2", res.ExecutedQty)
+
+	e.SetBook("BNBUSDT", []PriceLevel{{Price: dec("120"), Quantity: dec("1")}}, nil)
+
+	res, err = e.QueryOrder(context.Background(), "BNBUSDT", res.OrderId)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPartiallyFilled, res.Status)
+	assertDecimal(t, "3", res.ExecutedQty)
+
+	res, err = e.CancelOrder(context.Background(), "BNBUSDT", res.OrderId)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusCanceled, res.Status)
+
+	_, err = e.CancelOrder(context.Background(), "BNBUSDT", res.OrderId)
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
+
+func TestSimulated_Balances(t *testing.T) {
+	e := newTestSimulated()
+	e.SetBalance("BNB", dec("3"))
+	e.SetBalance("USDT", dec("0"))
+
+	_, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:   "BNBUSDT",
+		Side:     SideSell,
+		Quantity: dec("4"),
+		Price:    dec("100"),
+	})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{
+		Symbol:   "BNBUSDT",
+		Side:     SideSell,
+		Quantity: dec("3"),
+		Price:    dec("115"),
+	})
+	assert.NoError(t, err)
+	assertDecimal(t, "2", res.ExecutedQty)
+
+	balances, err := e.Balances(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, balances, 2)
+	assert.Equal(t, "BNB", balances[0].Asset)
+	assertDecimal(t, "1", balances[0].Free)
+	assertDecimal(t, "0", balances[0].Locked)
+	assert.Equal(t, "USDT", balances[1].Asset)
+	assertDecimal(t, "230", balances[1].Free)
+	assertDecimal(t, "0", balances[1].Locked)
+}
+
+func TestSimulated_Fees(t *testing.T) {
+	e := newTestSimulated()
+	e.SetFees(FeeSchedule{MakerPercent: dec("0.1"), TakerPercent: dec("0.2")})
+	e.SetBalance("BNB", dec("2"))
+	e.SetBalance("USDT", dec("110"))
+
+	// a sell filled when it is placed takes liquidity and pays the taker fee in the quote asset
+	res, err := e.PlaceOrder(context.Background(), OrderRequest{Symbol: "BNBUSDT", Side: SideSell, Quantity: dec("2"), Price: dec("115")})
+	assert.NoError(t, err)
+	assertDecimal(t, "0.46", res.Commission)
+	assert.Equal(t, "USDT", res.CommissionAsset)
+
+	// a resting buy filled later pays the maker fee in the base asset
+	res, err = e.PlaceOrder(context.Background(), OrderRequest{Symbol: "BNBUSDT", Side: SideBuy, Quantity: dec("1"), Price: dec("110"), TimeInForce: TimeInForceGTC})
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusNew, res.Status)
+	assert.True(t, res.Commission.IsZero())
+
+	e.SetBook("BNBUSDT", nil, []PriceLevel{{Price: dec("110"), Quantity: dec("5")}})
+
+	res, err = e.QueryOrder(context.Background(), "BNBUSDT", res.OrderId)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusFilled, res.Status)
+	assertDecimal(t, "0.001", res.Commission)
+	assert.Equal(t, "BNB", res.CommissionAsset)
+
+	balances, err := e.Balances(context.Background())
+	assert.NoError(t, err)
+	assertDecimal(t, "0.999", balances[0].Free)
+	assertDecimal(t, "229.54", balances[1].Free)
+}
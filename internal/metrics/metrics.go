@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "trader"
+
+// Metrics measures the trading loop, components are measured by wrapping them
+type Metrics struct {
+	logger         logus.Logger
+	registry       *prometheus.Registry
+	watchDuration  prometheus.Histogram
+	watchErrors    prometheus.Counter
+	tickerDuration prometheus.Histogram
+	tickerErrors   prometheus.Counter
+	ordersCreated  *prometheus.CounterVec
+	orderQuantity  *prometheus.CounterVec
+	orderNotional  *prometheus.CounterVec
+}
+
+func NewMetrics(
+	logger logus.Logger,
+	tradeRepo trade.RepositoryInterface,
+) *Metrics {
+	m := &Metrics{
+		logger:   logger,
+		registry: prometheus.NewRegistry(),
+		watchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "watch_duration_seconds",
+			Help:      "Duration of evaluating active trades against tickers.",
+		}),
+		watchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "watch_errors_total",
+			Help:      "Watches which failed.",
+		}),
+		tickerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ticker_fetch_duration_seconds",
+			Help:      "Duration of fetching book tickers from the exchange.",
+		}),
+		tickerErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ticker_fetch_errors_total",
+			Help:      "Book ticker fetches which failed.",
+		}),
+		ordersCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_created_total",
+			Help:      "Orders placed on the exchange and stored.",
+		}, []string{"symbol", "side"}),
+		orderQuantity: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "order_quantity_total",
+			Help:      "Quantity of created orders in the base currency.",
+		}, []string{"symbol", "side"}),
+		orderNotional: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "order_notional_total",
+			Help:      "Quantity of created orders times their limit price, in the quote currency.",
+		}, []string{"symbol", "side"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.watchDuration,
+		m.watchErrors,
+		m.tickerDuration,
+		m.tickerErrors,
+		m.ordersCreated,
+		m.orderQuantity,
+		m.orderNotional,
+		newTradeCollector(logger, tradeRepo),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Watch measures a watch of the trader, having nothing to trade is not an error
+func (m *Metrics) Watch(watch func() error) error {
+	start := time.Now()
+	err := watch()
+
+	m.watchDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil && !errors.Is(err, trade.ErrNothingToTrade) {
+		m.watchErrors.Inc()
+	}
+
+	return err
+}
+
+// OrderBookTickerRepository measures fetches of repo
+func (m *Metrics) OrderBookTickerRepository(repo orderbookticker.RepositoryInterface) orderbookticker.RepositoryInterface {
+	return orderBookTickerRepository{repo, m}
+}
+
+// UnitOfWork counts orders created through uow once they are committed
+func (m *Metrics) UnitOfWork(uow trade.UnitOfWorkInterface) trade.UnitOfWorkInterface {
+	return unitOfWork{uow, m}
+}
+
+func (m *Metrics) observeTickerFetch(start time.Time, err error) {
+	m.tickerDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		m.tickerErrors.Inc()
+	}
+}
+
+func (m *Metrics) orderCreated(o order.Order) {
+	symbol, side := o.Symbol, string(o.Side)
+
+	m.ordersCreated.WithLabelValues(symbol, side).Inc()
+	m.orderQuantity.WithLabelValues(symbol, side).Add(o.OrderSize.InexactFloat64())
+	m.orderNotional.WithLabelValues(symbol, side).Add(o.OrderSize.Mul(o.OrderPrice).InexactFloat64())
+}
+
+type orderBookTickerRepository struct {
+	orderbookticker.RepositoryInterface
+	metrics *Metrics
+}
+
+func (r orderBookTickerRepository) FindOneBySymbol(ctx context.Context, symbol string) (*orderbookticker.OrderBookTicker, error) {
+	start := time.Now()
+	ticker, err := r.RepositoryInterface.FindOneBySymbol(ctx, symbol)
+
+	// unknown symbol is an answer of the exchange, not a failed fetch
+	if errors.Is(err, orderbookticker.ErrOrderBookTickerNotFound) {
+		r.metrics.observeTickerFetch(start, nil)
+	} else {
+		r.metrics.observeTickerFetch(start, err)
+	}
+
+	return ticker, err
+}
+
+func (r orderBookTickerRepository) FindAllBySymbols(ctx context.Context, symbols []string) (map[string]orderbookticker.Result, error) {
+	start := time.Now()
+	results, err := r.RepositoryInterface.FindAllBySymbols(ctx, symbols)
+	r.metrics.observeTickerFetch(start, err)
+
+	return results, err
+}
+
+type unitOfWork struct {
+	trade.UnitOfWorkInterface
+	metrics *Metrics
+}
+
+func (u unitOfWork) Do(fn func(tradeRepo trade.RepositoryInterface, orderRepo order.RepositoryInterface) error) error {
+	var created []order.Order
+
+	err := u.UnitOfWorkInterface.Do(func(tradeRepo trade.RepositoryInterface, orderRepo order.RepositoryInterface) error {
+		return fn(tradeRepo, orderRepository{orderRepo, &created})
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range created {
+		u.metrics.orderCreated(created[i])
+	}
+
+	return nil
+}
+
+// orderRepository remembers orders created in a unit of work
+type orderRepository struct {
+	order.RepositoryInterface
+	created *[]order.Order
+}
+
+func (r orderRepository) Create(o order.Order) error {
+	if err := r.RepositoryInterface.Create(o); err != nil {
+		return err
+	}
+
+	*r.created = append(*r.created, o)
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = &logus.TestLogger{}
+
+type tradeRepositoryStub struct {
+	trade.RepositoryInterface
+
+	trades []trade.Trade
+	err    error
+}
+
+func (r tradeRepositoryStub) FindAllActive(now time.Time) ([]trade.Trade, error) {
+	return r.trades, r.err
+}
+
+type orderBookTickerRepositoryStub struct {
+	orderbookticker.RepositoryInterface
+
+	err error
+}
+
+func (r orderBookTickerRepositoryStub) FindOneBySymbol(ctx context.Context, symbol string) (*orderbookticker.OrderBookTicker, error) {
+	return nil, r.err
+}
+
+func (r orderBookTickerRepositoryStub) FindAllBySymbols(ctx context.Context, symbols []string) (map[string]orderbookticker.Result, error) {
+	return nil, r.err
+}
+
+type orderRepositoryStub struct {
+	order.RepositoryInterface
+}
+
+func (r orderRepositoryStub) Create(o order.Order) error {
+	return nil
+}
+
+type unitOfWorkStub struct{}
+
+func (u unitOfWorkStub) Do(fn func(tradeRepo trade.RepositoryInterface, orderRepo order.RepositoryInterface) error) error {
+	return fn(nil, orderRepositoryStub{})
+}
+
+func TestMetrics_Watch(t *testing.T) {
+	m := NewMetrics(testLogger, tradeRepositoryStub{})
+
+	assert.NoError(t, m.Watch(func() error { return nil }))
+	assert.ErrorIs(t, m.Watch(func() error { return trade.ErrNothingToTrade }), trade.ErrNothingToTrade)
+	assert.Error(t, m.Watch(func() error { return errors.New("database is locked") }))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.watchErrors))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "trader_watch_duration_seconds_count 3")
+}
+
+func TestMetrics_OrderBookTickerRepository(t *testing.T) {
+	m := NewMetrics(testLogger, tradeRepositoryStub{})
+
+	_, _ = m.OrderBookTickerRepository(orderBookTickerRepositoryStub{}).FindAllBySymbols(context.Background(), []string{"BNBUSDT"})
+	_, _ = m.OrderBookTickerRepository(orderBookTickerRepositoryStub{err: orderbookticker.ErrOrderBookTickerNotFound}).FindOneBySymbol(context.Background(), "XRPUSDT")
+	_, _ = m.OrderBookTickerRepository(orderBookTickerRepositoryStub{err: errors.New("timeout")}).FindAllBySymbols(context.Background(), []string{"BNBUSDT"})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.tickerErrors))
+}
+
+func TestMetrics_UnitOfWork(t *testing.T) {
+	m := NewMetrics(testLogger, tradeRepositoryStub{})
+	uow := m.UnitOfWork(unitOfWorkStub{})
+
+	create := func(o order.Order, commitErr error) error {
+		return uow.Do(func(tradeRepo trade.RepositoryInterface, orderRepo order.RepositoryInterface) error {
+			if err := orderRepo.Create(o); err != nil {
+				return err
+			}
+
+			return commitErr
+		})
+	}
+
+	o := order.Order{Symbol: "BNBUSDT", Side: exchange.SideSell, OrderSize: decimal.RequireFromString("1.5"), OrderPrice: decimal.RequireFromString("100")}
+
+	require.NoError(t, create(o, nil))
+	require.NoError(t, create(o, nil))
+	// rolled back orders are not counted
+	require.Error(t, create(o, errors.New("database is locked")))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.ordersCreated.WithLabelValues("BNBUSDT", "SELL")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.orderQuantity.WithLabelValues("BNBUSDT", "SELL")))
+	assert.Equal(t, float64(300), testutil.ToFloat64(m.orderNotional.WithLabelValues("BNBUSDT", "SELL")))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	id := uuid.New()
+	m := NewMetrics(testLogger, tradeRepositoryStub{trades: []trade.Trade{
+		{ID: id, OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", OrderSizeLeft: decimal.RequireFromString("2.5")},
+		{ID: uuid.New(), OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", OrderSizeLeft: decimal.RequireFromString("1")},
+		{ID: uuid.New(), OrderSizeCurrency: "ETH", OrderPriceCurrency: "USDT", OrderSizeLeft: decimal.RequireFromString("1"), Side: exchange.SideBuy},
+	}})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `trader_active_trades{symbol="BNBUSDT"} 2`)
+	assert.Contains(t, body, `trader_active_trades{symbol="ETHUSDT"} 1`)
+	assert.Contains(t, body, `trader_trade_order_size_left{side="SELL",symbol="BNBUSDT",trade_id="`+id.String()+`"} 2.5`)
+	assert.Contains(t, body, "trader_watch_duration_seconds")
+	assert.Contains(t, body, "go_goroutines")
+
+	m = NewMetrics(testLogger, tradeRepositoryStub{err: errors.New("database is locked")})
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "trader_trade_scrape_errors_total 1")
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tradeCollector reads active trades on every scrape, so trades which are done or paused disappear from the metrics
+type tradeCollector struct {
+	logger        logus.Logger
+	tradeRepo     trade.RepositoryInterface
+	activeTrades  *prometheus.Desc
+	orderSizeLeft *prometheus.Desc
+	scrapeErrors  prometheus.Counter
+}
+
+func newTradeCollector(
+	logger logus.Logger,
+	tradeRepo trade.RepositoryInterface,
+) *tradeCollector {
+	return &tradeCollector{
+		logger:    logger,
+		tradeRepo: tradeRepo,
+		activeTrades: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_trades"),
+			"Active trades with size left.",
+			[]string{"symbol"}, nil,
+		),
+		orderSizeLeft: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "trade_order_size_left"),
+			"Size of an active trade which is not ordered yet, in the base currency.",
+			[]string{"trade_id", "symbol", "side"}, nil,
+		),
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "trade_scrape_errors_total",
+			Help:      "Scrapes which could not read active trades.",
+		}),
+	}
+}
+
+func (c *tradeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activeTrades
+	ch <- c.orderSizeLeft
+	c.scrapeErrors.Describe(ch)
+}
+
+func (c *tradeCollector) Collect(ch chan<- prometheus.Metric) {
+	defer c.scrapeErrors.Collect(ch)
+
+	trades, err := c.tradeRepo.FindAllActive(time.Now())
+	if err != nil {
+		c.logger.Error(err)
+		c.scrapeErrors.Inc()
+
+		return
+	}
+
+	active := map[string]int{}
+
+	for i := range trades {
+		symbol := trades[i].GetSymbol()
+		active[symbol]++
+
+		ch <- prometheus.MustNewConstMetric(
+			c.orderSizeLeft, prometheus.GaugeValue, trades[i].OrderSizeLeft.InexactFloat64(),
+			trades[i].ID.String(), symbol, string(trades[i].GetSide()),
+		)
+	}
+
+	for symbol, count := range active {
+		ch <- prometheus.MustNewConstMetric(c.activeTrades, prometheus.GaugeValue, float64(count), symbol)
+	}
+}
@@ -0,0 +1,127 @@
+package migration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Migration records a data migration which was applied, every migration runs once
+type Migration struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+type step struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+var steps = []step{
+	{name: "decimal_amounts", run: normalizeDecimalAmounts},
+}
+
+// Migrator brings the schema up to date and applies data migrations which were not applied yet
+type Migrator struct {
+	db     *gorm.DB
+	logger logus.Logger
+}
+
+func NewMigrator(
+	db *gorm.DB,
+	logger logus.Logger,
+) Migrator {
+	return Migrator{db, logger}
+}
+
+func (m Migrator) Migrate() error {
+	if err := m.db.AutoMigrate(&trade.Trade{}, &trade.Level{}, &order.Order{}, &Migration{}); err != nil {
+		m.logger.Error(err)
+
+		return err
+	}
+
+	for _, s := range steps {
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			var applied int64
+			if err := tx.Model(&Migration{}).Where("name = ?", s.name).Count(&applied).Error; err != nil {
+				return err
+			}
+
+			if applied > 0 {
+				return nil
+			}
+
+			m.logger.Debugf("MIGRATION %s", s.name)
+
+			if err := s.run(tx); err != nil {
+				return fmt.Errorf("migration %s: %w", s.name, err)
+			}
+
+			return tx.Create(&Migration{Name: s.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			m.logger.Error(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// amountScale is the number of decimal places the exchange uses for sizes and prices
+const amountScale = 8
+
+// decimalColumns lists amount columns which were stored as floats, by table and its primary key
+var decimalColumns = []struct {
+	table   string
+	key     string
+	columns []string
+}{
+	{table: "trades", key: "id", columns: []string{"order_size", "order_size_left", "order_price"}},
+	{table: "orders", key: "order_id", columns: []string{"order_size", "order_price", "executed_size", "cumulative_quote_qty", "allocated_size"}},
+}
+
+// normalizeDecimalAmounts rewrites amounts stored as floats. AutoMigrate turns the float columns into text, but
+// values keep float residues like 0.30000000000000004 or 1.0e-17, they are rounded to the exchange precision.
+func normalizeDecimalAmounts(tx *gorm.DB) error {
+	for _, t := range decimalColumns {
+		var rows []map[string]any
+		if err := tx.Table(t.table).Select(append([]string{t.key}, t.columns...)).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			values := map[string]any{}
+
+			for _, column := range t.columns {
+				if row[column] == nil {
+					continue
+				}
+
+				value, err := decimal.NewFromString(fmt.Sprint(row[column]))
+				if err != nil {
+					return fmt.Errorf("%s.%s of %v: %w", t.table, column, row[t.key], err)
+				}
+
+				values[column] = value.Round(amountScale).String()
+			}
+
+			if len(values) == 0 {
+				continue
+			}
+
+			if err := tx.Table(t.table).Where(t.key+" = ?", row[t.key]).Updates(values).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testLogger = &logus.TestLogger{}
+
+// floatTrade and floatOrder are the models as they were stored before amounts became decimals
+type floatTrade struct {
+	ID                 uuid.UUID `gorm:"primaryKey"`
+	CreatedAt          time.Time `gorm:"default:current_timestamp"`
+	UpdatedAt          time.Time `gorm:"default:current_timestamp"`
+	OrderSize          float64
+	OrderSizeLeft      float64
+	OrderSizeCurrency  string
+	OrderPrice         float64
+	OrderPriceCurrency string
+	Status             string `gorm:"default:ACTIVE;index"`
+}
+
+func (floatTrade) TableName() string {
+	return "trades"
+}
+
+type floatOrder struct {
+	OrderId            string    `gorm:"primaryKey"`
+	CreatedAt          time.Time `gorm:"default:current_timestamp"`
+	UpdatedAt          time.Time `gorm:"default:current_timestamp"`
+	TradeId            uuid.UUID
+	OrderSize          float64
+	OrderPrice         float64
+	ExecutedSize       float64
+	CumulativeQuoteQty float64
+	AllocatedSize      float64
+}
+
+func (floatOrder) TableName() string {
+	return "orders"
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	return db
+}
+
+func TestMigrator_Migrate_DecimalAmounts(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&floatTrade{}, &floatOrder{}))
+
+	tradeId := uuid.New()
+	require.NoError(t, db.Create(&floatTrade{
+		ID:                 tradeId,
+		OrderSize:          0.1 + 0.2,
+		OrderSizeLeft:      0.3 - 0.1 - 0.2 + 1e-17,
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         115.5,
+		OrderPriceCurrency: "USDT",
+	}).Error)
+	require.NoError(t, db.Create(&floatOrder{
+		OrderId:            "1",
+		TradeId:            tradeId,
+		OrderSize:          0.3,
+		OrderPrice:         115.5,
+		ExecutedSize:       0.1 + 0.2,
+		CumulativeQuoteQty: 34.65,
+		AllocatedSize:      0.3,
+	}).Error)
+
+	m := NewMigrator(db, testLogger)
+	require.NoError(t, m.Migrate())
+
+	var tr trade.Trade
+	require.NoError(t, db.First(&tr, "id = ?", tradeId).Error)
+	assert.Equal(t, "0.3", tr.OrderSize.String())
+	assert.Equal(t, "0", tr.OrderSizeLeft.String())
+	assert.Equal(t, "115.5", tr.OrderPrice.String())
+
+	var o order.Order
+	require.NoError(t, db.First(&o, "order_id = ?", "1").Error)
+	assert.Equal(t, "0.3", o.ExecutedSize.String())
+	assert.Equal(t, "34.65", o.CumulativeQuoteQty.String())
+
+	// trade with nothing left is not active anymore
+	trades, err := trade.NewRepository(db, testLogger).FindAllActive(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, trades)
+
+	// data migrations run once
+	require.NoError(t, db.Model(&trade.Trade{}).Where("id = ?", tradeId).Update("order_size_left", "0.30000000000000004").Error)
+	require.NoError(t, m.Migrate())
+	require.NoError(t, db.First(&tr, "id = ?", tradeId).Error)
+	assert.Equal(t, "0.30000000000000004", tr.OrderSizeLeft.String())
+}
@@ -1,16 +1,76 @@
 package order
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/beng90/trader/internal/exchange"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type Order struct {
-	OrderId    string    `gorm:"primaryKey"`
-	CreatedAt  time.Time `gorm:"default:current_timestamp"`
-	UpdatedAt  time.Time `gorm:"default:current_timestamp"`
-	TradeId    uuid.UUID
-	OrderSize  float64
-	OrderPrice float64
+	OrderId       string    `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"default:current_timestamp"`
+	UpdatedAt     time.Time `gorm:"default:current_timestamp"`
+	TradeId       uuid.UUID
+	ClientOrderId string
+	Symbol        string
+	Side          exchange.Side
+	// Trigger is the trade condition the order was placed on, ReferencePrice is the price of the condition, the
+	// take-profit level, stop or trailing stop price. It is zero for orders placed before it was recorded.
+	Trigger            string
+	ReferencePrice     decimal.Decimal      `gorm:"type:text;not null;default:'0'"`
+	Status             exchange.OrderStatus `gorm:"index"`
+	OrderSize          decimal.Decimal      `gorm:"type:text"`
+	OrderPrice         decimal.Decimal      `gorm:"type:text"`
+	ExecutedSize       decimal.Decimal      `gorm:"type:text"`
+	CumulativeQuoteQty decimal.Decimal      `gorm:"type:text"`
+	// Fee is charged by the exchange in FeeAsset, the asset is empty while the fee is not known
+	Fee      decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	FeeAsset string
+	// OtherFees are fees charged in other assets than FeeAsset
+	OtherFees Fees `gorm:"type:text"`
+	// AllocationPolicy and AllocatedSize tell how much of the ticker quantity was given to the trade
+	AllocationPolicy string
+	AllocatedSize    decimal.Decimal `gorm:"type:text"`
+	// TransactedAt is the time the exchange accepted the order
+	TransactedAt time.Time
+	// ExchangeUpdatedAt is the time of the last change reported by the exchange
+	ExchangeUpdatedAt time.Time
+	// ExchangeResponse is the raw exchange response the order was created from
+	ExchangeResponse string
+}
+
+// Fees are amounts of fees by asset, they are stored as JSON
+type Fees map[string]decimal.Decimal
+
+func (f Fees) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+
+	res, err := json.Marshal(map[string]decimal.Decimal(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(res), nil
+}
+
+func (f *Fees) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*f = nil
+
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	case []byte:
+		return json.Unmarshal(v, f)
+	}
+
+	return fmt.Errorf("fees cannot be scanned from %T", value)
 }
@@ -1,12 +1,35 @@
 package order
 
 import (
+	"errors"
+
+	"github.com/beng90/trader/internal/exchange"
 	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrNotOpen is returned by UpdateOpen when the order was closed since it was read
+var ErrNotOpen = errors.New("order was closed since it was read")
+
 type RepositoryInterface interface {
 	Create(order Order) error
+	Update(order Order) error
+	UpdateOpen(order Order) error
+	FindAll(filter Filter) ([]Order, error)
+	Count(filter Filter) (int64, error)
+	FindAllOpen() ([]Order, error)
+	FindOpenByTradeId(tradeId uuid.UUID) ([]Order, error)
+}
+
+// Filter narrows FindAll and Count, empty fields match every order
+type Filter struct {
+	TradeId uuid.UUID
+	Symbol  string
+	Status  exchange.OrderStatus
+	// Limit and Offset page FindAll results, zero Limit returns all of them
+	Limit  int
+	Offset int
 }
 
 type Repository struct {
@@ -30,3 +53,110 @@ func (r Repository) Create(order Order) error {
 
 	return nil
 }
+
+func (r Repository) Update(order Order) error {
+	if result := r.db.Save(&order); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return result.Error
+	}
+
+	return nil
+}
+
+// UpdateOpen stores the order only while it is open in the database, otherwise ErrNotOpen is returned. An order
+// closed by the reconciler and the expirer at once is stored by the first of them only.
+func (r Repository) UpdateOpen(order Order) error {
+	result := r.db.Model(&order).Where("status IN ?", OpenStatuses()).Select("*").Updates(&order)
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotOpen
+	}
+
+	return nil
+}
+
+// FindAll returns orders matching filter from the oldest one
+func (r Repository) FindAll(filter Filter) ([]Order, error) {
+	var res []Order
+
+	query := r.filter(filter).Order("created_at, order_id")
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	if result := query.Find(&res); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
+	return res, nil
+}
+
+// Count returns the number of orders matching filter regardless of its limit
+func (r Repository) Count(filter Filter) (int64, error) {
+	var res int64
+
+	if result := r.filter(filter).Count(&res); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return 0, result.Error
+	}
+
+	return res, nil
+}
+
+func (r Repository) filter(filter Filter) *gorm.DB {
+	query := r.db.Model(&Order{})
+
+	if filter.TradeId != uuid.Nil {
+		query = query.Where("trade_id = ?", filter.TradeId)
+	}
+
+	if filter.Symbol != "" {
+		query = query.Where("symbol = ?", filter.Symbol)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	return query
+}
+
+// FindAllOpen returns orders which can still be executed by the exchange
+func (r Repository) FindAllOpen() ([]Order, error) {
+	var res []Order
+
+	if result := r.db.Find(&res, "status IN ?", OpenStatuses()); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
+	return res, nil
+}
+
+func (r Repository) FindOpenByTradeId(tradeId uuid.UUID) ([]Order, error) {
+	var res []Order
+
+	if result := r.db.Find(&res, "trade_id = ? AND status IN ?", tradeId, OpenStatuses()); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
+	return res, nil
+}
+
+// OpenStatuses are statuses of orders which can still be executed by the exchange
+func OpenStatuses() []exchange.OrderStatus {
+	return []exchange.OrderStatus{exchange.OrderStatusNew, exchange.OrderStatusPartiallyFilled}
+}
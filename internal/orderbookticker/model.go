@@ -1,9 +1,17 @@
 package orderbookticker
 
+import "github.com/shopspring/decimal"
+
 type OrderBookTicker struct {
 	Symbol   string
-	BidPrice float64
-	BidQty   float64
-	AskPrice float64
-	AksQty   float64
+	BidPrice decimal.Decimal
+	BidQty   decimal.Decimal
+	AskPrice decimal.Decimal
+	AksQty   decimal.Decimal
+}
+
+// Result is the outcome of looking up a single symbol in a batch, either Ticker or Err is set
+type Result struct {
+	Ticker *OrderBookTicker
+	Err    error
 }
@@ -3,9 +3,8 @@ package orderbookticker
 import (
 	"context"
 	"errors"
-	"strconv"
 
-	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/internal/exchange"
 	"github.com/beng90/trader/pkg/logus"
 )
 
@@ -13,65 +12,65 @@ var ErrOrderBookTickerNotFound = errors.New("cannot find order book ticker")
 
 type RepositoryInterface interface {
 	FindOneBySymbol(ctx context.Context, symbol string) (*OrderBookTicker, error)
+	// FindAllBySymbols looks up all symbols at once, every symbol has its own result. The error is returned when
+	// the lookup failed as a whole.
+	FindAllBySymbols(ctx context.Context, symbols []string) (map[string]Result, error)
 }
 
 type Repository struct {
-	client *binance.Client
-	logger logus.Logger
+	exchange exchange.Exchange
+	logger   logus.Logger
 }
 
 func NewRepository(
-	client *binance.Client,
+	exchange exchange.Exchange,
 	logger logus.Logger,
 ) Repository {
-	return Repository{client, logger}
+	return Repository{exchange, logger}
 }
 
 func (r Repository) FindOneBySymbol(ctx context.Context, symbol string) (*OrderBookTicker, error) {
-	results, err := r.client.NewListBookTickersService().
-		Symbol(symbol).
-		Do(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(results) == 0 {
+	ticker, err := r.exchange.BookTicker(ctx, symbol)
+	if errors.Is(err, exchange.ErrSymbolNotFound) {
 		return nil, ErrOrderBookTickerNotFound
 	}
 
-	bidPrice, err := strconv.ParseFloat(results[0].BidPrice, 64)
 	if err != nil {
-		r.logger.Error(err)
-
 		return nil, err
 	}
 
-	bidQty, err := strconv.ParseFloat(results[0].BidQuantity, 64)
-	if err != nil {
-		r.logger.Error(err)
-
-		return nil, err
-	}
+	return toOrderBookTicker(*ticker), nil
+}
 
-	askPrice, err := strconv.ParseFloat(results[0].AskPrice, 64)
+// FindAllBySymbols fetches tickers of all symbols in one request
+func (r Repository) FindAllBySymbols(ctx context.Context, symbols []string) (map[string]Result, error) {
+	tickers, err := r.exchange.BookTickers(ctx, symbols...)
 	if err != nil {
 		r.logger.Error(err)
 
 		return nil, err
 	}
 
-	askQty, err := strconv.ParseFloat(results[0].AskQuantity, 64)
-	if err != nil {
-		r.logger.Error(err)
+	results := make(map[string]Result, len(symbols))
+	for _, symbol := range symbols {
+		results[symbol] = Result{Err: ErrOrderBookTickerNotFound}
+	}
 
-		return nil, err
+	for _, ticker := range tickers {
+		if _, ok := results[ticker.Symbol]; ok {
+			results[ticker.Symbol] = Result{Ticker: toOrderBookTicker(ticker)}
+		}
 	}
 
+	return results, nil
+}
+
+func toOrderBookTicker(ticker exchange.BookTicker) *OrderBookTicker {
 	return &OrderBookTicker{
-		Symbol:   symbol,
-		BidPrice: bidPrice,
-		BidQty:   bidQty,
-		AskPrice: askPrice,
-		AksQty:   askQty,
-	}, nil
+		Symbol:   ticker.Symbol,
+		BidPrice: ticker.BidPrice,
+		BidQty:   ticker.BidQty,
+		AskPrice: ticker.AskPrice,
+		AksQty:   ticker.AskQty,
+	}
 }
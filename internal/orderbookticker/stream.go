@@ -0,0 +1,340 @@
+package orderbookticker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = time.Minute
+	// streamUpdatesSize is how many updates can wait for the consumer before next ones are dropped
+	streamUpdatesSize = 1024
+)
+
+type streamMessage struct {
+	Stream string                    `json:"stream"`
+	Data   binance.WsBookTickerEvent `json:"data"`
+}
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Id     int64    `json:"id"`
+}
+
+// Stream keeps the latest book ticker of every subscribed symbol, it is fed by the binance @bookTicker
+// combined stream. Symbols are subscribed as they are looked up.
+type Stream struct {
+	baseURL string
+	logger  logus.Logger
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu         sync.RWMutex
+	tickers    map[string]OrderBookTicker
+	symbols    map[string]bool
+	conn       *websocket.Conn
+	requestId  int64
+	subscribed chan struct{}
+	updates    chan string
+}
+
+func NewStream(
+	baseURL string,
+	logger logus.Logger,
+) *Stream {
+	return &Stream{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		logger:     logger,
+		minBackoff: streamMinBackoff,
+		maxBackoff: streamMaxBackoff,
+		tickers:    map[string]OrderBookTicker{},
+		symbols:    map[string]bool{},
+		subscribed: make(chan struct{}, 1),
+		updates:    make(chan string, streamUpdatesSize),
+	}
+}
+
+// FindOneBySymbol returns the latest ticker received for symbol, symbol is subscribed when it is not yet
+func (s *Stream) FindOneBySymbol(ctx context.Context, symbol string) (*OrderBookTicker, error) {
+	s.Subscribe(symbol)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ticker, ok := s.tickers[symbol]
+	if !ok {
+		return nil, ErrOrderBookTickerNotFound
+	}
+
+	return &ticker, nil
+}
+
+// FindAllBySymbols returns the latest tickers received for symbols, missing symbols are subscribed
+func (s *Stream) FindAllBySymbols(ctx context.Context, symbols []string) (map[string]Result, error) {
+	s.Subscribe(symbols...)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make(map[string]Result, len(symbols))
+
+	for _, symbol := range symbols {
+		ticker, ok := s.tickers[symbol]
+		if !ok {
+			results[symbol] = Result{Err: ErrOrderBookTickerNotFound}
+
+			continue
+		}
+
+		results[symbol] = Result{Ticker: &ticker}
+	}
+
+	return results, nil
+}
+
+// Updates receives symbol of every ticker update
+func (s *Stream) Updates() <-chan string {
+	return s.updates
+}
+
+// Subscribe adds symbols to the stream, new ones are sent to the open connection right away
+func (s *Stream) Subscribe(symbols ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var streams []string
+
+	for _, symbol := range symbols {
+		if s.symbols[symbol] {
+			continue
+		}
+
+		s.symbols[symbol] = true
+		streams = append(streams, streamName(symbol))
+	}
+
+	if len(streams) == 0 {
+		return
+	}
+
+	if s.conn != nil {
+		s.requestId++
+
+		err := s.conn.WriteJSON(streamRequest{Method: "SUBSCRIBE", Params: streams, Id: s.requestId})
+		if err != nil {
+			// read loop fails on the same connection as well and reconnects with all symbols
+			s.logger.Error(err)
+		}
+	}
+
+	select {
+	case s.subscribed <- struct{}{}:
+	default:
+	}
+}
+
+// Run keeps the stream connected until ctx is done, lost connections are retried with exponential backoff
+func (s *Stream) Run(ctx context.Context) error {
+	backoff := s.minBackoff
+
+	for {
+		streams := s.streams()
+		if len(streams) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.subscribed:
+				continue
+			}
+		}
+
+		received, err := s.listen(ctx, streams)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if received {
+			backoff = s.minBackoff
+		}
+
+		s.logger.Error(fmt.Errorf("book ticker stream disconnected, reconnecting in %s: %w", backoff, err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// listen reads tickers from a single connection until it fails, received tells if any ticker came through
+func (s *Stream) listen(ctx context.Context, streams []string) (received bool, err error) {
+	url := fmt.Sprintf("%s/stream?streams=%s", s.baseURL, strings.Join(streams, "/"))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	err = s.subscribeMissing(conn, streams)
+	if err == nil {
+		s.conn = conn
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		_ = conn.Close()
+
+		return false, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		_ = conn.Close()
+	}()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// tickers are not refreshed without connection, nothing should be traded on them
+		s.conn = nil
+		s.tickers = map[string]OrderBookTicker{}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+
+		var msg streamMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			s.logger.Error(err)
+
+			continue
+		}
+
+		// subscription confirmations have no stream
+		if msg.Stream == "" {
+			continue
+		}
+
+		ticker, err := toStreamOrderBookTicker(msg.Data)
+		if err != nil {
+			s.logger.Error(err)
+
+			continue
+		}
+
+		received = true
+
+		s.mu.Lock()
+		s.tickers[ticker.Symbol] = *ticker
+		s.mu.Unlock()
+
+		select {
+		case s.updates <- ticker.Symbol:
+		default:
+			s.logger.Debugf("book ticker update of %s dropped, consumer is too slow", ticker.Symbol)
+		}
+	}
+}
+
+// subscribeMissing sends symbols subscribed while the connection was being opened, s.mu must be held
+func (s *Stream) subscribeMissing(conn *websocket.Conn, connected []string) error {
+	known := map[string]bool{}
+	for _, stream := range connected {
+		known[stream] = true
+	}
+
+	var missing []string
+
+	for symbol := range s.symbols {
+		if !known[streamName(symbol)] {
+			missing = append(missing, streamName(symbol))
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	s.requestId++
+
+	return conn.WriteJSON(streamRequest{Method: "SUBSCRIBE", Params: missing, Id: s.requestId})
+}
+
+func (s *Stream) streams() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	streams := make([]string, 0, len(s.symbols))
+	for symbol := range s.symbols {
+		streams = append(streams, streamName(symbol))
+	}
+
+	sort.Strings(streams)
+
+	return streams
+}
+
+func streamName(symbol string) string {
+	return strings.ToLower(symbol) + "@bookTicker"
+}
+
+func toStreamOrderBookTicker(e binance.WsBookTickerEvent) (*OrderBookTicker, error) {
+	bidPrice, err := decimal.NewFromString(e.BestBidPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	bidQty, err := decimal.NewFromString(e.BestBidQty)
+	if err != nil {
+		return nil, err
+	}
+
+	askPrice, err := decimal.NewFromString(e.BestAskPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	askQty, err := decimal.NewFromString(e.BestAskQty)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderBookTicker{
+		Symbol:   e.Symbol,
+		BidPrice: bidPrice,
+		BidQty:   bidQty,
+		AskPrice: askPrice,
+		AksQty:   askQty,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package orderbookticker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/internal/exchange/binancetest"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStream(t *testing.T) (*Stream, *binancetest.StreamServer) {
+	server := binancetest.NewStreamServer()
+	t.Cleanup(server.Close)
+
+	stream := NewStream(server.WsURL(), logus.NewTestLogger())
+	stream.minBackoff = time.Millisecond
+	stream.maxBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go func() {
+		_ = stream.Run(ctx)
+	}()
+
+	return stream, server
+}
+
+func waitForConnection(t *testing.T, server *binancetest.StreamServer) {
+	select {
+	case <-server.Connected():
+	case <-time.After(time.Second):
+		t.Fatal("stream did not connect")
+	}
+}
+
+func waitForUpdate(t *testing.T, stream *Stream) string {
+	select {
+	case symbol := <-stream.Updates():
+		return symbol
+	case <-time.After(time.Second):
+		t.Fatal("no ticker update received")
+	}
+
+	return ""
+}
+
+func TestStream_FindOneBySymbol(t *testing.T) {
+	stream, server := newTestStream(t)
+
+	// first lookup subscribes the symbol, there is no ticker yet
+	_, err := stream.FindOneBySymbol(context.Background(), "BNBUSDT")
+	assert.ErrorIs(t, err, ErrOrderBookTickerNotFound)
+
+	waitForConnection(t, server)
+	assert.True(t, server.Subscribed("bnbusdt@bookTicker"))
+
+	server.PublishBookTicker(binance.WsBookTickerEvent{
+		UpdateID:     1,
+		Symbol:       "BNBUSDT",
+		BestBidPrice: "115.5",
+		BestBidQty:   "10",
+		BestAskPrice: "115.6",
+		BestAskQty:   "2",
+	})
+
+	assert.Equal(t, "BNBUSDT", waitForUpdate(t, stream))
+
+	ticker, err := stream.FindOneBySymbol(context.Background(), "BNBUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "BNBUSDT", ticker.Symbol)
+	assert.Equal(t, "115.5", ticker.BidPrice.String())
+	assert.Equal(t, "10", ticker.BidQty.String())
+	assert.Equal(t, "115.6", ticker.AskPrice.String())
+	assert.Equal(t, "2", ticker.AksQty.String())
+}
+
+func TestStream_FindAllBySymbols(t *testing.T) {
+	stream, server := newTestStream(t)
+
+	results, err := stream.FindAllBySymbols(context.Background(), []string{"BNBUSDT", "ETHUSDT"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, results["BNBUSDT"].Err, ErrOrderBookTickerNotFound)
+	assert.ErrorIs(t, results["ETHUSDT"].Err, ErrOrderBookTickerNotFound)
+
+	waitForConnection(t, server)
+	assert.True(t, server.Subscribed("bnbusdt@bookTicker"))
+	assert.True(t, server.Subscribed("ethusdt@bookTicker"))
+
+	server.PublishBookTicker(binance.WsBookTickerEvent{
+		Symbol:       "BNBUSDT",
+		BestBidPrice: "115.5",
+		BestBidQty:   "10",
+		BestAskPrice: "115.6",
+		BestAskQty:   "2",
+	})
+
+	assert.Equal(t, "BNBUSDT", waitForUpdate(t, stream))
+
+	results, err = stream.FindAllBySymbols(context.Background(), []string{"BNBUSDT", "ETHUSDT"})
+	require.NoError(t, err)
+	assert.NoError(t, results["BNBUSDT"].Err)
+	assert.Equal(t, "115.5", results["BNBUSDT"].Ticker.BidPrice.String())
+	assert.ErrorIs(t, results["ETHUSDT"].Err, ErrOrderBookTickerNotFound)
+}
+
+func TestStream_SubscribeWhileConnected(t *testing.T) {
+	stream, server := newTestStream(t)
+
+	stream.Subscribe("BNBUSDT")
+	waitForConnection(t, server)
+
+	stream.Subscribe("ETHUSDT")
+
+	assert.Eventually(t, func() bool {
+		return server.Subscribed("ethusdt@bookTicker")
+	}, time.Second, time.Millisecond)
+
+	server.PublishBookTicker(binance.WsBookTickerEvent{
+		Symbol:       "ETHUSDT",
+		BestBidPrice: "1500",
+		BestBidQty:   "1",
+		BestAskPrice: "1501",
+		BestAskQty:   "1",
+	})
+
+	assert.Equal(t, "ETHUSDT", waitForUpdate(t, stream))
+}
+
+func TestStream_Reconnect(t *testing.T) {
+	stream, server := newTestStream(t)
+
+	stream.Subscribe("BNBUSDT")
+	waitForConnection(t, server)
+
+	server.PublishBookTicker(binance.WsBookTickerEvent{
+		Symbol:       "BNBUSDT",
+		BestBidPrice: "115",
+		BestBidQty:   "1",
+		BestAskPrice: "116",
+		BestAskQty:   "1",
+	})
+	waitForUpdate(t, stream)
+
+	server.DropConnections()
+	waitForConnection(t, server)
+
+	assert.Equal(t, 2, server.Connections())
+	assert.True(t, server.Subscribed("bnbusdt@bookTicker"))
+
+	// tickers from the lost connection are not served
+	assert.Eventually(t, func() bool {
+		_, err := stream.FindOneBySymbol(context.Background(), "BNBUSDT")
+
+		return err == ErrOrderBookTickerNotFound
+	}, time.Second, time.Millisecond)
+
+	server.PublishBookTicker(binance.WsBookTickerEvent{
+		Symbol:       "BNBUSDT",
+		BestBidPrice: "117",
+		BestBidQty:   "3",
+		BestAskPrice: "118",
+		BestAskQty:   "1",
+	})
+	waitForUpdate(t, stream)
+
+	ticker, err := stream.FindOneBySymbol(context.Background(), "BNBUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, "117", ticker.BidPrice.String())
+}
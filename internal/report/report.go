@@ -0,0 +1,352 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Reporter tells how stored trades were executed, it reads trades and orders and changes nothing
+type Reporter struct {
+	logger    logus.Logger
+	tradeRepo trade.RepositoryInterface
+	orderRepo order.RepositoryInterface
+}
+
+func NewReporter(
+	logger logus.Logger,
+	tradeRepo trade.RepositoryInterface,
+	orderRepo order.RepositoryInterface,
+) Reporter {
+	return Reporter{
+		logger:    logger,
+		tradeRepo: tradeRepo,
+		orderRepo: orderRepo,
+	}
+}
+
+// Filter narrows trades of a report, zero fields match every trade
+type Filter struct {
+	Symbol string
+	// From and To limit the time trades were created at, To is excluded
+	From time.Time
+	To   time.Time
+}
+
+// Report has a row for every trade from the oldest one, totals of trades with the same symbol and side and the
+// overall total of all trades
+type Report struct {
+	Trades  []TradeReport
+	Totals  []Total
+	Overall Overall
+}
+
+type TradeReport struct {
+	TradeId   uuid.UUID
+	Symbol    string
+	Side      exchange.Side
+	Status    trade.Status
+	CreatedAt time.Time
+	// CompletedAt is the time of the last fill of a trade whose whole size was filled, nil until then
+	CompletedAt    *time.Time
+	TimeToComplete time.Duration
+	Size           decimal.Decimal
+	Filled         decimal.Decimal
+	Remaining      decimal.Decimal
+	OrderPrice     decimal.Decimal
+	// AveragePrice is zero until the trade is filled
+	AveragePrice decimal.Decimal
+	// ReferencePrice is the average price of the conditions which triggered the fills, the take-profit level,
+	// stop or trailing stop price of every order, OrderPrice for orders which did not record it
+	ReferencePrice decimal.Decimal
+	// SlippagePercent is how much worse than ReferencePrice the trade was filled on average, it is negative when
+	// the trade was filled better and zero for trades without fills or reference prices
+	SlippagePercent decimal.Decimal
+	Orders          int
+	Proceeds        trade.Proceeds
+}
+
+// Total sums trades of a symbol and side, prices of different symbols cannot be summed
+type Total struct {
+	Symbol          string
+	Side            exchange.Side
+	Trades          int
+	Completed       int
+	Orders          int
+	Size            decimal.Decimal
+	Filled          decimal.Decimal
+	Remaining       decimal.Decimal
+	AveragePrice    decimal.Decimal
+	SlippagePercent decimal.Decimal
+	// AverageTimeToComplete is the mean of completed trades
+	AverageTimeToComplete time.Duration
+	Proceeds              trade.Proceeds
+}
+
+// Overall sums all trades of the report. Sizes and prices of different symbols cannot be summed, slippage,
+// proceeds and fees are given only when all trades are quoted in the same currency.
+type Overall struct {
+	Trades    int
+	Completed int
+	Orders    int
+	// QuoteCurrency is the currency all trades are quoted in, it is empty when they are quoted in several
+	QuoteCurrency         string
+	SlippagePercent       decimal.Decimal
+	AverageTimeToComplete time.Duration
+	// Gross and Net are what sells received less what buys paid, they are negative when buys paid more
+	Gross       decimal.Decimal
+	Fees        decimal.Decimal
+	Net         decimal.Decimal
+	OtherFees   map[string]decimal.Decimal `json:",omitempty"`
+	UnknownFees int                        `json:",omitempty"`
+}
+
+// Report reports trades matching filter with all their orders
+func (r Reporter) Report(filter Filter) (*Report, error) {
+	trades, err := r.tradeRepo.FindAll(trade.Filter{Symbol: filter.Symbol})
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := r.orderRepo.FindAll(order.Filter{Symbol: filter.Symbol})
+	if err != nil {
+		return nil, err
+	}
+
+	byTrade := map[uuid.UUID][]order.Order{}
+	for _, o := range orders {
+		byTrade[o.TradeId] = append(byTrade[o.TradeId], o)
+	}
+
+	res := &Report{}
+	totals := map[string]*total{}
+	overall := &total{}
+	quotes := map[string]bool{}
+
+	for _, t := range trades {
+		// creation times are compared here, the database keeps them as text in the zone they were written in
+		if !filter.From.IsZero() && t.CreatedAt.Before(filter.From) {
+			continue
+		}
+
+		if !filter.To.IsZero() && !t.CreatedAt.Before(filter.To) {
+			continue
+		}
+
+		tradeReport, priced := newTradeReport(t, byTrade[t.ID])
+		res.Trades = append(res.Trades, tradeReport)
+
+		key := t.GetSymbol() + " " + string(t.GetSide())
+		if totals[key] == nil {
+			totals[key] = &total{Total: Total{Symbol: t.GetSymbol(), Side: t.GetSide()}}
+		}
+
+		totals[key].add(tradeReport, priced)
+		overall.add(tradeReport, priced)
+		quotes[t.OrderPriceCurrency] = true
+	}
+
+	for _, t := range totals {
+		res.Totals = append(res.Totals, t.result())
+	}
+
+	res.Overall = newOverall(overall, quotes)
+
+	sort.Slice(res.Totals, func(i, j int) bool {
+		if res.Totals[i].Symbol != res.Totals[j].Symbol {
+			return res.Totals[i].Symbol < res.Totals[j].Symbol
+		}
+
+		return res.Totals[i].Side < res.Totals[j].Side
+	})
+
+	return res, nil
+}
+
+// priced are fills of orders with a reference price, reference is what they would be worth at it
+type priced struct {
+	reference decimal.Decimal
+	gross     decimal.Decimal
+}
+
+// newTradeReport sums orders of the trade, every fill is compared to the reference price of its order
+func newTradeReport(t trade.Trade, orders []order.Order) (TradeReport, priced) {
+	r := TradeReport{
+		TradeId:    t.ID,
+		Symbol:     t.GetSymbol(),
+		Side:       t.GetSide(),
+		Status:     t.Status,
+		CreatedAt:  t.CreatedAt,
+		Size:       t.OrderSize,
+		OrderPrice: t.OrderPrice,
+		Orders:     len(orders),
+		Proceeds:   trade.NewProceeds(t, orders),
+	}
+
+	var lastFill time.Time
+
+	p := priced{}
+	pricedFilled := decimal.Zero
+
+	for _, o := range orders {
+		if !o.ExecutedSize.IsPositive() {
+			continue
+		}
+
+		r.Filled = r.Filled.Add(o.ExecutedSize)
+
+		if o.ExchangeUpdatedAt.After(lastFill) {
+			lastFill = o.ExchangeUpdatedAt
+		}
+
+		reference := o.ReferencePrice
+		if !reference.IsPositive() {
+			reference = t.OrderPrice
+		}
+
+		if reference.IsPositive() {
+			p.reference = p.reference.Add(o.ExecutedSize.Mul(reference))
+			p.gross = p.gross.Add(o.CumulativeQuoteQty)
+			pricedFilled = pricedFilled.Add(o.ExecutedSize)
+		}
+	}
+
+	r.Remaining = decimal.Max(r.Size.Sub(r.Filled), decimal.Zero)
+
+	if !r.Filled.IsPositive() {
+		return r, p
+	}
+
+	r.AveragePrice = r.Proceeds.Gross.Div(r.Filled)
+
+	if !r.Remaining.IsPositive() {
+		r.CompletedAt = &lastFill
+		r.TimeToComplete = lastFill.Sub(t.CreatedAt)
+	}
+
+	if pricedFilled.IsPositive() {
+		r.ReferencePrice = p.reference.Div(pricedFilled)
+		r.SlippagePercent = slippage(t.GetSide(), p.gross, p.reference)
+	}
+
+	return r, p
+}
+
+// slippage is how much worse in percent gross was than reference, sells get less and buys pay more
+func slippage(side exchange.Side, gross decimal.Decimal, reference decimal.Decimal) decimal.Decimal {
+	if !reference.IsPositive() {
+		return decimal.Zero
+	}
+
+	cost := reference.Sub(gross)
+	if side == exchange.SideBuy {
+		cost = gross.Sub(reference)
+	}
+
+	return cost.Div(reference).Mul(decimal.NewFromInt(100))
+}
+
+// total keeps the sums a Total is made of, slippage is weighted by what the fills would be worth at their
+// reference prices. Slippage of buys and sells is weighted alike as both are positive when worse.
+type total struct {
+	Total
+	priced         priced
+	buys           priced
+	timeToComplete time.Duration
+	// gross and net are received by sells less paid by buys
+	gross decimal.Decimal
+	net   decimal.Decimal
+}
+
+func (t *total) add(r TradeReport, p priced) {
+	t.Trades++
+	t.Orders += r.Orders
+	t.Size = t.Size.Add(r.Size)
+	t.Filled = t.Filled.Add(r.Filled)
+	t.Remaining = t.Remaining.Add(r.Remaining)
+
+	t.Proceeds.Gross = t.Proceeds.Gross.Add(r.Proceeds.Gross)
+	t.Proceeds.Fees = t.Proceeds.Fees.Add(r.Proceeds.Fees)
+	t.Proceeds.Net = t.Proceeds.Net.Add(r.Proceeds.Net)
+	t.Proceeds.UnknownFees += r.Proceeds.UnknownFees
+
+	for asset, fee := range r.Proceeds.OtherFees {
+		if t.Proceeds.OtherFees == nil {
+			t.Proceeds.OtherFees = map[string]decimal.Decimal{}
+		}
+
+		t.Proceeds.OtherFees[asset] = t.Proceeds.OtherFees[asset].Add(fee)
+	}
+
+	if r.Side == exchange.SideBuy {
+		t.buys.reference = t.buys.reference.Add(p.reference)
+		t.buys.gross = t.buys.gross.Add(p.gross)
+		t.gross = t.gross.Sub(r.Proceeds.Gross)
+		t.net = t.net.Sub(r.Proceeds.Net)
+	} else {
+		t.priced.reference = t.priced.reference.Add(p.reference)
+		t.priced.gross = t.priced.gross.Add(p.gross)
+		t.gross = t.gross.Add(r.Proceeds.Gross)
+		t.net = t.net.Add(r.Proceeds.Net)
+	}
+
+	if r.CompletedAt != nil {
+		t.Completed++
+		t.timeToComplete += r.TimeToComplete
+	}
+}
+
+func (t *total) result() Total {
+	res := t.Total
+
+	if res.Filled.IsPositive() {
+		res.AveragePrice = res.Proceeds.Gross.Div(res.Filled)
+	}
+
+	// sells get less and buys pay more than the reference when worse
+	cost := t.priced.reference.Sub(t.priced.gross).Add(t.buys.gross.Sub(t.buys.reference))
+	if reference := t.priced.reference.Add(t.buys.reference); reference.IsPositive() {
+		res.SlippagePercent = cost.Div(reference).Mul(decimal.NewFromInt(100))
+	}
+
+	if res.Completed > 0 {
+		res.AverageTimeToComplete = t.timeToComplete / time.Duration(res.Completed)
+	}
+
+	return res
+}
+
+// newOverall keeps of the total of all trades what can be summed across symbols, quotes are their currencies
+func newOverall(overall *total, quotes map[string]bool) Overall {
+	t := overall.result()
+
+	res := Overall{
+		Trades:                t.Trades,
+		Completed:             t.Completed,
+		Orders:                t.Orders,
+		AverageTimeToComplete: t.AverageTimeToComplete,
+	}
+
+	if len(quotes) != 1 {
+		return res
+	}
+
+	for quote := range quotes {
+		res.QuoteCurrency = quote
+	}
+
+	res.SlippagePercent = t.SlippagePercent
+	res.Gross = overall.gross
+	res.Fees = t.Proceeds.Fees
+	res.Net = overall.net
+	res.OtherFees = t.Proceeds.OtherFees
+	res.UnknownFees = t.Proceeds.UnknownFees
+
+	return res
+}
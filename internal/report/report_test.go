@@ -0,0 +1,169 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/migration"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/trade"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testLogger = &logus.TestLogger{}
+
+func dec(v string) decimal.Decimal {
+	return decimal.RequireFromString(v)
+}
+
+func newTestReporter(t *testing.T) (Reporter, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, migration.NewMigrator(db, testLogger).Migrate())
+
+	return NewReporter(testLogger, trade.NewRepository(db, testLogger), order.NewRepository(db, testLogger)), db
+}
+
+func TestReporter_Report(t *testing.T) {
+	reporter, db := newTestReporter(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	completed := trade.Trade{ID: uuid.New(), CreatedAt: start, OrderSize: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+	partial := trade.Trade{ID: uuid.New(), CreatedAt: start.Add(time.Hour), OrderSize: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+	buy := trade.Trade{ID: uuid.New(), CreatedAt: start.Add(2 * time.Hour), OrderSize: dec("1"), OrderSizeCurrency: "ETH", OrderPrice: dec("2000"), OrderPriceCurrency: "USDT", Side: exchange.SideBuy}
+	later := trade.Trade{ID: uuid.New(), CreatedAt: start.Add(48 * time.Hour), OrderSize: dec("1"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+
+	for _, tr := range []trade.Trade{completed, partial, buy, later} {
+		require.NoError(t, db.Create(&tr).Error)
+	}
+
+	orders := []order.Order{
+		{OrderId: "1", TradeId: completed.ID, Symbol: "BNBUSDT", ExecutedSize: dec("4"), CumulativeQuoteQty: dec("396"), Fee: dec("0.396"), FeeAsset: "USDT", ExchangeUpdatedAt: start.Add(time.Minute)},
+		{OrderId: "2", TradeId: completed.ID, Symbol: "BNBUSDT", ExecutedSize: dec("0"), ExchangeUpdatedAt: start.Add(2 * time.Minute)},
+		{OrderId: "3", TradeId: completed.ID, Symbol: "BNBUSDT", ExecutedSize: dec("6"), CumulativeQuoteQty: dec("594"), Fee: dec("0.594"), FeeAsset: "USDT", ExchangeUpdatedAt: start.Add(5 * time.Minute)},
+		{OrderId: "4", TradeId: partial.ID, Symbol: "BNBUSDT", ExecutedSize: dec("5"), CumulativeQuoteQty: dec("510"), Fee: dec("0.51"), FeeAsset: "USDT", ExchangeUpdatedAt: start.Add(61 * time.Minute)},
+		{OrderId: "5", TradeId: buy.ID, Symbol: "ETHUSDT", ExecutedSize: dec("1"), CumulativeQuoteQty: dec("2010"), Fee: dec("0.001"), FeeAsset: "ETH", ExchangeUpdatedAt: start.Add(3 * time.Hour)},
+	}
+
+	for _, o := range orders {
+		require.NoError(t, db.Create(&o).Error)
+	}
+
+	report, err := reporter.Report(Filter{To: start.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, report.Trades, 3)
+
+	c := report.Trades[0]
+	assert.Equal(t, completed.ID, c.TradeId)
+	assert.Equal(t, 3, c.Orders)
+	assert.Equal(t, "99", c.AveragePrice.String())
+	assert.Equal(t, "1", c.SlippagePercent.String())
+	assert.Equal(t, "0", c.Remaining.String())
+	require.NotNil(t, c.CompletedAt)
+	assert.Equal(t, 5*time.Minute, c.TimeToComplete)
+	assert.Equal(t, "990", c.Proceeds.Gross.String())
+	assert.Equal(t, "989.01", c.Proceeds.Net.String())
+
+	// a sell filled above its price has negative slippage
+	p := report.Trades[1]
+	assert.Equal(t, "-2", p.SlippagePercent.String())
+	assert.Equal(t, "5", p.Remaining.String())
+	assert.Nil(t, p.CompletedAt)
+
+	b := report.Trades[2]
+	assert.Equal(t, "0.5", b.SlippagePercent.String())
+	assert.Equal(t, "2012.01", b.Proceeds.Net.String())
+
+	require.Len(t, report.Totals, 2)
+
+	bnb := report.Totals[0]
+	assert.Equal(t, "BNBUSDT", bnb.Symbol)
+	assert.Equal(t, 2, bnb.Trades)
+	assert.Equal(t, 1, bnb.Completed)
+	assert.Equal(t, 4, bnb.Orders)
+	assert.Equal(t, "15", bnb.Filled.String())
+	assert.Equal(t, "5", bnb.Remaining.String())
+	assert.Equal(t, "100", bnb.AveragePrice.String())
+	assert.Equal(t, "0", bnb.SlippagePercent.String())
+	assert.Equal(t, 5*time.Minute, bnb.AverageTimeToComplete)
+	assert.Equal(t, "1500", bnb.Proceeds.Gross.String())
+	assert.Equal(t, "1.5", bnb.Proceeds.Fees.String())
+	assert.Equal(t, "ETHUSDT", report.Totals[1].Symbol)
+
+	// the buy paid 10 more than 2000, sells were filled at 1500 on average
+	o := report.Overall
+	assert.Equal(t, 3, o.Trades)
+	assert.Equal(t, 2, o.Completed)
+	assert.Equal(t, 5, o.Orders)
+	assert.Equal(t, "USDT", o.QuoteCurrency)
+	assert.Equal(t, "0.2857", o.SlippagePercent.Round(4).String())
+	// sells received 1500 and the buy paid 2010
+	assert.Equal(t, "-510", o.Gross.String())
+	assert.Equal(t, "3.51", o.Fees.String())
+	assert.Equal(t, "-513.51", o.Net.String())
+	assert.Equal(t, 65*time.Minute/2, o.AverageTimeToComplete)
+
+	report, err = reporter.Report(Filter{Symbol: "BNBUSDT", From: start.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, report.Trades, 2)
+	assert.Equal(t, partial.ID, report.Trades[0].TradeId)
+	assert.Equal(t, later.ID, report.Trades[1].TradeId)
+	assert.Equal(t, 0, report.Trades[1].Orders)
+}
+
+func TestReporter_ReportReferencePrice(t *testing.T) {
+	reporter, db := newTestReporter(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stopped := trade.Trade{ID: uuid.New(), CreatedAt: start, OrderSize: dec("2"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+	btc := trade.Trade{ID: uuid.New(), CreatedAt: start.Add(time.Hour), OrderSize: dec("1"), OrderSizeCurrency: "BNB", OrderPrice: dec("0.01"), OrderPriceCurrency: "BTC"}
+
+	for _, tr := range []trade.Trade{stopped, btc} {
+		require.NoError(t, db.Create(&tr).Error)
+	}
+
+	orders := []order.Order{
+		// sold by a stop at 90, the fill is compared to the stop price
+		{OrderId: "1", TradeId: stopped.ID, Symbol: "BNBUSDT", ExecutedSize: dec("1"), CumulativeQuoteQty: dec("89"), ReferencePrice: dec("90"), ExchangeUpdatedAt: start.Add(time.Minute)},
+		// an order which did not record its reference price is compared to the order price
+		{OrderId: "2", TradeId: stopped.ID, Symbol: "BNBUSDT", ExecutedSize: dec("1"), CumulativeQuoteQty: dec("99"), ExchangeUpdatedAt: start.Add(2 * time.Minute)},
+		{OrderId: "3", TradeId: btc.ID, Symbol: "BNBBTC", ExecutedSize: dec("1"), CumulativeQuoteQty: dec("0.01"), ExchangeUpdatedAt: start.Add(61 * time.Minute)},
+	}
+
+	for _, o := range orders {
+		require.NoError(t, db.Create(&o).Error)
+	}
+
+	report, err := reporter.Report(Filter{})
+	require.NoError(t, err)
+	require.Len(t, report.Trades, 2)
+
+	s := report.Trades[0]
+	assert.Equal(t, "95", s.ReferencePrice.String())
+	// 2 short of 190
+	assert.Equal(t, "1.0526", s.SlippagePercent.Round(4).String())
+
+	// slippage and fees of trades quoted in different currencies are not summed
+	o := report.Overall
+	assert.Equal(t, 2, o.Trades)
+	assert.Equal(t, 3, o.Orders)
+	assert.Equal(t, "", o.QuoteCurrency)
+	assert.True(t, o.SlippagePercent.IsZero())
+	assert.True(t, o.Gross.IsZero())
+	assert.True(t, o.Net.IsZero())
+}
@@ -0,0 +1,67 @@
+package symbol
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/pkg/logus"
+)
+
+type RepositoryInterface interface {
+	FindOneBySymbol(ctx context.Context, symbol string) (*exchange.SymbolInfo, error)
+}
+
+type cached struct {
+	info      exchange.SymbolInfo
+	fetchedAt time.Time
+}
+
+// Repository keeps symbol rules read from the exchange for ttl, they change rarely
+type Repository struct {
+	exchange exchange.Exchange
+	logger   logus.Logger
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	symbols map[string]cached
+}
+
+func NewRepository(
+	exchange exchange.Exchange,
+	logger logus.Logger,
+	ttl time.Duration,
+) *Repository {
+	return &Repository{
+		exchange: exchange,
+		logger:   logger,
+		ttl:      ttl,
+		now:      time.Now,
+		symbols:  map[string]cached{},
+	}
+}
+
+func (r *Repository) FindOneBySymbol(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	r.mu.Lock()
+	c, ok := r.symbols[symbol]
+	r.mu.Unlock()
+
+	if ok && r.now().Sub(c.fetchedAt) < r.ttl {
+		return &c.info, nil
+	}
+
+	info, err := r.exchange.SymbolInfo(ctx, symbol)
+	if err != nil {
+		r.logger.Error(err)
+
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.symbols[symbol] = cached{info: *info, fetchedAt: r.now()}
+	r.mu.Unlock()
+
+	return info, nil
+}
@@ -0,0 +1,49 @@
+package symbol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/exchange/binancetest"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_FindOneBySymbol(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetSymbols([]binance.Symbol{
+		{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT", Filters: []map[string]interface{}{
+			{"filterType": "LOT_SIZE", "minQty": "0.01", "stepSize": "0.01"},
+		}},
+	})
+
+	logger := logus.NewTestLogger()
+	now := time.Now()
+
+	repo := NewRepository(exchange.NewBinance(server.NewClient(), logger), logger, time.Hour)
+	repo.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		info, err := repo.FindOneBySymbol(context.Background(), "BNBUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, "0.01", info.StepSize.String())
+	}
+
+	// filters are read once until they expire
+	assert.Equal(t, 1, server.Requests("/api/v3/exchangeInfo"))
+
+	now = now.Add(time.Hour)
+
+	_, err := repo.FindOneBySymbol(context.Background(), "BNBUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.Requests("/api/v3/exchangeInfo"))
+
+	_, err = repo.FindOneBySymbol(context.Background(), "ETHUSDT")
+	assert.ErrorIs(t, err, exchange.ErrSymbolNotFound)
+}
@@ -0,0 +1,127 @@
+package trade
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AllocationPolicy decides how quantity offered at the top of the book is shared by trades on the same symbol
+type AllocationPolicy string
+
+const (
+	// AllocationFIFO gives the quantity to the oldest trades first
+	AllocationFIFO AllocationPolicy = "fifo"
+	// AllocationProRata splits the quantity in proportion to the size left of every trade
+	AllocationProRata AllocationPolicy = "pro_rata"
+	// AllocationBestPrice gives the quantity to trades with the best limit first, the lowest sell and the highest
+	// buy price, as the exchange would
+	AllocationBestPrice AllocationPolicy = "best_price"
+)
+
+// Allocation is the quantity a trade may order on a single ticker
+type Allocation struct {
+	Policy AllocationPolicy
+	Size   decimal.Decimal
+}
+
+func ParseAllocationPolicy(s string) (AllocationPolicy, error) {
+	switch p := AllocationPolicy(s); p {
+	case AllocationFIFO, AllocationProRata, AllocationBestPrice:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown allocation policy %q", s)
+}
+
+// Allocate shares the ticker quantity by trades matching the ticker, sell and buy trades take different sides
+// of the book and are allocated separately. Trades waiting for their next slice at now and trades which get
+// nothing are left out.
+func (p AllocationPolicy) Allocate(trades []Trade, ticker orderbookticker.OrderBookTicker, now time.Time) map[uuid.UUID]Allocation {
+	allocations := map[uuid.UUID]Allocation{}
+
+	for _, side := range []exchange.Side{exchange.SideSell, exchange.SideBuy} {
+		var matched []Trade
+
+		available := decimal.Zero
+
+		for i := range trades {
+			if trades[i].GetSide() != side || !trades[i].OrderSizeLeft.IsPositive() {
+				continue
+			}
+
+			// a ladder shares only the size left of the levels the ticker reached and a sliced trade only its slice
+			if e, ok := trades[i].Match(ticker); ok {
+				if e, ok = trades[i].Schedule(e, now); !ok {
+					continue
+				}
+
+				t := trades[i]
+				t.OrderSizeLeft = e.SizeLeft
+				matched = append(matched, t)
+				available = e.Qty
+			}
+		}
+
+		for id, size := range p.allocate(matched, available) {
+			if size.IsPositive() {
+				allocations[id] = Allocation{Policy: p, Size: size}
+			}
+		}
+	}
+
+	return allocations
+}
+
+func (p AllocationPolicy) allocate(trades []Trade, available decimal.Decimal) map[uuid.UUID]decimal.Decimal {
+	sizes := map[uuid.UUID]decimal.Decimal{}
+
+	if p == AllocationProRata {
+		total := decimal.Zero
+		for i := range trades {
+			total = total.Add(trades[i].OrderSizeLeft)
+		}
+
+		for i := range trades {
+			size := trades[i].OrderSizeLeft
+			if total.GreaterThan(available) {
+				size = available.Mul(trades[i].OrderSizeLeft).Div(total)
+			}
+
+			sizes[trades[i].ID] = size
+		}
+
+		return sizes
+	}
+
+	ordered := append([]Trade{}, trades...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if p == AllocationBestPrice && !ordered[i].OrderPrice.Equal(ordered[j].OrderPrice) {
+			if ordered[i].GetSide() == exchange.SideBuy {
+				return ordered[i].OrderPrice.GreaterThan(ordered[j].OrderPrice)
+			}
+
+			return ordered[i].OrderPrice.LessThan(ordered[j].OrderPrice)
+		}
+
+		if !ordered[i].CreatedAt.Equal(ordered[j].CreatedAt) {
+			return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+		}
+
+		return ordered[i].ID.String() < ordered[j].ID.String()
+	})
+
+	for i := range ordered {
+		size := decimal.Min(ordered[i].OrderSizeLeft, available)
+
+		sizes[ordered[i].ID] = size
+		available = available.Sub(size)
+	}
+
+	return sizes
+}
@@ -0,0 +1,88 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocationPolicy_Allocate(t *testing.T) {
+	now := time.Now()
+
+	older := Trade{ID: uuid.New(), CreatedAt: now.Add(-time.Hour), OrderSizeLeft: dec("30"), OrderSizeCurrency: "BNB", OrderPrice: dec("112"), OrderPriceCurrency: "USDT"}
+	newer := Trade{ID: uuid.New(), CreatedAt: now, OrderSizeLeft: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("110"), OrderPriceCurrency: "USDT"}
+	tooHigh := Trade{ID: uuid.New(), CreatedAt: now, OrderSizeLeft: dec("10"), OrderSizeCurrency: "BNB", OrderPrice: dec("120"), OrderPriceCurrency: "USDT"}
+	buy := Trade{ID: uuid.New(), CreatedAt: now, OrderSizeLeft: dec("50"), OrderSizeCurrency: "BNB", OrderPrice: dec("117"), OrderPriceCurrency: "USDT", Side: exchange.SideBuy}
+	cheapBuy := Trade{ID: uuid.New(), CreatedAt: now.Add(-time.Hour), OrderSizeLeft: dec("50"), OrderSizeCurrency: "BNB", OrderPrice: dec("116"), OrderPriceCurrency: "USDT", Side: exchange.SideBuy}
+
+	ticker := orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec("115"), BidQty: dec("20"), AskPrice: dec("116"), AksQty: dec("60")}
+
+	tests := []struct {
+		name   string
+		policy AllocationPolicy
+		trades []Trade
+		want   map[uuid.UUID]string
+	}{
+		{
+			name:   "fifo gives the quantity to the oldest trade first",
+			policy: AllocationFIFO,
+			trades: []Trade{newer, older, tooHigh},
+			want:   map[uuid.UUID]string{older.ID: "20"},
+		},
+		{
+			name:   "fifo gives the rest to the next trade",
+			policy: AllocationFIFO,
+			trades: []Trade{newer, {ID: older.ID, CreatedAt: older.CreatedAt, OrderSizeLeft: dec("15"), OrderSizeCurrency: "BNB", OrderPrice: dec("112"), OrderPriceCurrency: "USDT"}},
+			want:   map[uuid.UUID]string{older.ID: "15", newer.ID: "5"},
+		},
+		{
+			name:   "pro rata splits by size left",
+			policy: AllocationProRata,
+			trades: []Trade{newer, older, tooHigh},
+			want:   map[uuid.UUID]string{older.ID: "15", newer.ID: "5"},
+		},
+		{
+			name:   "best price gives the quantity to the lowest sell price first",
+			policy: AllocationBestPrice,
+			trades: []Trade{older, newer},
+			want:   map[uuid.UUID]string{newer.ID: "10", older.ID: "10"},
+		},
+		{
+			name:   "best price gives the quantity to the highest buy price first",
+			policy: AllocationBestPrice,
+			trades: []Trade{cheapBuy, buy},
+			want:   map[uuid.UUID]string{buy.ID: "50", cheapBuy.ID: "10"},
+		},
+		{
+			name:   "sell and buy trades take different sides of the book",
+			policy: AllocationFIFO,
+			trades: []Trade{older, buy},
+			want:   map[uuid.UUID]string{older.ID: "20", buy.ID: "50"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[uuid.UUID]string{}
+			for id, allocation := range tt.policy.Allocate(tt.trades, ticker, now) {
+				assert.Equal(t, tt.policy, allocation.Policy)
+				got[id] = allocation.Size.String()
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAllocationPolicy(t *testing.T) {
+	policy, err := ParseAllocationPolicy("pro_rata")
+	assert.NoError(t, err)
+	assert.Equal(t, AllocationProRata, policy)
+
+	_, err = ParseAllocationPolicy("random")
+	assert.Error(t, err)
+}
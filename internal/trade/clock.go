@@ -0,0 +1,6 @@
+package trade
+
+import "time"
+
+// Clock tells the time trades are scheduled and expired by, it is time.Now unless a backtest replays its ticks
+type Clock func() time.Time
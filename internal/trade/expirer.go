@@ -0,0 +1,112 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/shopspring/decimal"
+)
+
+// Expirer ends trades which were not done by their ExpiresAt, their orders resting on the exchange are canceled
+type Expirer struct {
+	logger     logus.Logger
+	tradeRepo  RepositoryInterface
+	orderRepo  order.RepositoryInterface
+	exchange   exchange.Exchange
+	unitOfWork UnitOfWorkInterface
+	clock      Clock
+}
+
+func NewExpirer(
+	logger logus.Logger,
+	tradeRepo RepositoryInterface,
+	orderRepo order.RepositoryInterface,
+	exchange exchange.Exchange,
+	unitOfWork UnitOfWorkInterface,
+	clock Clock,
+) Expirer {
+	return Expirer{
+		logger:     logger,
+		tradeRepo:  tradeRepo,
+		orderRepo:  orderRepo,
+		exchange:   exchange,
+		unitOfWork: unitOfWork,
+		clock:      clock,
+	}
+}
+
+// Run expires trades every interval until ctx is done. Expiring is given workCtx, so an order which is being
+// canceled is not interrupted between the exchange and the database when ctx is done.
+func (s Expirer) Run(ctx context.Context, workCtx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Expire(workCtx); err != nil {
+				s.logger.Error(err)
+			}
+		}
+	}
+}
+
+// Expire expires every trade past its ExpiresAt, a failing trade does not stop the others
+func (s Expirer) Expire(ctx context.Context) error {
+	trades, err := s.tradeRepo.FindAllExpired(s.clock())
+	if err != nil {
+		return err
+	}
+
+	for i := range trades {
+		if err := s.expire(ctx, trades[i]); err != nil {
+			s.logger.Error(fmt.Errorf("TRADE %s was not expired: %w", trades[i].ID, err))
+		}
+	}
+
+	return nil
+}
+
+// expire cancels open orders of the trade before it is marked expired, a trade whose order could not be canceled
+// is tried again on the next run. The trade is not traded meanwhile, it is out of its window.
+func (s Expirer) expire(ctx context.Context, t Trade) error {
+	open, err := s.orderRepo.FindOpenByTradeId(t.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range open {
+		res, err := s.exchange.CancelOrder(ctx, o.Symbol, o.OrderId)
+		if err != nil {
+			return fmt.Errorf("order %s was not canceled: %w", o.OrderId, err)
+		}
+
+		if err := storeOrderState(s.unitOfWork, o, res); err != nil {
+			return err
+		}
+	}
+
+	if err := s.tradeRepo.SetStatus(t.ID, StatusExpired); err != nil {
+		return err
+	}
+
+	orders, err := s.orderRepo.FindAll(order.Filter{TradeId: t.ID})
+	if err != nil {
+		return err
+	}
+
+	unfilled := t.OrderSize
+	for _, o := range orders {
+		unfilled = unfilled.Sub(o.ExecutedSize)
+	}
+
+	s.logger.Infof("TRADE %s expired, %s of %s is unfilled, %d open orders canceled", t.ID, decimal.Max(unfilled, decimal.Zero), t.OrderSize, len(open))
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package trade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpirer_Expire(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		res := now.Add(d)
+
+		return &res
+	}
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("10")}}, nil)
+
+	tradeRepo := NewRepository(db, testLogger)
+
+	orderRepo := order.NewRepository(db, testLogger)
+	expirer := NewExpirer(testLogger, tradeRepo, orderRepo, sim, NewUnitOfWork(db, testLogger), func() time.Time { return now })
+
+	newTrade := func(status Status, startAt *time.Time, expiresAt *time.Time) Trade {
+		tr := Trade{
+			ID:                 uuid.New(),
+			OrderSize:          dec("50"),
+			OrderSizeLeft:      dec("50"),
+			OrderSizeCurrency:  "BNB",
+			OrderPrice:         dec("111"),
+			OrderPriceCurrency: "USDT",
+			Status:             status,
+			StartAt:            startAt,
+			ExpiresAt:          expiresAt,
+		}
+		require.NoError(t, tradeRepo.Create(tr))
+
+		return tr
+	}
+
+	expired := newTrade(StatusActive, nil, at(-time.Minute))
+	pausedExpired := newTrade(StatusPaused, nil, at(0))
+	notStarted := newTrade(StatusActive, at(time.Hour), at(2*time.Hour))
+	open := newTrade(StatusActive, at(-time.Hour), at(time.Hour))
+	filled := newTrade(StatusActive, nil, at(-time.Minute))
+	reserved := newTrade(StatusActive, nil, at(-time.Minute))
+
+	active, err := tradeRepo.FindAllActive(now)
+	require.NoError(t, err)
+	require.Len(t, active, 1, "trades out of their window are not traded")
+	assert.Equal(t, open.ID, active[0].ID)
+
+	// order resting in the book after 10 were executed on placement, its whole size is reserved on the trade
+	res, err := sim.PlaceOrder(ctx, exchange.OrderRequest{
+		Symbol:      "BNBUSDT",
+		Side:        exchange.SideSell,
+		Quantity:    dec("40"),
+		Price:       dec("112"),
+		TimeInForce: exchange.TimeInForceGTC,
+	})
+	require.NoError(t, err)
+	require.Equal(t, exchange.OrderStatusPartiallyFilled, res.Status)
+
+	require.NoError(t, tradeRepo.Reserve(expired, dec("40")))
+	require.NoError(t, orderRepo.Create(order.Order{
+		OrderId:      res.OrderId,
+		TradeId:      expired.ID,
+		Symbol:       "BNBUSDT",
+		Side:         exchange.SideSell,
+		Status:       res.Status,
+		OrderSize:    dec("40"),
+		OrderPrice:   dec("112"),
+		ExecutedSize: res.ExecutedQty,
+	}))
+
+	// whole size of a trade was filled before it expired
+	require.NoError(t, tradeRepo.Reserve(filled, dec("50")))
+	require.NoError(t, orderRepo.Create(order.Order{
+		OrderId:      "filled",
+		TradeId:      filled.ID,
+		Symbol:       "BNBUSDT",
+		Side:         exchange.SideSell,
+		Status:       exchange.OrderStatusFilled,
+		OrderSize:    dec("50"),
+		OrderPrice:   dec("111"),
+		ExecutedSize: dec("50"),
+	}))
+
+	// whole size of a trade is reserved by an order resting above the book
+	resting, err := sim.PlaceOrder(ctx, exchange.OrderRequest{
+		Symbol:      "BNBUSDT",
+		Side:        exchange.SideSell,
+		Quantity:    dec("50"),
+		Price:       dec("120"),
+		TimeInForce: exchange.TimeInForceGTC,
+	})
+	require.NoError(t, err)
+	require.Equal(t, exchange.OrderStatusNew, resting.Status)
+
+	require.NoError(t, tradeRepo.Reserve(reserved, dec("50")))
+	require.NoError(t, orderRepo.Create(order.Order{
+		OrderId:    resting.OrderId,
+		TradeId:    reserved.ID,
+		Symbol:     "BNBUSDT",
+		Side:       exchange.SideSell,
+		Status:     resting.Status,
+		OrderSize:  dec("50"),
+		OrderPrice: dec("120"),
+	}))
+
+	require.NoError(t, expirer.Expire(ctx))
+
+	stored := func(id uuid.UUID) Trade {
+		tr, err := tradeRepo.FindOneById(id)
+		require.NoError(t, err)
+
+		return *tr
+	}
+
+	tr := stored(expired.ID)
+	assert.Equal(t, StatusExpired, tr.Status)
+	// what was not executed is the unfilled remainder
+	assertDecimal(t, "40", tr.OrderSizeLeft)
+
+	o, err := orderRepo.FindAll(order.Filter{TradeId: expired.ID})
+	require.NoError(t, err)
+	require.Len(t, o, 1)
+	assert.Equal(t, exchange.OrderStatusCanceled, o[0].Status)
+
+	assert.Equal(t, StatusExpired, stored(pausedExpired.ID).Status)
+	assert.Equal(t, StatusActive, stored(notStarted.ID).Status)
+	assert.Equal(t, StatusActive, stored(open.ID).Status)
+	assert.Equal(t, StatusActive, stored(filled.ID).Status, "filled trades are not expired")
+
+	tr = stored(reserved.ID)
+	assert.Equal(t, StatusExpired, tr.Status)
+	assertDecimal(t, "50", tr.OrderSizeLeft)
+
+	// expired trades are not expired again
+	expiredTrades, err := tradeRepo.FindAllExpired(now)
+	require.NoError(t, err)
+	assert.Empty(t, expiredTrades)
+}
@@ -0,0 +1,89 @@
+package trade
+
+import (
+	"fmt"
+
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/shopspring/decimal"
+)
+
+// Guard keeps trades from ordering on a book which is too wide or at a price too far beyond the price which
+// triggered them. Limits of a trade replace the guard ones, zero limits are not checked.
+type Guard struct {
+	// MaxSpreadPercent is the widest bid/ask spread in percent of the mid price, crossed books always breach it
+	MaxSpreadPercent decimal.Decimal
+	// MaxDeviationPercent is how far in percent the execution price may be worse than the price which triggered it
+	MaxDeviationPercent decimal.Decimal
+}
+
+// SkipKind is what the guard skipped a trade for, it stays the same while the prices of the reason change
+type SkipKind string
+
+const (
+	SkipSpread    SkipKind = "SPREAD"
+	SkipCrossed   SkipKind = "CROSSED"
+	SkipEmptySide SkipKind = "EMPTY_SIDE"
+	SkipDeviation SkipKind = "DEVIATION"
+)
+
+// Skip is why the guard keeps a trade from ordering, Reason tells the prices the trade was skipped on
+type Skip struct {
+	Kind   SkipKind
+	Reason string
+}
+
+// Check returns why the trade may not order the execution on the ticker, it is empty when the trade may order
+func (g Guard) Check(t Trade, ticker orderbookticker.OrderBookTicker, e Execution) Skip {
+	maxSpread := g.MaxSpreadPercent
+	if t.MaxSpreadPercent.IsPositive() {
+		maxSpread = t.MaxSpreadPercent
+	}
+
+	maxDeviation := g.MaxDeviationPercent
+	if t.MaxDeviationPercent.IsPositive() {
+		maxDeviation = t.MaxDeviationPercent
+	}
+
+	if maxSpread.IsPositive() {
+		if skip := checkSpread(ticker, maxSpread); skip.Kind != "" {
+			return skip
+		}
+	}
+
+	if maxDeviation.IsPositive() && e.Reference.IsPositive() && t.worse(e.Price, e.Reference) {
+		deviation := e.Price.Sub(e.Reference).Abs().Div(e.Reference).Mul(decimal.NewFromInt(100))
+		if deviation.GreaterThan(maxDeviation) {
+			return Skip{SkipDeviation, fmt.Sprintf("price %s is %s%% worse than %s price %s, above %s%%", e.Price, deviation.StringFixed(2), e.Trigger, e.Reference, maxDeviation)}
+		}
+	}
+
+	return Skip{}
+}
+
+func checkSpread(ticker orderbookticker.OrderBookTicker, maxSpread decimal.Decimal) Skip {
+	if !ticker.BidPrice.IsPositive() || !ticker.AskPrice.IsPositive() {
+		return Skip{SkipEmptySide, "spread is unknown, a side of the book is empty"}
+	}
+
+	if ticker.AskPrice.LessThan(ticker.BidPrice) {
+		return Skip{SkipCrossed, fmt.Sprintf("book is crossed, ask %s is below bid %s", ticker.AskPrice, ticker.BidPrice)}
+	}
+
+	mid := ticker.BidPrice.Add(ticker.AskPrice).Div(decimal.NewFromInt(2))
+
+	spread := ticker.AskPrice.Sub(ticker.BidPrice).Div(mid).Mul(decimal.NewFromInt(100))
+	if spread.GreaterThan(maxSpread) {
+		return Skip{SkipSpread, fmt.Sprintf("spread %s%% is above %s%%", spread.StringFixed(2), maxSpread)}
+	}
+
+	return Skip{}
+}
+
+// validateGuard checks the limits of the trade
+func (m Trade) validateGuard() error {
+	if m.MaxSpreadPercent.IsNegative() || m.MaxDeviationPercent.IsNegative() {
+		return fmt.Errorf("%w: spread and deviation limits cannot be negative", ErrInvalidTrade)
+	}
+
+	return nil
+}
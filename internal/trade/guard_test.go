@@ -0,0 +1,66 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_Check(t *testing.T) {
+	book := orderbookticker.OrderBookTicker{BidPrice: dec("99.5"), AskPrice: dec("100.5")}
+	takeProfit := Execution{Price: dec("99.5"), Trigger: TriggerTakeProfit, Reference: dec("95")}
+	stopLoss := Execution{Price: dec("97"), Trigger: TriggerStopLoss, Reference: dec("100")}
+
+	tests := []struct {
+		name      string
+		guard     Guard
+		trade     Trade
+		ticker    orderbookticker.OrderBookTicker
+		execution Execution
+		want      Skip
+	}{
+		{name: "no limits", ticker: orderbookticker.OrderBookTicker{BidPrice: dec("90"), AskPrice: dec("110")}, execution: stopLoss},
+		{name: "spread within limit", guard: Guard{MaxSpreadPercent: dec("1")}, ticker: book, execution: takeProfit},
+		{name: "spread too wide", guard: Guard{MaxSpreadPercent: dec("0.5")}, ticker: book, execution: takeProfit, want: Skip{SkipSpread, "spread 1.00% is above 0.5%"}},
+		{
+			name:      "crossed book",
+			guard:     Guard{MaxSpreadPercent: dec("1")},
+			ticker:    orderbookticker.OrderBookTicker{BidPrice: dec("101"), AskPrice: dec("100")},
+			execution: takeProfit,
+			want:      Skip{SkipCrossed, "book is crossed, ask 100 is below bid 101"},
+		},
+		{
+			name:      "missing ask",
+			guard:     Guard{MaxSpreadPercent: dec("1")},
+			ticker:    orderbookticker.OrderBookTicker{BidPrice: dec("99.5")},
+			execution: takeProfit,
+			want:      Skip{SkipEmptySide, "spread is unknown, a side of the book is empty"},
+		},
+		{name: "trade limit replaces global", guard: Guard{MaxSpreadPercent: dec("0.5")}, trade: Trade{MaxSpreadPercent: dec("2")}, ticker: book, execution: takeProfit},
+		{
+			name:      "stop deviation too far",
+			guard:     Guard{MaxDeviationPercent: dec("2")},
+			ticker:    book,
+			execution: stopLoss,
+			want:      Skip{SkipDeviation, "price 97 is 3.00% worse than STOP_LOSS price 100, above 2%"},
+		},
+		{name: "stop deviation within limit", guard: Guard{MaxDeviationPercent: dec("5")}, ticker: book, execution: stopLoss},
+		{name: "better price is not a deviation", guard: Guard{MaxDeviationPercent: dec("1")}, ticker: book, execution: takeProfit},
+		{
+			name:      "buy deviation",
+			guard:     Guard{MaxDeviationPercent: dec("1")},
+			trade:     Trade{Side: exchange.SideBuy},
+			ticker:    book,
+			execution: Execution{Price: dec("103"), Trigger: TriggerStopLoss, Reference: dec("100")},
+			want:      Skip{SkipDeviation, "price 103 is 3.00% worse than STOP_LOSS price 100, above 1%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.guard.Check(tt.trade, tt.ticker, tt.execution))
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package trade
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Level is a step of a take-profit ladder, it sells Size once the bid reaches Price, buy ladders buy Size once
+// the ask falls to Price
+type Level struct {
+	ID      uint            `gorm:"primaryKey"`
+	TradeId uuid.UUID       `gorm:"index"`
+	Price   decimal.Decimal `gorm:"type:text"`
+	Size    decimal.Decimal `gorm:"type:text"`
+	// SizeLeft is the part of Size which is not ordered yet
+	SizeLeft decimal.Decimal `gorm:"type:text"`
+	// Fraction is the part of the trade size the level was given as, zero when it was given as a quantity
+	Fraction decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+}
+
+func (Level) TableName() string {
+	return "trade_levels"
+}
+
+// IsLadder tells if the trade takes profit on levels instead of OrderPrice
+func (m Trade) IsLadder() bool {
+	return len(m.Levels) > 0
+}
+
+// ladder returns levels in the order they are reached, from the lowest price for sells and from the highest
+// price for buys
+func (m Trade) ladder() []*Level {
+	levels := make([]*Level, 0, len(m.Levels))
+	for i := range m.Levels {
+		levels = append(levels, &m.Levels[i])
+	}
+
+	sort.SliceStable(levels, func(i, j int) bool {
+		if m.GetSide() == exchange.SideBuy {
+			return levels[i].Price.GreaterThan(levels[j].Price)
+		}
+
+		return levels[i].Price.LessThan(levels[j].Price)
+	})
+
+	return levels
+}
+
+// reached returns the size left of levels the price reached and the price of the first of them with size left,
+// it is the level an order takes its size from first
+func (m Trade) reached(price decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	left, reference := decimal.Zero, decimal.Zero
+
+	for _, l := range m.ladder() {
+		if m.worse(price, l.Price) {
+			break
+		}
+
+		if reference.IsZero() && l.SizeLeft.IsPositive() {
+			reference = l.Price
+		}
+
+		left = left.Add(l.SizeLeft)
+	}
+
+	return left, reference
+}
+
+// distributeSizeLeft sets size left of the levels from OrderSizeLeft, the ordered size is taken from the levels in
+// the order they are reached, so OrderSizeLeft is always the sum of the levels
+func (m *Trade) distributeSizeLeft() {
+	ordered := m.OrderSize.Sub(m.OrderSizeLeft)
+
+	for _, l := range m.ladder() {
+		taken := decimal.Max(decimal.Zero, decimal.Min(l.Size, ordered))
+		ordered = ordered.Sub(taken)
+		l.SizeLeft = l.Size.Sub(taken)
+	}
+}
+
+// setLevels prepares levels of a new ladder, OrderPrice becomes the first level
+func (m *Trade) setLevels() {
+	if m.OrderSize.IsZero() {
+		for _, l := range m.Levels {
+			m.OrderSize = m.OrderSize.Add(l.Size)
+		}
+	}
+
+	for i := range m.Levels {
+		m.Levels[i].ID = 0
+		m.Levels[i].TradeId = m.ID
+
+		if m.Levels[i].Fraction.IsPositive() {
+			m.Levels[i].Size = m.OrderSize.Mul(m.Levels[i].Fraction)
+		}
+	}
+
+	m.OrderPrice = m.ladder()[0].Price
+}
+
+// validateLevels checks that levels add up to the trade size and OrderPrice is the first of them
+func (m Trade) validateLevels() error {
+	if !m.IsLadder() {
+		return nil
+	}
+
+	total := decimal.Zero
+
+	for _, l := range m.Levels {
+		if !l.Price.IsPositive() || !l.Size.IsPositive() {
+			return fmt.Errorf("%w: level price %s and size %s have to be positive", ErrInvalidTrade, l.Price, l.Size)
+		}
+
+		if l.Fraction.IsNegative() || l.Fraction.GreaterThan(decimal.NewFromInt(1)) {
+			return fmt.Errorf("%w: level fraction %s has to be between 0 and 1", ErrInvalidTrade, l.Fraction)
+		}
+
+		total = total.Add(l.Size)
+	}
+
+	if !total.Equal(m.OrderSize) {
+		return fmt.Errorf("%w: level sizes add up to %s instead of size %s", ErrInvalidTrade, total, m.OrderSize)
+	}
+
+	if first := m.ladder()[0].Price; !m.OrderPrice.Equal(first) {
+		return fmt.Errorf("%w: price %s has to be the first level %s", ErrInvalidTrade, m.OrderPrice, first)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/stretchr/testify/assert"
+)
+
+func ladder(side exchange.Side, levels ...Level) Trade {
+	t := Trade{Side: side, OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", Levels: levels}
+	for _, l := range levels {
+		t.OrderSize = t.OrderSize.Add(l.Size)
+	}
+
+	t.OrderSizeLeft = t.OrderSize
+	t.OrderPrice = t.ladder()[0].Price
+	t.distributeSizeLeft()
+
+	return t
+}
+
+func TestTrade_Match_Ladder(t *testing.T) {
+	ticker := func(bid string, ask string) orderbookticker.OrderBookTicker {
+		return orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec(bid), BidQty: dec("100"), AskPrice: dec(ask), AksQty: dec("100")}
+	}
+
+	sell := ladder(exchange.SideSell, Level{Price: dec("120"), Size: dec("2")}, Level{Price: dec("110"), Size: dec("1")}, Level{Price: dec("130"), Size: dec("3")})
+	buy := ladder(exchange.SideBuy, Level{Price: dec("90"), Size: dec("2")}, Level{Price: dec("100"), Size: dec("1")})
+
+	partlyOrdered := sell
+	partlyOrdered.Levels = append([]Level{}, sell.Levels...)
+	partlyOrdered.OrderSizeLeft = dec("4.5")
+	partlyOrdered.distributeSizeLeft()
+
+	tests := []struct {
+		name      string
+		trade     Trade
+		ticker    orderbookticker.OrderBookTicker
+		sizeLeft  string
+		reference string
+	}{
+		{name: "below first level", trade: sell, ticker: ticker("109", "110")},
+		{name: "first level", trade: sell, ticker: ticker("110", "111"), sizeLeft: "1", reference: "110"},
+		{name: "two levels", trade: sell, ticker: ticker("125", "126"), sizeLeft: "3", reference: "110"},
+		{name: "all levels", trade: sell, ticker: ticker("130", "131"), sizeLeft: "6", reference: "110"},
+		{name: "ordered levels are left out", trade: partlyOrdered, ticker: ticker("125", "126"), sizeLeft: "1.5", reference: "120"},
+		{name: "buy first level", trade: buy, ticker: ticker("99", "100"), sizeLeft: "1", reference: "100"},
+		{name: "buy above first level", trade: buy, ticker: ticker("100", "101")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := tt.trade.Match(tt.ticker)
+
+			assert.Equal(t, tt.sizeLeft != "", ok)
+
+			if ok {
+				assert.Equal(t, TriggerTakeProfit, e.Trigger)
+				assertDecimal(t, tt.sizeLeft, e.SizeLeft)
+				assertDecimal(t, tt.reference, e.Reference)
+			}
+		})
+	}
+
+	// stops sell the whole size left of a ladder
+	stop := sell
+	stop.StopPrice = dec("100")
+
+	e, ok := stop.Match(ticker("99", "100"))
+	assert.True(t, ok)
+	assert.Equal(t, TriggerStopLoss, e.Trigger)
+	assertDecimal(t, "6", e.SizeLeft)
+}
+
+func TestTrade_distributeSizeLeft(t *testing.T) {
+	tr := ladder(exchange.SideSell, Level{Price: dec("130"), Size: dec("3")}, Level{Price: dec("110"), Size: dec("1")}, Level{Price: dec("120"), Size: dec("2")})
+
+	sizesLeft := func() []string {
+		var res []string
+		for _, l := range tr.Levels {
+			res = append(res, l.SizeLeft.String())
+		}
+
+		return res
+	}
+
+	assert.Equal(t, []string{"3", "1", "2"}, sizesLeft())
+
+	tr.OrderSizeLeft = dec("4.5")
+	tr.distributeSizeLeft()
+	assert.Equal(t, []string{"3", "0", "1.5"}, sizesLeft())
+
+	tr.OrderSizeLeft = dec("0")
+	tr.distributeSizeLeft()
+	assert.Equal(t, []string{"0", "0", "0"}, sizesLeft())
+}
+
+func TestTrade_Validate_Ladder(t *testing.T) {
+	valid := func(change func(t *Trade)) Trade {
+		t := ladder(exchange.SideSell, Level{Price: dec("110"), Size: dec("1")}, Level{Price: dec("120"), Size: dec("2")})
+		change(&t)
+
+		return t
+	}
+
+	tests := []struct {
+		name    string
+		trade   Trade
+		wantErr bool
+	}{
+		{name: "valid", trade: valid(func(t *Trade) {})},
+		{name: "with stop", trade: valid(func(t *Trade) { t.StopPrice = dec("100") })},
+		{name: "stop above first level", trade: valid(func(t *Trade) { t.StopPrice = dec("115") }), wantErr: true},
+		{name: "sizes do not add up", trade: valid(func(t *Trade) { t.OrderSize = dec("4") }), wantErr: true},
+		{name: "price is not the first level", trade: valid(func(t *Trade) { t.OrderPrice = dec("120") }), wantErr: true},
+		{name: "zero level price", trade: valid(func(t *Trade) { t.Levels[1].Price = dec("0") }), wantErr: true},
+		{name: "fraction above 1", trade: valid(func(t *Trade) { t.Levels[1].Fraction = dec("1.5") }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trade.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTrade)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
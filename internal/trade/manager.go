@@ -0,0 +1,223 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidStatus = errors.New("trade status does not allow the change")
+
+type ManagerInterface interface {
+	Add(trade Trade) (*Trade, error)
+	Edit(id uuid.UUID, edit Edit) (*Trade, error)
+	Pause(id uuid.UUID) (*Trade, error)
+	Resume(id uuid.UUID) (*Trade, error)
+	Cancel(id uuid.UUID) (*Trade, error)
+	Reset(id uuid.UUID) (*Trade, error)
+}
+
+// Edit holds trade fields which may be changed, nil fields are kept
+type Edit struct {
+	OrderSize       *decimal.Decimal
+	OrderPrice      *decimal.Decimal
+	StopPrice       *decimal.Decimal
+	StopLimitOffset *decimal.Decimal
+	// trailing stop begins again from its activation price when any of its fields is changed
+	TrailingDistance        *decimal.Decimal
+	TrailingPercent         *decimal.Decimal
+	TrailingActivationPrice *decimal.Decimal
+}
+
+// Manager creates trades and changes their lifecycle, changes are rejected when the trade is being traded
+// concurrently, see ErrConcurrentUpdate
+type Manager struct {
+	logger    logus.Logger
+	tradeRepo RepositoryInterface
+	orderRepo order.RepositoryInterface
+}
+
+func NewManager(
+	logger logus.Logger,
+	tradeRepo RepositoryInterface,
+	orderRepo order.RepositoryInterface,
+) Manager {
+	return Manager{
+		logger:    logger,
+		tradeRepo: tradeRepo,
+		orderRepo: orderRepo,
+	}
+}
+
+// Add stores a new active trade with the whole size left. Size of a ladder is the sum of its levels when it is
+// not given, levels given as a fraction take their part of the size.
+func (s Manager) Add(trade Trade) (*Trade, error) {
+	if trade.ID == uuid.Nil {
+		trade.ID = uuid.New()
+	}
+
+	trade.OrderSizeCurrency = strings.ToUpper(trade.OrderSizeCurrency)
+	trade.OrderPriceCurrency = strings.ToUpper(trade.OrderPriceCurrency)
+	trade.Side = exchange.Side(strings.ToUpper(string(trade.GetSide())))
+	trade.Style = Style(strings.ToUpper(string(trade.GetStyle())))
+	trade.ScheduleStartedAt, trade.LastSliceAt = nil, nil
+	trade.TrailingPeak = decimal.Zero
+	trade.Status = StatusActive
+
+	if trade.IsLadder() {
+		trade.setLevels()
+	}
+
+	trade.OrderSizeLeft = trade.OrderSize
+
+	if err := trade.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.tradeRepo.Create(trade); err != nil {
+		return nil, err
+	}
+
+	return s.tradeRepo.FindOneById(trade.ID)
+}
+
+// Edit changes size or prices of a trade which was not canceled, size which was already ordered is kept ordered
+// so size left changes by the same amount as size
+func (s Manager) Edit(id uuid.UUID, edit Edit) (*Trade, error) {
+	return s.change(id, func(trade *Trade) error {
+		if trade.Status == StatusCanceled {
+			return fmt.Errorf("%w: canceled trade cannot be edited", ErrInvalidStatus)
+		}
+
+		if trade.IsLadder() && (edit.OrderSize != nil || edit.OrderPrice != nil) {
+			return fmt.Errorf("%w: size and price of a ladder are given by its levels", ErrInvalidTrade)
+		}
+
+		if edit.OrderSize != nil {
+			trade.OrderSizeLeft = trade.OrderSizeLeft.Add(edit.OrderSize.Sub(trade.OrderSize))
+			trade.OrderSize = *edit.OrderSize
+
+			if trade.OrderSizeLeft.IsNegative() {
+				return fmt.Errorf("%w: size %s is below the ordered size", ErrInvalidTrade, trade.OrderSize)
+			}
+		}
+
+		if edit.OrderPrice != nil {
+			trade.OrderPrice = *edit.OrderPrice
+		}
+
+		if edit.StopPrice != nil {
+			trade.StopPrice = *edit.StopPrice
+		}
+
+		if edit.StopLimitOffset != nil {
+			trade.StopLimitOffset = *edit.StopLimitOffset
+		}
+
+		if edit.TrailingDistance != nil || edit.TrailingPercent != nil || edit.TrailingActivationPrice != nil {
+			trade.TrailingPeak = decimal.Zero
+		}
+
+		if edit.TrailingDistance != nil {
+			trade.TrailingDistance = *edit.TrailingDistance
+		}
+
+		if edit.TrailingPercent != nil {
+			trade.TrailingPercent = *edit.TrailingPercent
+		}
+
+		if edit.TrailingActivationPrice != nil {
+			trade.TrailingActivationPrice = *edit.TrailingActivationPrice
+		}
+
+		return trade.Validate()
+	})
+}
+
+// Pause stops trading of an active trade until it is resumed
+func (s Manager) Pause(id uuid.UUID) (*Trade, error) {
+	return s.change(id, func(trade *Trade) error {
+		if trade.Status != StatusActive {
+			return fmt.Errorf("%w: %s trade cannot be paused", ErrInvalidStatus, trade.Status)
+		}
+
+		trade.Status = StatusPaused
+
+		return nil
+	})
+}
+
+// Resume trades a paused trade again
+func (s Manager) Resume(id uuid.UUID) (*Trade, error) {
+	return s.change(id, func(trade *Trade) error {
+		if trade.Status != StatusPaused {
+			return fmt.Errorf("%w: %s trade cannot be resumed", ErrInvalidStatus, trade.Status)
+		}
+
+		trade.Status = StatusActive
+
+		return nil
+	})
+}
+
+// Cancel stops trading for good, orders which are still open on the exchange are left to the reconciler
+func (s Manager) Cancel(id uuid.UUID) (*Trade, error) {
+	return s.change(id, func(trade *Trade) error {
+		if trade.Status == StatusCanceled {
+			return fmt.Errorf("%w: trade is already canceled", ErrInvalidStatus)
+		}
+
+		trade.Status = StatusCanceled
+
+		return nil
+	})
+}
+
+// Reset makes the whole size of the trade available again, as if it was never ordered, and starts its slice
+// schedule, trailing peak and guard over. Orders stay as they are, a trade with open orders cannot be reset
+// because their size is still reserved.
+func (s Manager) Reset(id uuid.UUID) (*Trade, error) {
+	open, err := s.orderRepo.FindOpenByTradeId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(open) > 0 {
+		return nil, fmt.Errorf("%w: trade has %d open orders", ErrInvalidStatus, len(open))
+	}
+
+	return s.change(id, func(trade *Trade) error {
+		trade.OrderSizeLeft = trade.OrderSize
+		trade.ScheduleStartedAt, trade.LastSliceAt = nil, nil
+		trade.TrailingPeak = decimal.Zero
+		trade.LastSkipKind, trade.LastSkipReason, trade.LastSkippedAt = "", "", nil
+		trade.Status = StatusActive
+
+		return nil
+	})
+}
+
+func (s Manager) change(id uuid.UUID, fn func(trade *Trade) error) (*Trade, error) {
+	trade, err := s.tradeRepo.FindOneById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(trade); err != nil {
+		return nil, err
+	}
+
+	if err := s.tradeRepo.Update(*trade); err != nil {
+		return nil, err
+	}
+
+	trade.Version++
+
+	return trade, nil
+}
@@ -0,0 +1,241 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Add(t *testing.T) {
+	db := newTestDB(t)
+	manager := NewManager(testLogger, NewRepository(db, testLogger), order.NewRepository(db, testLogger))
+
+	tests := []struct {
+		name    string
+		trade   Trade
+		wantErr bool
+	}{
+		{
+			name:  "sell trade",
+			trade: Trade{OrderSize: dec("1.5"), OrderSizeCurrency: "bnb", OrderPrice: dec("115"), OrderPriceCurrency: "usdt"},
+		},
+		{
+			name:  "buy trade",
+			trade: Trade{OrderSize: dec("1.5"), OrderSizeCurrency: "BNB", OrderPrice: dec("115"), OrderPriceCurrency: "USDT", Side: "buy"},
+		},
+		{
+			name:    "missing currency",
+			trade:   Trade{OrderSize: dec("1.5"), OrderSizeCurrency: "BNB", OrderPrice: dec("115")},
+			wantErr: true,
+		},
+		{
+			name:    "unknown side",
+			trade:   Trade{OrderSize: dec("1.5"), OrderSizeCurrency: "BNB", OrderPrice: dec("115"), OrderPriceCurrency: "USDT", Side: "HOLD"},
+			wantErr: true,
+		},
+		{
+			name:    "zero size",
+			trade:   Trade{OrderSizeCurrency: "BNB", OrderPrice: dec("115"), OrderPriceCurrency: "USDT"},
+			wantErr: true,
+		},
+		{
+			name:    "negative price",
+			trade:   Trade{OrderSize: dec("1.5"), OrderSizeCurrency: "BNB", OrderPrice: dec("-1"), OrderPriceCurrency: "USDT"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trade, err := manager.Add(tt.trade)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTrade)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			stored, err := NewRepository(db, testLogger).FindOneById(trade.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "BNBUSDT", stored.GetSymbol())
+			assert.Equal(t, StatusActive, stored.Status)
+			assertDecimal(t, "1.5", stored.OrderSizeLeft)
+			assert.Contains(t, []exchange.Side{exchange.SideSell, exchange.SideBuy}, stored.Side)
+		})
+	}
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	db := newTestDB(t)
+	tradeRepo := NewRepository(db, testLogger)
+	orderRepo := order.NewRepository(db, testLogger)
+	manager := NewManager(testLogger, tradeRepo, orderRepo)
+
+	trade := createTestTrade(t, db)
+
+	paused, err := manager.Pause(trade.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaused, paused.Status)
+
+	// paused trades are not traded
+	active, err := tradeRepo.FindAllActive(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	_, err = manager.Pause(trade.ID)
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	_, err = manager.Resume(trade.ID)
+	require.NoError(t, err)
+
+	_, err = manager.Cancel(trade.ID)
+	require.NoError(t, err)
+
+	_, err = manager.Resume(trade.ID)
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	_, err = manager.Cancel(trade.ID)
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	stored, err := tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	require.NoError(t, tradeRepo.Reserve(*stored, dec("20")))
+
+	// size of an open order is still reserved
+	require.NoError(t, orderRepo.Create(order.Order{OrderId: "1", TradeId: trade.ID, Status: exchange.OrderStatusNew}))
+
+	_, err = manager.Reset(trade.ID)
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+
+	require.NoError(t, orderRepo.Update(order.Order{OrderId: "1", TradeId: trade.ID, Status: exchange.OrderStatusFilled}))
+
+	// peak and skip reason of the trading before the reset
+	require.NoError(t, db.Model(&Trade{}).Where("id = ?", trade.ID).Updates(map[string]any{
+		"trailing_peak":    dec("130"),
+		"last_skip_reason": "spread too wide",
+		"last_skipped_at":  time.Now(),
+	}).Error)
+
+	reset, err := manager.Reset(trade.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusActive, reset.Status)
+	assertDecimal(t, "50", reset.OrderSizeLeft)
+
+	stored, err = tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "0", stored.TrailingPeak)
+	assert.Empty(t, stored.LastSkipReason)
+	assert.Nil(t, stored.LastSkippedAt)
+
+	trades, err := tradeRepo.FindAll(Filter{Status: StatusActive, Symbol: "BNBUSDT"})
+	require.NoError(t, err)
+	assert.Len(t, trades, 1)
+
+	trades, err = tradeRepo.FindAll(Filter{Symbol: "ETHUSDT"})
+	require.NoError(t, err)
+	assert.Empty(t, trades)
+}
+
+func TestManager_Edit(t *testing.T) {
+	db := newTestDB(t)
+	tradeRepo := NewRepository(db, testLogger)
+	manager := NewManager(testLogger, tradeRepo, order.NewRepository(db, testLogger))
+
+	trade := createTestTrade(t, db)
+	require.NoError(t, tradeRepo.Reserve(trade, dec("20")))
+
+	size, price := dec("40"), dec("120")
+
+	edited, err := manager.Edit(trade.ID, Edit{OrderSize: &size, OrderPrice: &price})
+	require.NoError(t, err)
+	assertDecimal(t, "40", edited.OrderSize)
+	assertDecimal(t, "20", edited.OrderSizeLeft)
+	assertDecimal(t, "120", edited.OrderPrice)
+
+	// size cannot go below what was already ordered
+	size = dec("10")
+	_, err = manager.Edit(trade.ID, Edit{OrderSize: &size})
+	assert.ErrorIs(t, err, ErrInvalidTrade)
+
+	price = dec("0")
+	_, err = manager.Edit(trade.ID, Edit{OrderPrice: &price})
+	assert.ErrorIs(t, err, ErrInvalidTrade)
+
+	// stop alone is enough
+	stop := dec("90")
+	edited, err = manager.Edit(trade.ID, Edit{OrderPrice: &price, StopPrice: &stop})
+	require.NoError(t, err)
+	assertDecimal(t, "0", edited.OrderPrice)
+	assertDecimal(t, "90", edited.StopPrice)
+
+	_, err = manager.Cancel(trade.ID)
+	require.NoError(t, err)
+
+	_, err = manager.Edit(trade.ID, Edit{})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+}
+
+func TestManager_Add_Ladder(t *testing.T) {
+	db := newTestDB(t)
+	tradeRepo := NewRepository(db, testLogger)
+	manager := NewManager(testLogger, tradeRepo, order.NewRepository(db, testLogger))
+
+	added, err := manager.Add(Trade{
+		OrderSize:          dec("3"),
+		OrderSizeCurrency:  "BNB",
+		OrderPriceCurrency: "USDT",
+		Levels: []Level{
+			{Price: dec("120"), Fraction: dec("0.5")},
+			{Price: dec("115"), Size: dec("1")},
+			{Price: dec("130"), Size: dec("0.5")},
+		},
+	})
+	require.NoError(t, err)
+	assertDecimal(t, "115", added.OrderPrice)
+	require.Len(t, added.Levels, 3)
+	assertDecimal(t, "1.5", added.Levels[0].Size)
+	assertDecimal(t, "1.5", added.Levels[0].SizeLeft)
+
+	// size is the sum of the levels when it is not given
+	added, err = manager.Add(Trade{
+		OrderSizeCurrency:  "BNB",
+		OrderPriceCurrency: "USDT",
+		Levels:             []Level{{Price: dec("115"), Size: dec("1")}, {Price: dec("120"), Size: dec("2")}},
+	})
+	require.NoError(t, err)
+	assertDecimal(t, "3", added.OrderSize)
+	assertDecimal(t, "3", added.OrderSizeLeft)
+
+	require.NoError(t, tradeRepo.Reserve(*added, dec("1.5")))
+
+	stored, err := tradeRepo.FindOneById(added.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "0", stored.Levels[0].SizeLeft)
+	assertDecimal(t, "1.5", stored.Levels[1].SizeLeft)
+
+	_, err = manager.Add(Trade{
+		OrderSize:          dec("3"),
+		OrderSizeCurrency:  "BNB",
+		OrderPriceCurrency: "USDT",
+		Levels:             []Level{{Price: dec("115"), Size: dec("1")}, {Price: dec("120"), Fraction: dec("0.5")}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidTrade, "levels have to add up to the size")
+
+	size := dec("4")
+	_, err = manager.Edit(added.ID, Edit{OrderSize: &size})
+	assert.ErrorIs(t, err, ErrInvalidTrade)
+
+	// reset gives the size back to the levels
+	reset, err := manager.Reset(added.ID)
+	require.NoError(t, err)
+
+	stored, err = tradeRepo.FindOneById(reset.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "1", stored.Levels[0].SizeLeft)
+	assertDecimal(t, "2", stored.Levels[1].SizeLeft)
+}
@@ -1,25 +1,335 @@
 package trade
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/beng90/trader/internal/exchange"
 	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+type Status string
+
+const (
+	StatusActive Status = "ACTIVE"
+	// StatusDust is a trade whose size left is below the minimum the exchange accepts, it cannot be finished
+	StatusDust Status = "DUST"
+	// StatusPaused is a trade which is not traded until it is resumed
+	StatusPaused Status = "PAUSED"
+	// StatusCanceled is a trade which is not traded anymore, it cannot be resumed
+	StatusCanceled Status = "CANCELED"
+	// StatusExpired is a trade which was not done by its ExpiresAt, its size left was never filled
+	StatusExpired Status = "EXPIRED"
+)
+
+// Trigger is the condition which made a trade order
+type Trigger string
+
+const (
+	// TriggerTakeProfit sells at or above OrderPrice, buys at or below it
+	TriggerTakeProfit Trigger = "TAKE_PROFIT"
+	// TriggerStopLoss sells at or below StopPrice, buys at or above it
+	TriggerStopLoss Trigger = "STOP_LOSS"
+	// TriggerTrailingStop sells once the bid drops by the trailing distance from TrailingPeak, buys once the ask
+	// rises by it
+	TriggerTrailingStop Trigger = "TRAILING_STOP"
+)
+
+var ErrInvalidTrade = errors.New("invalid trade")
+
 type Trade struct {
-	ID                 uuid.UUID `gorm:"primaryKey"`
-	CreatedAt          time.Time `gorm:"default:current_timestamp"`
-	UpdatedAt          time.Time `gorm:"default:current_timestamp"`
-	OrderSize          float64
-	OrderSizeLeft      float64
-	OrderSizeCurrency  string
-	OrderPrice         float64
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"default:current_timestamp"`
+	UpdatedAt time.Time `gorm:"default:current_timestamp"`
+	// amounts are stored as text, numeric columns would turn them into floats
+	OrderSize         decimal.Decimal `gorm:"type:text"`
+	OrderSizeLeft     decimal.Decimal `gorm:"type:text"`
+	OrderSizeCurrency string
+	// OrderPrice is the take-profit limit, zero when the trade has only a stop. It is the first level of a ladder.
+	OrderPrice         decimal.Decimal `gorm:"type:text"`
 	OrderPriceCurrency string
-	Orders             []*order.Order `gorm:"foreignKey:TradeId"`
+	// StopPrice is the stop-loss price, zero when the trade has no stop. StopLimitOffset keeps the stop from
+	// ordering further than the offset beyond StopPrice, zero offset orders at any price past the stop.
+	StopPrice       decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	StopLimitOffset decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	// TrailingDistance or TrailingPercent make a trailing stop, the stop follows TrailingPeak at the absolute
+	// distance or at the percentage of the peak
+	TrailingDistance decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	TrailingPercent  decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	// TrailingActivationPrice is the price trailing begins at, zero starts trailing right away
+	TrailingActivationPrice decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	// TrailingPeak is the best price seen since activation, the highest bid of a sell trade and the lowest ask
+	// of a buy trade. It is zero until trailing begins.
+	TrailingPeak decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	// Style spreads take-profit over several orders, SliceSize is the most a single order may take and
+	// SliceInterval the least time between orders. TWAP spreads the size over TwapDuration.
+	Style         Style           `gorm:"default:IMMEDIATE"`
+	SliceSize     decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	SliceInterval time.Duration   `gorm:"not null;default:0"`
+	TwapDuration  time.Duration   `gorm:"not null;default:0"`
+	// ScheduleStartedAt is the time of the first slice and LastSliceAt of the latest one, they are stored with the
+	// reserved size so a restart resumes the schedule
+	ScheduleStartedAt *time.Time
+	LastSliceAt       *time.Time
+	// MaxSpreadPercent and MaxDeviationPercent replace the limits of the Guard for the trade, zero keeps them
+	MaxSpreadPercent    decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	MaxDeviationPercent decimal.Decimal `gorm:"type:text;not null;default:'0'"`
+	// LastSkipKind is what the guard keeps the trade from ordering for since LastSkippedAt, LastSkipReason tells
+	// the prices of the first of those skips
+	LastSkipKind   SkipKind
+	LastSkipReason string
+	LastSkippedAt  *time.Time
+	// StartAt and ExpiresAt are the window the trade is traded in, nil leaves the window open
+	StartAt   *time.Time
+	ExpiresAt *time.Time
+	// Side is SELL for trades created before buying was supported
+	Side   exchange.Side `gorm:"default:SELL"`
+	Status Status        `gorm:"default:ACTIVE;index"`
+	// Version is increased on every change, writes based on an outdated read are rejected
+	Version int64          `gorm:"not null;default:0"`
+	Orders  []*order.Order `gorm:"foreignKey:TradeId"`
+	// Proceeds are set when the trade is read with its orders
+	Proceeds *Proceeds `gorm:"-" json:",omitempty"`
+	// Levels make a take-profit ladder, OrderSizeLeft is the sum of their size left
+	Levels []Level `gorm:"foreignKey:TradeId"`
 }
 
 func (m Trade) GetSymbol() string {
 	return fmt.Sprintf("%s%s", m.OrderSizeCurrency, m.OrderPriceCurrency)
 }
+
+func (m Trade) GetSide() exchange.Side {
+	if m.Side == "" {
+		return exchange.SideSell
+	}
+
+	return m.Side
+}
+
+// InWindow tells if the trade may be traded at now, its window begins at StartAt and ends before ExpiresAt
+func (m Trade) InWindow(now time.Time) bool {
+	if m.StartAt != nil && now.Before(*m.StartAt) {
+		return false
+	}
+
+	return m.ExpiresAt == nil || now.Before(*m.ExpiresAt)
+}
+
+// Execution is the price and quantity a trade can be executed at and the condition which triggered it
+type Execution struct {
+	Price   decimal.Decimal
+	Qty     decimal.Decimal
+	Trigger Trigger
+	// SizeLeft is the part of the trade size left which may be ordered, the reached levels of a ladder
+	SizeLeft decimal.Decimal
+	// Reference is the price which triggered the execution, the take-profit, stop or trailing stop price. It is
+	// the price of the level an order of a ladder is taken from first.
+	Reference decimal.Decimal
+}
+
+// Match tells if the trade can be executed on the ticker, sell trades take the bid and buy trades take the ask.
+// Take-profit is checked before the stops, stops order the whole size left.
+func (m Trade) Match(ticker orderbookticker.OrderBookTicker) (Execution, bool) {
+	e := Execution{}
+	e.Price, e.Qty = m.bookSide(ticker)
+
+	// empty side of the book
+	if !e.Price.IsPositive() || !e.Qty.IsPositive() {
+		return Execution{}, false
+	}
+
+	e.SizeLeft = m.OrderSizeLeft
+
+	if m.IsLadder() {
+		if left, reference := m.reached(e.Price); left.IsPositive() {
+			e.Trigger = TriggerTakeProfit
+			e.SizeLeft = left
+			e.Reference = reference
+
+			return e, true
+		}
+	} else if m.OrderPrice.IsPositive() && !m.worse(e.Price, m.OrderPrice) {
+		e.Trigger = TriggerTakeProfit
+		e.Reference = m.OrderPrice
+
+		return e, true
+	}
+
+	if m.StopPrice.IsPositive() && !m.worse(m.StopPrice, e.Price) {
+		if m.StopLimitOffset.IsPositive() && m.worse(e.Price, m.stopLimit()) {
+			return Execution{}, false
+		}
+
+		e.Trigger = TriggerStopLoss
+		e.Reference = m.StopPrice
+
+		return e, true
+	}
+
+	if m.IsTrailing() && m.TrailingPeak.IsPositive() && !m.worse(m.trailingStop(), e.Price) {
+		e.Trigger = TriggerTrailingStop
+		e.Reference = m.trailingStop()
+
+		return e, true
+	}
+
+	return Execution{}, false
+}
+
+// IsTrailing tells if the trade has a trailing stop
+func (m Trade) IsTrailing() bool {
+	return m.TrailingDistance.IsPositive() || m.TrailingPercent.IsPositive()
+}
+
+// Trail returns the peak after the ticker, changed is false when the peak stays or trailing did not begin yet
+func (m Trade) Trail(ticker orderbookticker.OrderBookTicker) (peak decimal.Decimal, changed bool) {
+	price, qty := m.bookSide(ticker)
+	if !m.IsTrailing() || !price.IsPositive() || !qty.IsPositive() {
+		return m.TrailingPeak, false
+	}
+
+	if !m.TrailingPeak.IsPositive() {
+		if m.TrailingActivationPrice.IsPositive() && m.worse(price, m.TrailingActivationPrice) {
+			return m.TrailingPeak, false
+		}
+
+		return price, true
+	}
+
+	if !m.worse(m.TrailingPeak, price) {
+		return m.TrailingPeak, false
+	}
+
+	return price, true
+}
+
+// bookSide is the price and quantity the trade is executed at, the bid for sells and the ask for buys
+func (m Trade) bookSide(ticker orderbookticker.OrderBookTicker) (decimal.Decimal, decimal.Decimal) {
+	if m.GetSide() == exchange.SideBuy {
+		return ticker.AskPrice, ticker.AksQty
+	}
+
+	return ticker.BidPrice, ticker.BidQty
+}
+
+// worse tells if price a is worse for the trade than b, lower for sells and higher for buys
+func (m Trade) worse(a decimal.Decimal, b decimal.Decimal) bool {
+	if m.GetSide() == exchange.SideBuy {
+		return a.GreaterThan(b)
+	}
+
+	return a.LessThan(b)
+}
+
+// bestPrice is the highest price the trade takes profit at, the top level of a ladder. It is zero for trades
+// without a take-profit price.
+func (m Trade) bestPrice() decimal.Decimal {
+	price := m.OrderPrice
+	for _, l := range m.Levels {
+		price = decimal.Max(price, l.Price)
+	}
+
+	return price
+}
+
+// stopLimit is the worst price the stop orders at
+func (m Trade) stopLimit() decimal.Decimal {
+	if m.GetSide() == exchange.SideBuy {
+		return m.StopPrice.Add(m.StopLimitOffset)
+	}
+
+	return m.StopPrice.Sub(m.StopLimitOffset)
+}
+
+// trailingStop is the price the trailing stop orders at
+func (m Trade) trailingStop() decimal.Decimal {
+	distance := m.TrailingDistance
+	if m.TrailingPercent.IsPositive() {
+		distance = m.TrailingPeak.Mul(m.TrailingPercent).Div(decimal.NewFromInt(100))
+	}
+
+	if m.GetSide() == exchange.SideBuy {
+		return m.TrailingPeak.Add(distance)
+	}
+
+	return m.TrailingPeak.Sub(distance)
+}
+
+// Validate checks a trade before it is stored
+func (m Trade) Validate() error {
+	if m.OrderSizeCurrency == "" || m.OrderPriceCurrency == "" {
+		return fmt.Errorf("%w: size and price currencies are required", ErrInvalidTrade)
+	}
+
+	if m.Side != "" && m.Side != exchange.SideSell && m.Side != exchange.SideBuy {
+		return fmt.Errorf("%w: unknown side %q", ErrInvalidTrade, m.Side)
+	}
+
+	if !m.OrderSize.IsPositive() {
+		return fmt.Errorf("%w: size %s has to be positive", ErrInvalidTrade, m.OrderSize)
+	}
+
+	if m.OrderSizeLeft.IsNegative() || m.OrderSizeLeft.GreaterThan(m.OrderSize) {
+		return fmt.Errorf("%w: size left %s has to be between 0 and size %s", ErrInvalidTrade, m.OrderSizeLeft, m.OrderSize)
+	}
+
+	if m.OrderPrice.IsNegative() || m.StopPrice.IsNegative() || m.StopLimitOffset.IsNegative() {
+		return fmt.Errorf("%w: prices cannot be negative", ErrInvalidTrade)
+	}
+
+	if m.TrailingDistance.IsNegative() || m.TrailingPercent.IsNegative() || m.TrailingActivationPrice.IsNegative() {
+		return fmt.Errorf("%w: trailing stop cannot be negative", ErrInvalidTrade)
+	}
+
+	if !m.OrderPrice.IsPositive() && !m.StopPrice.IsPositive() && !m.IsTrailing() {
+		return fmt.Errorf("%w: price, stop price or trailing stop has to be positive", ErrInvalidTrade)
+	}
+
+	if m.TrailingDistance.IsPositive() && m.TrailingPercent.IsPositive() {
+		return fmt.Errorf("%w: trailing stop is either a distance or a percentage", ErrInvalidTrade)
+	}
+
+	if m.TrailingPercent.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		return fmt.Errorf("%w: trailing percentage %s has to be below 100", ErrInvalidTrade, m.TrailingPercent)
+	}
+
+	if m.TrailingActivationPrice.IsPositive() && !m.IsTrailing() {
+		return fmt.Errorf("%w: trailing activation price needs a trailing stop", ErrInvalidTrade)
+	}
+
+	if m.StopLimitOffset.IsPositive() && !m.StopPrice.IsPositive() {
+		return fmt.Errorf("%w: stop limit offset needs a stop price", ErrInvalidTrade)
+	}
+
+	if m.StartAt != nil && m.ExpiresAt != nil && !m.ExpiresAt.After(*m.StartAt) {
+		return fmt.Errorf("%w: expiry %s has to be after start %s", ErrInvalidTrade, m.ExpiresAt, m.StartAt)
+	}
+
+	if err := m.validateGuard(); err != nil {
+		return err
+	}
+
+	if err := m.validateStyle(); err != nil {
+		return err
+	}
+
+	if err := m.validateLevels(); err != nil {
+		return err
+	}
+
+	if m.OrderPrice.IsPositive() && m.StopPrice.IsPositive() {
+		if m.GetSide() == exchange.SideBuy && m.StopPrice.LessThanOrEqual(m.OrderPrice) {
+			return fmt.Errorf("%w: stop price %s of a buy trade has to be above price %s", ErrInvalidTrade, m.StopPrice, m.OrderPrice)
+		}
+
+		if m.GetSide() != exchange.SideBuy && m.StopPrice.GreaterThanOrEqual(m.OrderPrice) {
+			return fmt.Errorf("%w: stop price %s of a sell trade has to be below price %s", ErrInvalidTrade, m.StopPrice, m.OrderPrice)
+		}
+	}
+
+	return nil
+}
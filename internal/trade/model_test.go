@@ -0,0 +1,146 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrade_Match(t *testing.T) {
+	ticker := orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec("100"), BidQty: dec("5"), AskPrice: dec("101"), AksQty: dec("3")}
+
+	tests := []struct {
+		name      string
+		trade     Trade
+		want      Trigger
+		wantPrice string
+	}{
+		{name: "sell at price", trade: Trade{OrderPrice: dec("100")}, want: TriggerTakeProfit, wantPrice: "100"},
+		{name: "sell below price", trade: Trade{OrderPrice: dec("110")}},
+		{name: "sell stop", trade: Trade{OrderPrice: dec("110"), StopPrice: dec("100")}, want: TriggerStopLoss, wantPrice: "100"},
+		{name: "sell above stop", trade: Trade{OrderPrice: dec("110"), StopPrice: dec("99")}},
+		{name: "sell stop only", trade: Trade{StopPrice: dec("105")}, want: TriggerStopLoss, wantPrice: "100"},
+		{name: "sell stop within offset", trade: Trade{StopPrice: dec("105"), StopLimitOffset: dec("5")}, want: TriggerStopLoss, wantPrice: "100"},
+		{name: "sell stop beyond offset", trade: Trade{StopPrice: dec("105"), StopLimitOffset: dec("4")}},
+		{name: "buy at price", trade: Trade{Side: exchange.SideBuy, OrderPrice: dec("101")}, want: TriggerTakeProfit, wantPrice: "101"},
+		{name: "buy stop", trade: Trade{Side: exchange.SideBuy, OrderPrice: dec("90"), StopPrice: dec("100")}, want: TriggerStopLoss, wantPrice: "101"},
+		{name: "buy below stop", trade: Trade{Side: exchange.SideBuy, OrderPrice: dec("90"), StopPrice: dec("102")}},
+		{name: "buy stop beyond offset", trade: Trade{Side: exchange.SideBuy, StopPrice: dec("100"), StopLimitOffset: dec("0.5")}},
+		{name: "trailing not active", trade: Trade{TrailingDistance: dec("1")}},
+		{name: "sell trailing stop", trade: Trade{TrailingDistance: dec("5"), TrailingPeak: dec("105")}, want: TriggerTrailingStop, wantPrice: "100"},
+		{name: "sell above trailing stop", trade: Trade{TrailingPercent: dec("4"), TrailingPeak: dec("104")}},
+		{name: "sell trailing percent", trade: Trade{TrailingPercent: dec("5"), TrailingPeak: dec("106")}, want: TriggerTrailingStop, wantPrice: "100"},
+		{name: "buy trailing stop", trade: Trade{Side: exchange.SideBuy, TrailingDistance: dec("1"), TrailingPeak: dec("100")}, want: TriggerTrailingStop, wantPrice: "101"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := tt.trade.Match(ticker)
+
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, e.Trigger)
+
+			if ok {
+				assertDecimal(t, tt.wantPrice, e.Price)
+			}
+		})
+	}
+
+	_, ok := Trade{StopPrice: dec("105")}.Match(orderbookticker.OrderBookTicker{Symbol: "BNBUSDT"})
+	assert.False(t, ok, "empty book does not trigger the stop")
+}
+
+func TestTrade_Trail(t *testing.T) {
+	ticker := func(bid string, ask string) orderbookticker.OrderBookTicker {
+		return orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec(bid), BidQty: dec("1"), AskPrice: dec(ask), AksQty: dec("1")}
+	}
+
+	tests := []struct {
+		name    string
+		trade   Trade
+		ticker  orderbookticker.OrderBookTicker
+		want    string
+		changed bool
+	}{
+		{name: "no trailing stop", trade: Trade{OrderPrice: dec("100")}, ticker: ticker("100", "101"), want: "0"},
+		{name: "begins right away", trade: Trade{TrailingDistance: dec("1")}, ticker: ticker("100", "101"), want: "100", changed: true},
+		{name: "below activation", trade: Trade{TrailingDistance: dec("1"), TrailingActivationPrice: dec("105")}, ticker: ticker("100", "101"), want: "0"},
+		{name: "at activation", trade: Trade{TrailingDistance: dec("1"), TrailingActivationPrice: dec("100")}, ticker: ticker("100", "101"), want: "100", changed: true},
+		{name: "higher bid", trade: Trade{TrailingPercent: dec("1"), TrailingPeak: dec("99")}, ticker: ticker("100", "101"), want: "100", changed: true},
+		{name: "lower bid", trade: Trade{TrailingPercent: dec("1"), TrailingPeak: dec("102")}, ticker: ticker("100", "101"), want: "102"},
+		{name: "buy lower ask", trade: Trade{Side: exchange.SideBuy, TrailingDistance: dec("1"), TrailingPeak: dec("102")}, ticker: ticker("100", "101"), want: "101", changed: true},
+		{name: "buy above activation", trade: Trade{Side: exchange.SideBuy, TrailingDistance: dec("1"), TrailingActivationPrice: dec("100")}, ticker: ticker("100", "101"), want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peak, changed := tt.trade.Trail(tt.ticker)
+
+			assert.Equal(t, tt.changed, changed)
+			assertDecimal(t, tt.want, peak)
+		})
+	}
+}
+
+func TestTrade_InWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	assert.True(t, Trade{}.InWindow(now))
+	assert.True(t, Trade{StartAt: &now, ExpiresAt: &after}.InWindow(now))
+	assert.False(t, Trade{StartAt: &after}.InWindow(now))
+	assert.False(t, Trade{ExpiresAt: &now}.InWindow(now))
+	assert.False(t, Trade{StartAt: &before, ExpiresAt: &before}.InWindow(now))
+}
+
+func TestTrade_Validate(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	valid := func(change func(t *Trade)) Trade {
+		t := Trade{OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", OrderSize: dec("1"), OrderSizeLeft: dec("1"), OrderPrice: dec("110")}
+		change(&t)
+
+		return t
+	}
+
+	tests := []struct {
+		name    string
+		trade   Trade
+		wantErr bool
+	}{
+		{name: "take profit", trade: valid(func(t *Trade) {})},
+		{name: "take profit and stop", trade: valid(func(t *Trade) { t.StopPrice = dec("90") })},
+		{name: "stop only", trade: valid(func(t *Trade) { t.OrderPrice = decimal.Zero; t.StopPrice = dec("90"); t.StopLimitOffset = dec("1") })},
+		{name: "no price", trade: valid(func(t *Trade) { t.OrderPrice = decimal.Zero }), wantErr: true},
+		{name: "sell stop above price", trade: valid(func(t *Trade) { t.StopPrice = dec("120") }), wantErr: true},
+		{name: "buy stop below price", trade: valid(func(t *Trade) { t.Side = exchange.SideBuy; t.StopPrice = dec("100") }), wantErr: true},
+		{name: "offset without stop", trade: valid(func(t *Trade) { t.StopLimitOffset = dec("1") }), wantErr: true},
+		{name: "negative stop", trade: valid(func(t *Trade) { t.StopPrice = dec("-1") }), wantErr: true},
+		{name: "trailing stop only", trade: valid(func(t *Trade) {
+			t.OrderPrice = decimal.Zero
+			t.TrailingPercent = dec("2")
+			t.TrailingActivationPrice = dec("120")
+		})},
+		{name: "trailing distance and percent", trade: valid(func(t *Trade) { t.TrailingDistance = dec("1"); t.TrailingPercent = dec("2") }), wantErr: true},
+		{name: "trailing percent too high", trade: valid(func(t *Trade) { t.TrailingPercent = dec("100") }), wantErr: true},
+		{name: "activation without trailing", trade: valid(func(t *Trade) { t.TrailingActivationPrice = dec("120") }), wantErr: true},
+		{name: "expires after start", trade: valid(func(t *Trade) { t.StartAt, t.ExpiresAt = &now, &later })},
+		{name: "expires before start", trade: valid(func(t *Trade) { t.StartAt, t.ExpiresAt = &later, &now }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trade.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTrade)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,71 +1,192 @@
 package trade
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/beng90/trader/internal/exchange"
 	"github.com/beng90/trader/internal/order"
 	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/symbol"
 	"github.com/beng90/trader/pkg/logus"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type OrderCreatorInterface interface {
-	CreateOrder(trade Trade, ticker orderbookticker.OrderBookTicker) (*string, error)
+	CreateOrder(ctx context.Context, trade Trade, ticker orderbookticker.OrderBookTicker, allocation Allocation) (*string, error)
 }
 
 type OrderCreator struct {
-	logger    logus.Logger
-	tradeRepo RepositoryInterface
-	orderRepo order.RepositoryInterface
+	logger     logus.Logger
+	tradeRepo  RepositoryInterface
+	orderRepo  order.RepositoryInterface
+	symbolRepo symbol.RepositoryInterface
+	exchange   exchange.Exchange
+	unitOfWork UnitOfWorkInterface
+	clock      Clock
 }
 
 func NewOrderCreator(
 	logger logus.Logger,
 	tradeRepo RepositoryInterface,
 	orderRepo order.RepositoryInterface,
+	symbolRepo symbol.RepositoryInterface,
+	exchange exchange.Exchange,
+	unitOfWork UnitOfWorkInterface,
+	clock Clock,
 ) OrderCreator {
 	return OrderCreator{
-		logger:    logger,
-		tradeRepo: tradeRepo,
-		orderRepo: orderRepo,
+		logger:     logger,
+		tradeRepo:  tradeRepo,
+		orderRepo:  orderRepo,
+		symbolRepo: symbolRepo,
+		exchange:   exchange,
+		unitOfWork: unitOfWork,
+		clock:      clock,
 	}
 }
 
-func (s OrderCreator) CreateOrder(trade Trade, ticker orderbookticker.OrderBookTicker) (*string, error) {
-	if ticker.BidPrice < trade.OrderPrice {
+// CreateOrder orders at most the allocated part of the ticker quantity
+func (s OrderCreator) CreateOrder(ctx context.Context, trade Trade, ticker orderbookticker.OrderBookTicker, allocation Allocation) (*string, error) {
+	execution, ok := trade.Match(ticker)
+	if !ok || !allocation.Size.IsPositive() {
 		return nil, nil
 	}
 
-	s.logger.Debugf("ORDER BOOK TICKER FOUND: Price: %.2f, Qty: %.2f\n", ticker.BidPrice, ticker.BidQty)
+	now := s.clock()
+
+	execution, ok = trade.Schedule(execution, now)
+	if !ok {
+		s.logger.Debugf("TRADE %s waits for its next slice", trade.ID)
 
-	orderSize := trade.OrderSizeLeft
-	if ticker.BidQty < trade.OrderSizeLeft {
-		orderSize = ticker.BidQty
+		return nil, nil
 	}
 
-	// TODO: create order in external system
+	price, qty := execution.Price, execution.Qty
 
-	// artificial order id from external system
-	orderId := uuid.New()
+	// executed size of an open order is not known yet, the trade waits until it is reconciled
+	open, err := s.orderRepo.FindOpenByTradeId(trade.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	o := order.Order{
-		OrderId:    orderId.String(),
-		TradeId:    trade.ID,
-		OrderSize:  orderSize,
-		OrderPrice: ticker.BidPrice,
+	if len(open) > 0 {
+		s.logger.Debugf("TRADE %s has %d open orders, skipping", trade.ID, len(open))
+
+		return nil, nil
 	}
 
-	err := s.orderRepo.Create(o)
+	s.logger.Debugf("ORDER BOOK TICKER FOUND: Side: %s, Price: %s, Qty: %s, Trigger: %s\n", trade.GetSide(), price, qty, execution.Trigger)
+
+	info, err := s.symbolRepo.FindOneBySymbol(ctx, trade.GetSymbol())
 	if err != nil {
 		return nil, err
 	}
 
-	trade.OrderSizeLeft = trade.OrderSizeLeft - orderSize
+	price = info.RoundPrice(price)
+	minSize := info.MinQuantity(price)
+	sizeLeft := info.RoundQuantity(trade.OrderSizeLeft)
+
+	if sizeLeft.LessThan(minSize) {
+		// the minimum notional depends on the price, a rest which can still be ordered at the take-profit price is
+		// kept when a stop fires below it. Trades without a take-profit price are dust only below MinQty.
+		if dustSize := info.MinQuantity(trade.bestPrice()); sizeLeft.LessThan(dustSize) {
+			s.logger.Debugf("TRADE %s size left %s is below minimum %s, marking as dust", trade.ID, trade.OrderSizeLeft, dustSize)
+
+			return nil, s.tradeRepo.SetStatus(trade.ID, StatusDust)
+		}
+
+		s.logger.Debugf("TRADE %s size left %s is below minimum %s at %s, skipping", trade.ID, trade.OrderSizeLeft, minSize, price)
+
+		return nil, nil
+	}
+
+	// a ladder orders only the levels which were reached and a slice only its part, the rest stays for later
+	orderSize := info.RoundQuantity(decimal.Min(sizeLeft, execution.SizeLeft, qty, allocation.Size))
+
+	// rest below minimum could not be ordered anymore, it is kept big enough and ordered with a later order
+	if rest := info.RoundQuantity(sizeLeft.Sub(orderSize)); rest.IsPositive() && rest.LessThan(minSize) {
+		orderSize = info.RoundQuantity(sizeLeft.Sub(minSize))
+	}
+
+	if !orderSize.IsPositive() || orderSize.LessThan(minSize) {
+		s.logger.Debugf("TRADE %s order size %s is below minimum %s, skipping", trade.ID, orderSize, minSize)
+
+		return nil, nil
+	}
+
+	// size is taken from the trade before ordering, an evaluation which read the trade concurrently loses here
+	trade.sliced(now)
+
+	err = s.tradeRepo.Reserve(trade, orderSize)
+	if errors.Is(err, ErrConcurrentUpdate) {
+		s.logger.Debugf("TRADE %s changed concurrently, skipping", trade.ID)
+
+		return nil, nil
+	}
 
-	err = s.tradeRepo.Update(trade)
 	if err != nil {
 		return nil, err
 	}
 
-	oId := orderId.String()
+	res, err := s.exchange.PlaceOrder(ctx, exchange.OrderRequest{
+		Symbol:        trade.GetSymbol(),
+		Side:          trade.GetSide(),
+		Quantity:      orderSize,
+		Price:         price,
+		ClientOrderId: uuid.NewString(),
+	})
+	if err != nil {
+		if releaseErr := s.tradeRepo.Release(trade.ID, orderSize); releaseErr != nil {
+			s.logger.Error(fmt.Errorf("size %s of trade %s stays reserved: %w", orderSize, trade.ID, releaseErr))
+		}
+
+		return nil, err
+	}
+
+	o := order.Order{
+		OrderId:            res.OrderId,
+		TradeId:            trade.ID,
+		ClientOrderId:      res.ClientOrderId,
+		Symbol:             res.Symbol,
+		Side:               trade.GetSide(),
+		Trigger:            string(execution.Trigger),
+		ReferencePrice:     execution.Reference,
+		Status:             res.Status,
+		OrderSize:          orderSize,
+		OrderPrice:         price,
+		ExecutedSize:       res.ExecutedQty,
+		CumulativeQuoteQty: res.CumulativeQuoteQty,
+		Fee:                res.Commission,
+		FeeAsset:           res.CommissionAsset,
+		OtherFees:          res.OtherCommissions,
+		AllocationPolicy:   string(allocation.Policy),
+		AllocatedSize:      allocation.Size,
+		TransactedAt:       res.TransactTime,
+		ExchangeUpdatedAt:  res.TransactTime,
+		ExchangeResponse:   res.Raw,
+	}
+
+	// order is stored together with giving back what was not executed, the rest is tried again on the next tick.
+	// Open orders keep their whole size reserved until they are closed.
+	err = s.unitOfWork.Do(func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error {
+		if err := orderRepo.Create(o); err != nil {
+			return err
+		}
+
+		if res.Status.IsOpen() || res.ExecutedQty.GreaterThanOrEqual(orderSize) {
+			return nil
+		}
+
+		return tradeRepo.Release(trade.ID, orderSize.Sub(res.ExecutedQty))
+	})
+	if err != nil {
+		s.logger.Error(fmt.Errorf("order %s was placed on exchange but not stored: %w", res.OrderId, err))
+
+		return nil, err
+	}
 
-	return &oId, nil
+	return &res.OrderId, nil
 }
@@ -1,13 +1,18 @@
 package trade
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/exchange/binancetest"
 	"github.com/beng90/trader/internal/order"
 	"github.com/beng90/trader/internal/orderbookticker"
 	"github.com/beng90/trader/pkg/logus"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,31 +20,128 @@ import (
 type OrderRepositoryMock struct {
 	mock.Mock
 
-	orderId string
-	order   order.Order
+	order order.Order
+	open  []order.Order
 }
 
 func (m *OrderRepositoryMock) Create(order order.Order) error {
 	args := m.Called(order)
 
 	m.order = order
-	m.order.OrderId = m.orderId
 
 	return args.Error(0)
 }
 
+func (m *OrderRepositoryMock) Update(order order.Order) error {
+	args := m.Called(order)
+
+	m.order = order
+
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) UpdateOpen(order order.Order) error {
+	args := m.Called(order)
+
+	m.order = order
+
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) FindAll(filter order.Filter) ([]order.Order, error) {
+	return m.open, nil
+}
+
+func (m *OrderRepositoryMock) Count(filter order.Filter) (int64, error) {
+	return int64(len(m.open)), nil
+}
+
+func (m *OrderRepositoryMock) FindAllOpen() ([]order.Order, error) {
+	return m.open, nil
+}
+
+func (m *OrderRepositoryMock) FindOpenByTradeId(tradeId uuid.UUID) ([]order.Order, error) {
+	var res []order.Order
+
+	for _, o := range m.open {
+		if o.TradeId == tradeId {
+			res = append(res, o)
+		}
+	}
+
+	return res, nil
+}
+
+// SymbolRepositoryMock returns info of every symbol, without filters unless info is set
+type SymbolRepositoryMock struct {
+	info exchange.SymbolInfo
+}
+
+func (m *SymbolRepositoryMock) FindOneBySymbol(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	info := m.info
+	info.Symbol = symbol
+
+	return &info, nil
+}
+
+// UnitOfWorkMock passes the repository mocks to fn, committed tells if fn succeeded
+type UnitOfWorkMock struct {
+	tradeRepo *TradeRepositoryMock
+	orderRepo *OrderRepositoryMock
+	committed bool
+}
+
+func (m *UnitOfWorkMock) Do(fn func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error) error {
+	err := fn(m.tradeRepo, m.orderRepo)
+	m.committed = err == nil
+
+	return err
+}
+
+// ExchangeMock executes every order up to executedQty, all of it when executedQty is not set
+type ExchangeMock struct {
+	exchange.Exchange
+
+	orderId     string
+	executedQty decimal.Decimal
+}
+
+func (m *ExchangeMock) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (*exchange.OrderResponse, error) {
+	executedQty := req.Quantity
+	if m.executedQty.IsPositive() && m.executedQty.LessThan(req.Quantity) {
+		executedQty = m.executedQty
+	}
+
+	return &exchange.OrderResponse{
+		OrderId:            m.orderId,
+		ClientOrderId:      req.ClientOrderId,
+		Symbol:             req.Symbol,
+		Side:               req.Side,
+		Status:             exchange.OrderStatusFilled,
+		Price:              req.Price,
+		OrigQty:            req.Quantity,
+		ExecutedQty:        executedQty,
+		CumulativeQuoteQty: executedQty.Mul(req.Price),
+		Raw:                "{}",
+	}, nil
+}
+
 func TestOrderCreator_CreateOrder(t *testing.T) {
 	tradeRepo := &TradeRepositoryMock{}
 
-	orderId := uuid.NewString()
-	orderRepo := &OrderRepositoryMock{orderId: orderId}
+	orderId := "12345"
+	orderRepo := &OrderRepositoryMock{}
 
 	orderRepo.
 		On("Create", mock.Anything).
 		Return(nil)
 
 	tradeRepo.
-		On("Update", mock.Anything).
+		On("Reserve", mock.Anything, mock.Anything).
+		Return(nil)
+
+	tradeRepo.
+		On("Release", mock.Anything, mock.Anything).
 		Return(nil)
 
 	type fields struct {
@@ -47,6 +149,7 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 		orderBookTickerRepo orderbookticker.RepositoryInterface
 		tradeRepo           *TradeRepositoryMock
 		orderRepo           *OrderRepositoryMock
+		exchange            *ExchangeMock
 	}
 
 	type args struct {
@@ -74,23 +177,24 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 				logger:    testLogger,
 				tradeRepo: tradeRepo,
 				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId},
 			},
 			args: args{
 				trade: Trade{
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
 				ticker: orderbookticker.OrderBookTicker{
 					Symbol:   "BNBUSDT",
-					BidPrice: 115,
-					BidQty:   50,
+					BidPrice: dec("115"),
+					BidQty:   dec("50"),
 				},
 			},
 			wantErr: false,
@@ -99,20 +203,30 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      0, // IMPORTANT
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("0"), // IMPORTANT
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
 				order: order.Order{
-					OrderId:    orderId,
-					CreatedAt:  time.Time{},
-					UpdatedAt:  time.Time{},
-					TradeId:    tradeId,
-					OrderSize:  50,
-					OrderPrice: 115,
+					OrderId:            orderId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					TradeId:            tradeId,
+					Symbol:             "BNBUSDT",
+					Side:               exchange.SideSell,
+					Trigger:            "TAKE_PROFIT",
+					ReferencePrice:     dec("111"),
+					Status:             exchange.OrderStatusFilled,
+					OrderSize:          dec("50"),
+					OrderPrice:         dec("115"),
+					ExecutedSize:       dec("50"),
+					CumulativeQuoteQty: dec("5750"),
+					AllocationPolicy:   "fifo",
+					AllocatedSize:      dec("50"),
+					ExchangeResponse:   "{}",
 				},
 			},
 		},
@@ -122,23 +236,24 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 				logger:    testLogger,
 				tradeRepo: tradeRepo,
 				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId},
 			},
 			args: args{
 				trade: Trade{
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
 				ticker: orderbookticker.OrderBookTicker{
 					Symbol:   "BNBUSDT",
-					BidPrice: 130,
-					BidQty:   22,
+					BidPrice: dec("130"),
+					BidQty:   dec("22"),
 				},
 			},
 			wantErr: false,
@@ -147,46 +262,225 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      28, // IMPORTANT
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("28"), // IMPORTANT
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
 				order: order.Order{
-					OrderId:    orderId,
-					CreatedAt:  time.Time{},
-					UpdatedAt:  time.Time{},
-					TradeId:    tradeId,
-					OrderSize:  22,
-					OrderPrice: 130,
+					OrderId:            orderId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					TradeId:            tradeId,
+					Symbol:             "BNBUSDT",
+					Side:               exchange.SideSell,
+					Trigger:            "TAKE_PROFIT",
+					ReferencePrice:     dec("111"),
+					Status:             exchange.OrderStatusFilled,
+					OrderSize:          dec("22"),
+					OrderPrice:         dec("130"),
+					ExecutedSize:       dec("22"),
+					CumulativeQuoteQty: dec("2860"),
+					AllocationPolicy:   "fifo",
+					AllocatedSize:      dec("22"),
+					ExchangeResponse:   "{}",
 				},
 			},
 		},
+		{
+			name: "trade partially executed by exchange",
+			fields: fields{
+				logger:    testLogger,
+				tradeRepo: tradeRepo,
+				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId, executedQty: dec("15")},
+			},
+			args: args{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Orders:             nil,
+				},
+				ticker: orderbookticker.OrderBookTicker{
+					Symbol:   "BNBUSDT",
+					BidPrice: dec("115"),
+					BidQty:   dec("50"),
+				},
+			},
+			wantErr: false,
+			want: want{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("35"), // IMPORTANT
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Orders:             nil,
+				},
+				order: order.Order{
+					OrderId:            orderId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					TradeId:            tradeId,
+					Symbol:             "BNBUSDT",
+					Side:               exchange.SideSell,
+					Trigger:            "TAKE_PROFIT",
+					ReferencePrice:     dec("111"),
+					Status:             exchange.OrderStatusFilled,
+					OrderSize:          dec("50"),
+					OrderPrice:         dec("115"),
+					ExecutedSize:       dec("15"),
+					CumulativeQuoteQty: dec("1725"),
+					AllocationPolicy:   "fifo",
+					AllocatedSize:      dec("50"),
+					ExchangeResponse:   "{}",
+				},
+			},
+		},
+		{
+			name: "buy trade bought for one ticker",
+			fields: fields{
+				logger:    testLogger,
+				tradeRepo: tradeRepo,
+				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId},
+			},
+			args: args{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Side:               exchange.SideBuy,
+					Orders:             nil,
+				},
+				ticker: orderbookticker.OrderBookTicker{
+					Symbol:   "BNBUSDT",
+					BidPrice: dec("109"),
+					BidQty:   dec("100"),
+					AskPrice: dec("110"),
+					AksQty:   dec("30"),
+				},
+			},
+			wantErr: false,
+			want: want{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("20"), // IMPORTANT
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Side:               exchange.SideBuy,
+					Orders:             nil,
+				},
+				order: order.Order{
+					OrderId:            orderId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					TradeId:            tradeId,
+					Symbol:             "BNBUSDT",
+					Side:               exchange.SideBuy,
+					Trigger:            "TAKE_PROFIT",
+					ReferencePrice:     dec("111"),
+					Status:             exchange.OrderStatusFilled,
+					OrderSize:          dec("30"),
+					OrderPrice:         dec("110"),
+					ExecutedSize:       dec("30"),
+					CumulativeQuoteQty: dec("3300"),
+					AllocationPolicy:   "fifo",
+					AllocatedSize:      dec("30"),
+					ExchangeResponse:   "{}",
+				},
+			},
+		},
+		{
+			name: "ask above buy trade price",
+			fields: fields{
+				logger:    testLogger,
+				tradeRepo: tradeRepo,
+				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId},
+			},
+			args: args{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Side:               exchange.SideBuy,
+					Orders:             nil,
+				},
+				ticker: orderbookticker.OrderBookTicker{
+					Symbol:   "BNBUSDT",
+					BidPrice: dec("115"),
+					BidQty:   dec("100"),
+					AskPrice: dec("116"),
+					AksQty:   dec("30"),
+				},
+			},
+			wantErr: false,
+			want: want{
+				trade: Trade{
+					ID:                 tradeId,
+					CreatedAt:          time.Time{},
+					UpdatedAt:          time.Time{},
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"), // IMPORTANT
+					OrderSizeCurrency:  "BNB",
+					OrderPrice:         dec("111"),
+					OrderPriceCurrency: "USDT",
+					Side:               exchange.SideBuy,
+					Orders:             nil,
+				},
+				order: order.Order{},
+			},
+		},
 		{
 			name: "no ticker for trade",
 			fields: fields{
 				logger:    testLogger,
 				tradeRepo: tradeRepo,
 				orderRepo: orderRepo,
+				exchange:  &ExchangeMock{orderId: orderId},
 			},
 			args: args{
 				trade: Trade{
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
 				ticker: orderbookticker.OrderBookTicker{
 					Symbol:   "BNBUSDT",
-					BidPrice: 110,
-					BidQty:   50,
+					BidPrice: dec("110"),
+					BidQty:   dec("50"),
 				},
 			},
 			wantErr: false,
@@ -195,10 +489,10 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 					ID:                 tradeId,
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50, // IMPORTANT
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"), // IMPORTANT
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
@@ -214,19 +508,317 @@ func TestOrderCreator_CreateOrder(t *testing.T) {
 			tt.fields.orderRepo.order = order.Order{}
 			// tt.fields.orderRepo.orderId = ""
 
-			s := NewOrderCreator(testLogger, tradeRepo, orderRepo)
+			s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{}, tt.fields.exchange, &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}, time.Now)
 
-			oId, err := s.CreateOrder(tt.args.trade, tt.args.ticker)
+			allocation := AllocationFIFO.Allocate([]Trade{tt.args.trade}, tt.args.ticker, time.Now())[tt.args.trade.ID]
+
+			oId, err := s.CreateOrder(context.Background(), tt.args.trade, tt.args.ticker, allocation)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
 			if tt.want.order.OrderId != "" {
 				assert.NotNil(t, oId)
+				// client order id is generated for every order
+				assert.NotEmpty(t, tt.fields.orderRepo.order.ClientOrderId)
+				tt.fields.orderRepo.order.ClientOrderId = ""
+			}
+
+			assertJSONEqual(t, tt.want.trade, tt.fields.tradeRepo.trade)
+			assertJSONEqual(t, tt.want.order, tt.fields.orderRepo.order)
+		})
+	}
+}
+
+func TestOrderCreator_CreateOrder_SymbolFilters(t *testing.T) {
+	lotSize := exchange.SymbolInfo{StepSize: dec("0.01"), MinQty: dec("0.1")}
+	notional := exchange.SymbolInfo{StepSize: dec("0.01"), MinQty: dec("0.01"), MinNotional: dec("10")}
+
+	tests := []struct {
+		name          string
+		info          exchange.SymbolInfo
+		sizeLeft      string
+		stopPrice     string
+		bidPrice      string
+		bidQty        string
+		wantOrderSize string
+		wantStatus    Status
+	}{
+		{
+			name:          "order size rounded down to step size",
+			info:          lotSize,
+			sizeLeft:      "5",
+			bidQty:        "1.239",
+			wantOrderSize: "1.23",
+		},
+		{
+			name:     "order below minimum skipped",
+			info:     lotSize,
+			sizeLeft: "5",
+			bidQty:   "0.05",
+		},
+		{
+			name:          "rest below minimum left for a later order",
+			info:          lotSize,
+			sizeLeft:      "1.05",
+			bidQty:        "1",
+			wantOrderSize: "0.95",
+		},
+		{
+			name:          "rest below minimum merged into the order",
+			info:          lotSize,
+			sizeLeft:      "1.05",
+			bidQty:        "2",
+			wantOrderSize: "1.05",
+		},
+		{
+			name:     "rest cannot be split from too small trade",
+			info:     lotSize,
+			sizeLeft: "0.15",
+			bidQty:   "0.1",
+		},
+		{
+			name:       "size left below minimum quantity is dust",
+			info:       lotSize,
+			sizeLeft:   "0.05",
+			bidQty:     "1",
+			wantStatus: StatusDust,
+		},
+		{
+			name:       "size left below minimum notional is dust",
+			info:       notional,
+			sizeLeft:   "0.08",
+			bidQty:     "1",
+			wantStatus: StatusDust,
+		},
+		{
+			// 0.1 is below the minimum of 0.12 at the stop, it is not below 0.1 at the price of 111
+			name:      "size left below minimum notional at a stop waits",
+			info:      notional,
+			sizeLeft:  "0.1",
+			stopPrice: "95",
+			bidPrice:  "90",
+			bidQty:    "1",
+		},
+		{
+			name:       "size left below minimum notional at the price is dust at a stop",
+			info:       notional,
+			sizeLeft:   "0.08",
+			stopPrice:  "95",
+			bidPrice:   "90",
+			bidQty:     "1",
+			wantStatus: StatusDust,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trade := Trade{
+				ID:                 uuid.New(),
+				OrderSize:          dec(tt.sizeLeft),
+				OrderSizeLeft:      dec(tt.sizeLeft),
+				OrderSizeCurrency:  "BNB",
+				OrderPrice:         dec("111"),
+				OrderPriceCurrency: "USDT",
+				Status:             StatusActive,
+			}
+
+			if tt.stopPrice != "" {
+				trade.StopPrice = dec(tt.stopPrice)
+			}
+
+			bidPrice := "115"
+			if tt.bidPrice != "" {
+				bidPrice = tt.bidPrice
 			}
 
-			assert.Equal(t, tt.want.trade, tt.fields.tradeRepo.trade)
-			assert.Equal(t, tt.want.order, tt.fields.orderRepo.order)
+			tradeRepo := &TradeRepositoryMock{trade: trade}
+			tradeRepo.On("Reserve", mock.Anything, mock.Anything).Return(nil)
+			tradeRepo.On("Release", mock.Anything, mock.Anything).Return(nil)
+			tradeRepo.On("SetStatus", trade.ID, StatusDust).Return(nil)
+
+			orderRepo := &OrderRepositoryMock{}
+			orderRepo.On("Create", mock.Anything).Return(nil)
+
+			s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{info: tt.info}, &ExchangeMock{orderId: "1"}, &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}, time.Now)
+
+			ticker := orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec(bidPrice), BidQty: dec(tt.bidQty)}
+
+			_, err := s.CreateOrder(context.Background(), trade, ticker, Allocation{Policy: AllocationFIFO, Size: dec(tt.bidQty)})
+			assert.NoError(t, err)
+
+			if tt.wantOrderSize != "" {
+				assertDecimal(t, tt.wantOrderSize, orderRepo.order.OrderSize)
+			} else {
+				orderRepo.AssertNotCalled(t, "Create", mock.Anything)
+			}
+
+			wantStatus := StatusActive
+			if tt.wantStatus != "" {
+				wantStatus = tt.wantStatus
+			}
+
+			assert.Equal(t, wantStatus, tradeRepo.trade.Status)
 		})
 	}
 }
+
+func TestOrderCreator_CreateOrder_TradeReleaseFailed(t *testing.T) {
+	tradeRepo := &TradeRepositoryMock{}
+	tradeRepo.
+		On("Reserve", mock.Anything, mock.Anything).
+		Return(nil)
+	tradeRepo.
+		On("Release", mock.Anything, mock.Anything).
+		Return(errors.New("database is locked"))
+
+	orderRepo := &OrderRepositoryMock{}
+	orderRepo.
+		On("Create", mock.Anything).
+		Return(nil)
+
+	unitOfWork := &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}
+	s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{}, &ExchangeMock{orderId: "1", executedQty: dec("10")}, unitOfWork, time.Now)
+
+	oId, err := s.CreateOrder(context.Background(), Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}, orderbookticker.OrderBookTicker{
+		Symbol:   "BNBUSDT",
+		BidPrice: dec("115"),
+		BidQty:   dec("30"),
+	}, Allocation{Policy: AllocationFIFO, Size: dec("30")})
+	assert.Error(t, err)
+	assert.Nil(t, oId)
+	assert.False(t, unitOfWork.committed)
+}
+
+func TestOrderCreator_CreateOrder_OpenOrder(t *testing.T) {
+	tradeId := uuid.New()
+
+	tradeRepo := &TradeRepositoryMock{}
+	orderRepo := &OrderRepositoryMock{open: []order.Order{
+		{OrderId: "1", TradeId: tradeId, Status: exchange.OrderStatusPartiallyFilled},
+	}}
+
+	s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{}, &ExchangeMock{orderId: "2"}, &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}, time.Now)
+
+	oId, err := s.CreateOrder(context.Background(), Trade{
+		ID:                 tradeId,
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}, orderbookticker.OrderBookTicker{
+		Symbol:   "BNBUSDT",
+		BidPrice: dec("115"),
+		BidQty:   dec("30"),
+	}, Allocation{Policy: AllocationFIFO, Size: dec("30")})
+	assert.NoError(t, err)
+	assert.Nil(t, oId)
+
+	// nothing is ordered until the open order is reconciled
+	orderRepo.AssertNotCalled(t, "Create", mock.Anything)
+	tradeRepo.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything)
+}
+
+func TestOrderCreator_CreateOrder_Binance(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetLiquidity("BNBUSDT", dec("20"))
+
+	tradeRepo := &TradeRepositoryMock{}
+	tradeRepo.
+		On("Reserve", mock.Anything, mock.Anything).
+		Return(nil)
+	tradeRepo.
+		On("Release", mock.Anything, mock.Anything).
+		Return(nil)
+
+	orderRepo := &OrderRepositoryMock{}
+	orderRepo.
+		On("Create", mock.Anything).
+		Return(nil)
+
+	binanceExchange := exchange.NewBinance(server.NewClient(), testLogger)
+	s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{}, binanceExchange, &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}, time.Now)
+
+	trade := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}
+
+	oId, err := s.CreateOrder(context.Background(), trade, orderbookticker.OrderBookTicker{
+		Symbol:   "BNBUSDT",
+		BidPrice: dec("115.5"),
+		BidQty:   dec("30"),
+	}, Allocation{Policy: AllocationFIFO, Size: dec("30")})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", *oId)
+
+	sent := server.Orders()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "BNBUSDT", sent[0].Symbol)
+	assert.Equal(t, "SELL", string(sent[0].Side))
+	assert.Equal(t, "LIMIT", string(sent[0].Type))
+	assert.Equal(t, "30", sent[0].OrigQuantity)
+	assert.Equal(t, "115.5", sent[0].Price)
+
+	assert.Equal(t, "1", orderRepo.order.OrderId)
+	assert.Equal(t, sent[0].ClientOrderID, orderRepo.order.ClientOrderId)
+	assertDecimal(t, "30", orderRepo.order.OrderSize)
+	assertDecimal(t, "20", orderRepo.order.ExecutedSize)
+	assert.Contains(t, orderRepo.order.ExchangeResponse, `"orderId":1`)
+
+	assertDecimal(t, "30", tradeRepo.trade.OrderSizeLeft)
+}
+
+func TestOrderCreator_CreateOrder_BinanceError(t *testing.T) {
+	server := binancetest.NewServer()
+	defer server.Close()
+
+	server.SetError(-2010, "Account has insufficient balance for requested action.")
+
+	tradeRepo := &TradeRepositoryMock{}
+	tradeRepo.
+		On("Reserve", mock.Anything, mock.Anything).
+		Return(nil)
+	tradeRepo.
+		On("Release", mock.Anything, mock.Anything).
+		Return(nil)
+
+	orderRepo := &OrderRepositoryMock{}
+
+	binanceExchange := exchange.NewBinance(server.NewClient(), testLogger)
+	s := NewOrderCreator(testLogger, tradeRepo, orderRepo, &SymbolRepositoryMock{}, binanceExchange, &UnitOfWorkMock{tradeRepo: tradeRepo, orderRepo: orderRepo}, time.Now)
+
+	oId, err := s.CreateOrder(context.Background(), Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}, orderbookticker.OrderBookTicker{
+		Symbol:   "BNBUSDT",
+		BidPrice: dec("115"),
+		BidQty:   dec("30"),
+	}, Allocation{Policy: AllocationFIFO, Size: dec("30")})
+	assert.Error(t, err)
+	assert.Nil(t, oId)
+
+	// nothing is stored when the exchange rejects the order and the reserved size is given back
+	orderRepo.AssertNotCalled(t, "Create", mock.Anything)
+	tradeRepo.AssertCalled(t, "Release", mock.Anything, decimalArg("30"))
+	assertDecimal(t, "50", tradeRepo.trade.OrderSizeLeft)
+}
@@ -0,0 +1,69 @@
+package trade
+
+import (
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/shopspring/decimal"
+)
+
+// Proceeds are what orders of a trade were executed for, in the quote currency
+type Proceeds struct {
+	// Gross is the quote received by sells or paid by buys
+	Gross decimal.Decimal
+	// Fees are fees charged in the quote or the base currency, base fees are valued at the price of their order
+	Fees decimal.Decimal
+	// Net is Gross less Fees for sells and Gross with Fees for buys
+	Net decimal.Decimal
+	// OtherFees are fees charged in other assets, e.g. BNB, they are not in Fees
+	OtherFees map[string]decimal.Decimal `json:",omitempty"`
+	// UnknownFees counts executed orders whose fee is not known
+	UnknownFees int `json:",omitempty"`
+}
+
+// NewProceeds sums executed orders of trade t
+func NewProceeds(t Trade, orders []order.Order) Proceeds {
+	res := Proceeds{}
+
+	for _, o := range orders {
+		if !o.ExecutedSize.IsPositive() {
+			continue
+		}
+
+		res.Gross = res.Gross.Add(o.CumulativeQuoteQty)
+
+		if o.FeeAsset == "" {
+			res.UnknownFees++
+
+			continue
+		}
+
+		res.addFee(t, o, o.FeeAsset, o.Fee)
+
+		for asset, fee := range o.OtherFees {
+			res.addFee(t, o, asset, fee)
+		}
+	}
+
+	res.Net = res.Gross.Sub(res.Fees)
+	if t.GetSide() == exchange.SideBuy {
+		res.Net = res.Gross.Add(res.Fees)
+	}
+
+	return res
+}
+
+// addFee adds a fee of order o in asset, fees in the base currency are valued at the average price of the order
+func (p *Proceeds) addFee(t Trade, o order.Order, asset string, fee decimal.Decimal) {
+	switch asset {
+	case t.OrderPriceCurrency:
+		p.Fees = p.Fees.Add(fee)
+	case t.OrderSizeCurrency:
+		p.Fees = p.Fees.Add(fee.Mul(o.CumulativeQuoteQty).Div(o.ExecutedSize))
+	default:
+		if p.OtherFees == nil {
+			p.OtherFees = map[string]decimal.Decimal{}
+		}
+
+		p.OtherFees[asset] = p.OtherFees[asset].Add(fee)
+	}
+}
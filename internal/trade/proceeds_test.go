@@ -0,0 +1,37 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProceeds(t *testing.T) {
+	sell := Trade{OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT"}
+	buy := Trade{OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", Side: exchange.SideBuy}
+
+	orders := []order.Order{
+		{ExecutedSize: dec("2"), CumulativeQuoteQty: dec("230"), Fee: dec("0.23"), FeeAsset: "USDT"},
+		// a base fee is valued at the average price of its order, 0.01 BNB at 120
+		{ExecutedSize: dec("1"), CumulativeQuoteQty: dec("120"), Fee: dec("0.01"), FeeAsset: "BNB"},
+		{ExecutedSize: dec("1"), CumulativeQuoteQty: dec("110"), Fee: dec("0.002"), FeeAsset: "ETH"},
+		// fills charged in other assets once the first one ran out
+		{ExecutedSize: dec("1"), CumulativeQuoteQty: dec("100"), Fee: dec("0.001"), FeeAsset: "ETH", OtherFees: order.Fees{"USDT": dec("0.05")}},
+		{ExecutedSize: dec("1"), CumulativeQuoteQty: dec("100")},
+		// orders which were not executed are left out
+		{ExecutedSize: dec("0"), FeeAsset: "USDT"},
+	}
+
+	p := NewProceeds(sell, orders)
+	assertDecimal(t, "660", p.Gross)
+	assertDecimal(t, "1.48", p.Fees)
+	assertDecimal(t, "658.52", p.Net)
+	assertDecimal(t, "0.003", p.OtherFees["ETH"])
+	assert.Equal(t, 1, p.UnknownFees)
+
+	// fees are paid on top of what buys cost
+	p = NewProceeds(buy, orders)
+	assertDecimal(t, "661.48", p.Net)
+}
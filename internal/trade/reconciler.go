@@ -0,0 +1,113 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/pkg/logus"
+)
+
+// Reconciler keeps open orders in sync with the exchange
+type Reconciler struct {
+	logger     logus.Logger
+	orderRepo  order.RepositoryInterface
+	exchange   exchange.Exchange
+	unitOfWork UnitOfWorkInterface
+}
+
+func NewReconciler(
+	logger logus.Logger,
+	orderRepo order.RepositoryInterface,
+	exchange exchange.Exchange,
+	unitOfWork UnitOfWorkInterface,
+) Reconciler {
+	return Reconciler{
+		logger:     logger,
+		orderRepo:  orderRepo,
+		exchange:   exchange,
+		unitOfWork: unitOfWork,
+	}
+}
+
+// Run reconciles open orders every interval until ctx is done. Reconciling is given workCtx, so an order state
+// which is being stored is not interrupted when ctx is done.
+func (s Reconciler) Run(ctx context.Context, workCtx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reconcile(workCtx); err != nil {
+				s.logger.Error(err)
+			}
+		}
+	}
+}
+
+// Reconcile queries every open order, a failing order does not stop the others
+func (s Reconciler) Reconcile(ctx context.Context) error {
+	orders, err := s.orderRepo.FindAllOpen()
+	if err != nil {
+		return err
+	}
+
+	for i := range orders {
+		if err := s.reconcile(ctx, orders[i]); err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	return nil
+}
+
+// reconcile stores the exchange state of the order
+func (s Reconciler) reconcile(ctx context.Context, o order.Order) error {
+	res, err := s.exchange.QueryOrder(ctx, o.Symbol, o.OrderId)
+	if err != nil {
+		return err
+	}
+
+	if res.Status == o.Status && res.ExecutedQty.Equal(o.ExecutedSize) {
+		return nil
+	}
+
+	s.logger.Debugf("ORDER %s changed: Status: %s -> %s, Executed: %s -> %s", o.OrderId, o.Status, res.Status, o.ExecutedSize, res.ExecutedQty)
+
+	return storeOrderState(s.unitOfWork, o, res)
+}
+
+// storeOrderState stores the exchange state of the order. The whole order size was reserved on the trade when it
+// was placed, what was not executed is given back once the order is closed. The state is stored only while the
+// order is open in the database, so an order closed by the reconciler and the expirer at once is given back once.
+func storeOrderState(unitOfWork UnitOfWorkInterface, o order.Order, res *exchange.OrderResponse) error {
+	o.Status = res.Status
+	o.ExecutedSize = res.ExecutedQty
+	o.CumulativeQuoteQty = res.CumulativeQuoteQty
+	o.ExchangeUpdatedAt = res.TransactTime
+
+	// a fee which could not be read is kept as it was
+	if res.CommissionAsset != "" {
+		o.Fee, o.FeeAsset, o.OtherFees = res.Commission, res.CommissionAsset, res.OtherCommissions
+	}
+
+	return unitOfWork.Do(func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error {
+		err := orderRepo.UpdateOpen(o)
+		if errors.Is(err, order.ErrNotOpen) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if o.Status.IsOpen() || o.ExecutedSize.GreaterThanOrEqual(o.OrderSize) {
+			return nil
+		}
+
+		return tradeRepo.Release(o.TradeId, o.OrderSize.Sub(o.ExecutedSize))
+	})
+}
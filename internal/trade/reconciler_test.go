@@ -0,0 +1,166 @@
+package trade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(exchange.SymbolInfo{Symbol: "BNBUSDT", BaseAsset: "BNB", QuoteAsset: "USDT"})
+	sim.SetFees(exchange.FeeSchedule{MakerPercent: dec("0.1"), TakerPercent: dec("0.2")})
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("10")}}, nil)
+
+	orderRepo := order.NewRepository(db, testLogger)
+	reconciler := NewReconciler(testLogger, orderRepo, sim, NewUnitOfWork(db, testLogger))
+
+	tradeId := uuid.New()
+	require.NoError(t, db.Create(&Trade{
+		ID:                 tradeId,
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}).Error)
+
+	// order resting in the book after the first 10 were executed on placement, its whole size is reserved on the trade
+	res, err := sim.PlaceOrder(ctx, exchange.OrderRequest{
+		Symbol:      "BNBUSDT",
+		Side:        exchange.SideSell,
+		Quantity:    dec("40"),
+		Price:       dec("112"),
+		TimeInForce: exchange.TimeInForceGTC,
+	})
+	require.NoError(t, err)
+	require.Equal(t, exchange.OrderStatusPartiallyFilled, res.Status)
+
+	require.NoError(t, orderRepo.Create(order.Order{
+		OrderId:            res.OrderId,
+		TradeId:            tradeId,
+		Symbol:             "BNBUSDT",
+		Side:               exchange.SideSell,
+		Status:             res.Status,
+		OrderSize:          dec("40"),
+		OrderPrice:         dec("112"),
+		ExecutedSize:       res.ExecutedQty,
+		CumulativeQuoteQty: res.CumulativeQuoteQty,
+	}))
+
+	stored := func() (Trade, order.Order) {
+		var tr Trade
+		require.NoError(t, db.First(&tr, "id = ?", tradeId).Error)
+
+		var o order.Order
+		require.NoError(t, db.First(&o, "order_id = ?", res.OrderId).Error)
+
+		return tr, o
+	}
+
+	// nothing changed on the exchange
+	require.NoError(t, reconciler.Reconcile(ctx))
+	tr, o := stored()
+	assertDecimal(t, "10", tr.OrderSizeLeft)
+	assert.Equal(t, exchange.OrderStatusPartiallyFilled, o.Status)
+
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("113"), Quantity: dec("25")}}, nil)
+
+	require.NoError(t, reconciler.Reconcile(ctx))
+	tr, o = stored()
+	assertDecimal(t, "10", tr.OrderSizeLeft)
+	assert.Equal(t, exchange.OrderStatusPartiallyFilled, o.Status)
+	assertDecimal(t, "35", o.ExecutedSize)
+	assertDecimal(t, "3975", o.CumulativeQuoteQty)
+	// taker fee of the fill on placement and maker fee of the resting fill
+	assertDecimal(t, "5.125", o.Fee)
+	assert.Equal(t, "USDT", o.FeeAsset)
+
+	_, err = sim.CancelOrder(ctx, "BNBUSDT", res.OrderId)
+	require.NoError(t, err)
+
+	// size which was not executed is given back once the order is closed
+	require.NoError(t, reconciler.Reconcile(ctx))
+	tr, o = stored()
+	assertDecimal(t, "15", tr.OrderSizeLeft)
+	assert.Equal(t, exchange.OrderStatusCanceled, o.Status)
+
+	// closed orders are not queried anymore
+	open, err := orderRepo.FindAllOpen()
+	require.NoError(t, err)
+	assert.Empty(t, open)
+}
+
+func TestReconciler_Reconcile_CanceledByExpirer(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("10")}}, nil)
+
+	tradeRepo := NewRepository(db, testLogger)
+	orderRepo := order.NewRepository(db, testLogger)
+	unitOfWork := NewUnitOfWork(db, testLogger)
+	reconciler := NewReconciler(testLogger, orderRepo, sim, unitOfWork)
+	expirer := NewExpirer(testLogger, tradeRepo, orderRepo, sim, unitOfWork, time.Now)
+
+	tr := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}
+	require.NoError(t, db.Create(&tr).Error)
+
+	// 10 of 40 executed on placement, the rest rests in the book and is reserved on the trade
+	res, err := sim.PlaceOrder(ctx, exchange.OrderRequest{
+		Symbol:      "BNBUSDT",
+		Side:        exchange.SideSell,
+		Quantity:    dec("40"),
+		Price:       dec("112"),
+		TimeInForce: exchange.TimeInForceGTC,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, orderRepo.Create(order.Order{
+		OrderId:      res.OrderId,
+		TradeId:      tr.ID,
+		Symbol:       "BNBUSDT",
+		Side:         exchange.SideSell,
+		Status:       res.Status,
+		OrderSize:    dec("40"),
+		OrderPrice:   dec("112"),
+		ExecutedSize: res.ExecutedQty,
+	}))
+
+	// the reconciler reads the order open, the expirer cancels it before the reconciler queries it
+	open, err := orderRepo.FindAllOpen()
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+
+	require.NoError(t, expirer.expire(ctx, tr))
+	require.NoError(t, reconciler.reconcile(ctx, open[0]))
+
+	// the unfilled 30 are given back once
+	stored, err := tradeRepo.FindOneById(tr.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "40", stored.OrderSizeLeft)
+
+	orders, err := orderRepo.FindAll(order.Filter{TradeId: tr.ID})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, exchange.OrderStatusCanceled, orders[0].Status)
+	assertDecimal(t, "10", orders[0].ExecutedSize)
+}
@@ -1,13 +1,43 @@
 package trade
 
 import (
+	"errors"
+	"time"
+
+	"github.com/beng90/trader/internal/order"
 	"github.com/beng90/trader/pkg/logus"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+var ErrConcurrentUpdate = errors.New("trade was changed since it was read")
+
+// releaseAttempts is how many times Release reads the trade again when it loses to a concurrent change
+const releaseAttempts = 10
+
 type RepositoryInterface interface {
-	FindAllActive() ([]Trade, error)
+	Create(trade Trade) error
+	FindAll(filter Filter) ([]Trade, error)
+	Count(filter Filter) (int64, error)
+	FindAllActive(now time.Time) ([]Trade, error)
+	FindAllExpired(now time.Time) ([]Trade, error)
+	FindOneById(id uuid.UUID) (*Trade, error)
 	Update(trade Trade) error
+	Reserve(trade Trade, size decimal.Decimal) error
+	Release(id uuid.UUID, size decimal.Decimal) error
+	SetTrailingPeak(trade Trade, peak decimal.Decimal) error
+	SetStatus(id uuid.UUID, status Status) error
+	SetSkipReason(trade Trade, skip Skip, at *time.Time) error
+}
+
+// Filter narrows FindAll and Count, empty fields match every trade
+type Filter struct {
+	Status Status
+	Symbol string
+	// Limit and Offset page FindAll results, zero Limit returns all of them
+	Limit  int
+	Offset int
 }
 
 type Repository struct {
@@ -22,21 +52,275 @@ func NewRepository(
 	return Repository{db, logger}
 }
 
-// FindAllActive returns all trades to be sold, it means trades with order_size_left > 0
-func (r Repository) FindAllActive() ([]Trade, error) {
+// Create stores the trade with its levels, size left of the levels is set from OrderSizeLeft
+func (r Repository) Create(trade Trade) error {
+	trade.distributeSizeLeft()
+
+	if result := r.db.Create(&trade); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return result.Error
+	}
+
+	return nil
+}
+
+// FindAll returns trades matching filter from the oldest one
+func (r Repository) FindAll(filter Filter) ([]Trade, error) {
+	var res []Trade
+
+	query := r.filter(filter).Preload("Levels").Order("created_at, id")
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	if result := query.Find(&res); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
+	return res, nil
+}
+
+// Count returns the number of trades matching filter regardless of its limit
+func (r Repository) Count(filter Filter) (int64, error) {
+	var res int64
+
+	if result := r.filter(filter).Count(&res); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return 0, result.Error
+	}
+
+	return res, nil
+}
+
+func (r Repository) filter(filter Filter) *gorm.DB {
+	query := r.db.Model(&Trade{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.Symbol != "" {
+		query = query.Where("order_size_currency || order_price_currency = ?", filter.Symbol)
+	}
+
+	return query
+}
+
+// FindAllActive returns all trades to be sold, it means active trades with order_size_left > 0 within their window
+// at now. Sizes are stored as text, they are cast to be compared in the query so filled trades are not read.
+func (r Repository) FindAllActive(now time.Time) ([]Trade, error) {
+	var trades []Trade
+
+	result := r.db.Preload("Levels").
+		Where("status = ? AND CAST(order_size_left AS REAL) > 0", StatusActive).
+		Find(&trades)
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
 	var res []Trade
 
-	if result := r.db.Find(&res, "order_size_left > 0"); result.Error != nil {
+	for i := range trades {
+		if trades[i].InWindow(now) {
+			res = append(res, trades[i])
+		}
+	}
+
+	return res, nil
+}
+
+// FindAllExpired returns active and paused trades whose ExpiresAt passed at now and which are not done, they have
+// size left or open orders. Filled trades stay as they are.
+func (r Repository) FindAllExpired(now time.Time) ([]Trade, error) {
+	var trades []Trade
+
+	open := r.db.Model(&order.Order{}).Select("trade_id").Where("status IN ?", order.OpenStatuses())
+
+	result := r.db.Preload("Levels").
+		Where("status IN ? AND expires_at IS NOT NULL", []Status{StatusActive, StatusPaused}).
+		Where("CAST(order_size_left AS REAL) > 0 OR id IN (?)", open).
+		Find(&trades)
+	if result.Error != nil {
 		r.logger.Error(result.Error)
 
 		return nil, result.Error
 	}
 
+	var res []Trade
+
+	for i := range trades {
+		if !now.Before(*trades[i].ExpiresAt) {
+			res = append(res, trades[i])
+		}
+	}
+
 	return res, nil
 }
 
+func (r Repository) FindOneById(id uuid.UUID) (*Trade, error) {
+	var res Trade
+
+	if result := r.db.Preload("Levels").First(&res, "id = ?", id); result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return nil, result.Error
+	}
+
+	return &res, nil
+}
+
+// Update stores the trade only when it was not changed since it was read, otherwise ErrConcurrentUpdate is returned.
+// Size left of the levels is set from OrderSizeLeft.
 func (r Repository) Update(trade Trade) error {
-	if result := r.db.Save(trade); result.Error != nil {
+	version := trade.Version
+	trade.Version++
+	trade.distributeSizeLeft()
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&trade).
+			Where("version = ?", version).
+			Select("*").
+			Omit("CreatedAt", "Orders", "Levels").
+			Updates(trade)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrConcurrentUpdate
+		}
+
+		return saveLevels(tx, trade.Levels)
+	})
+	if err != nil && !errors.Is(err, ErrConcurrentUpdate) {
+		r.logger.Error(err)
+	}
+
+	return err
+}
+
+// Reserve takes size from order_size_left before it is ordered, so concurrent evaluations of the same trade
+// cannot order more than is left. The slice schedule of the trade is stored with it. It fails with
+// ErrConcurrentUpdate when the trade was changed since it was read.
+func (r Repository) Reserve(trade Trade, size decimal.Decimal) error {
+	left := trade.OrderSizeLeft.Sub(size)
+	if left.IsNegative() {
+		return ErrConcurrentUpdate
+	}
+
+	return r.setSizeLeft(trade, left, map[string]any{
+		"schedule_started_at": trade.ScheduleStartedAt,
+		"last_slice_at":       trade.LastSliceAt,
+	})
+}
+
+// Release gives back reserved size which was not executed, it is retried when the trade changes meanwhile
+func (r Repository) Release(id uuid.UUID, size decimal.Decimal) error {
+	for i := 0; i < releaseAttempts; i++ {
+		trade, err := r.FindOneById(id)
+		if err != nil {
+			return err
+		}
+
+		err = r.setSizeLeft(*trade, trade.OrderSizeLeft.Add(size), nil)
+		if !errors.Is(err, ErrConcurrentUpdate) {
+			return err
+		}
+	}
+
+	return ErrConcurrentUpdate
+}
+
+// setSizeLeft stores size left of the trade and its levels with other columns when its version did not change
+func (r Repository) setSizeLeft(trade Trade, left decimal.Decimal, columns map[string]any) error {
+	trade.OrderSizeLeft = left
+	trade.distributeSizeLeft()
+
+	updates := map[string]any{
+		"order_size_left": left,
+		"version":         gorm.Expr("version + 1"),
+	}
+
+	for column, value := range columns {
+		updates[column] = value
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Trade{}).
+			Where("id = ? AND version = ?", trade.ID, trade.Version).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrConcurrentUpdate
+		}
+
+		return saveLevels(tx, trade.Levels)
+	})
+	if err != nil && !errors.Is(err, ErrConcurrentUpdate) {
+		r.logger.Error(err)
+	}
+
+	return err
+}
+
+// saveLevels stores size left of stored levels
+func saveLevels(tx *gorm.DB, levels []Level) error {
+	for i := range levels {
+		if levels[i].ID == 0 {
+			continue
+		}
+
+		result := tx.Model(&Level{}).
+			Where("id = ?", levels[i].ID).
+			Update("size_left", levels[i].SizeLeft)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
+
+// SetTrailingPeak stores the peak of a trailing stop, it fails with ErrConcurrentUpdate when the trade was changed
+// since it was read
+func (r Repository) SetTrailingPeak(trade Trade, peak decimal.Decimal) error {
+	result := r.db.Model(&Trade{}).
+		Where("id = ? AND version = ?", trade.ID, trade.Version).
+		Updates(map[string]any{
+			"trailing_peak": peak,
+			"version":       gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
+
+func (r Repository) SetStatus(id uuid.UUID, status Status) error {
+	result := r.db.Model(&Trade{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":  status,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
 		r.logger.Error(result.Error)
 
 		return result.Error
@@ -44,3 +328,27 @@ func (r Repository) Update(trade Trade) error {
 
 	return nil
 }
+
+// SetSkipReason records why the guard skipped the trade, an empty skip and nil at clear it. It fails with
+// ErrConcurrentUpdate when the trade was changed since it was read.
+func (r Repository) SetSkipReason(trade Trade, skip Skip, at *time.Time) error {
+	result := r.db.Model(&Trade{}).
+		Where("id = ? AND version = ?", trade.ID, trade.Version).
+		Updates(map[string]any{
+			"last_skip_kind":   skip.Kind,
+			"last_skip_reason": skip.Reason,
+			"last_skipped_at":  at,
+			"version":          gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		r.logger.Error(result.Error)
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
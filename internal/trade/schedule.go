@@ -0,0 +1,117 @@
+package trade
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Style is how the size of a trade is spread over orders
+type Style string
+
+const (
+	// StyleImmediate orders as much as the book offers
+	StyleImmediate Style = "IMMEDIATE"
+	// StyleTWAP spreads the size evenly over TwapDuration, a slice every SliceInterval
+	StyleTWAP Style = "TWAP"
+	// StyleIceberg orders at most SliceSize at a time
+	StyleIceberg Style = "ICEBERG"
+)
+
+func (m Trade) GetStyle() Style {
+	if m.Style == "" {
+		return StyleImmediate
+	}
+
+	return m.Style
+}
+
+// Schedule limits the execution to the slice the execution style allows at now, ok is false when the trade waits
+// for its next slice. Stops are not limited, they exit at once.
+func (m Trade) Schedule(e Execution, now time.Time) (Execution, bool) {
+	if m.GetStyle() == StyleImmediate || e.Trigger != TriggerTakeProfit {
+		return e, true
+	}
+
+	if m.LastSliceAt != nil && now.Sub(*m.LastSliceAt) < m.SliceInterval {
+		return Execution{}, false
+	}
+
+	if m.SliceSize.IsPositive() {
+		e.SizeLeft = decimal.Min(e.SizeLeft, m.SliceSize)
+	}
+
+	if m.GetStyle() == StyleTWAP {
+		e.SizeLeft = decimal.Min(e.SizeLeft, m.twapTarget(now).Sub(m.OrderSize.Sub(m.OrderSizeLeft)))
+	}
+
+	if !e.SizeLeft.IsPositive() {
+		return Execution{}, false
+	}
+
+	return e, true
+}
+
+// twapTarget is the size which should be ordered by the end of the current slice, the window begins with the
+// first slice
+func (m Trade) twapTarget(now time.Time) decimal.Decimal {
+	var elapsed time.Duration
+	if m.ScheduleStartedAt != nil {
+		elapsed = now.Sub(*m.ScheduleStartedAt)
+	}
+
+	slices := elapsed/m.SliceInterval + 1
+	if slices*m.SliceInterval >= m.TwapDuration {
+		return m.OrderSize
+	}
+
+	return m.OrderSize.Mul(decimal.NewFromInt(int64(slices * m.SliceInterval))).Div(decimal.NewFromInt(int64(m.TwapDuration)))
+}
+
+// sliced records a slice ordered at now, the schedule is stored together with the reserved size
+func (m *Trade) sliced(now time.Time) {
+	if m.GetStyle() == StyleImmediate {
+		return
+	}
+
+	if m.ScheduleStartedAt == nil {
+		m.ScheduleStartedAt = &now
+	}
+
+	m.LastSliceAt = &now
+}
+
+// validateStyle checks the execution style fields
+func (m Trade) validateStyle() error {
+	if m.SliceSize.IsNegative() || m.SliceInterval < 0 || m.TwapDuration < 0 {
+		return fmt.Errorf("%w: slice size, interval and duration cannot be negative", ErrInvalidTrade)
+	}
+
+	switch m.GetStyle() {
+	case StyleImmediate:
+		if m.SliceSize.IsPositive() || m.SliceInterval > 0 || m.TwapDuration > 0 {
+			return fmt.Errorf("%w: slices need the TWAP or ICEBERG style", ErrInvalidTrade)
+		}
+	case StyleIceberg:
+		if !m.SliceSize.IsPositive() {
+			return fmt.Errorf("%w: iceberg needs a slice size", ErrInvalidTrade)
+		}
+
+		if m.TwapDuration > 0 {
+			return fmt.Errorf("%w: duration needs the TWAP style", ErrInvalidTrade)
+		}
+	case StyleTWAP:
+		if m.TwapDuration <= 0 || m.SliceInterval <= 0 {
+			return fmt.Errorf("%w: TWAP needs a duration and a slice interval", ErrInvalidTrade)
+		}
+
+		if m.SliceInterval > m.TwapDuration {
+			return fmt.Errorf("%w: slice interval %s is longer than duration %s", ErrInvalidTrade, m.SliceInterval, m.TwapDuration)
+		}
+	default:
+		return fmt.Errorf("%w: unknown style %q", ErrInvalidTrade, m.Style)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrade_Schedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		res := start.Add(d)
+
+		return &res
+	}
+
+	twap := Trade{Style: StyleTWAP, OrderSize: dec("12"), OrderSizeLeft: dec("12"), TwapDuration: time.Hour, SliceInterval: 10 * time.Minute}
+	takeProfit := Execution{Trigger: TriggerTakeProfit, SizeLeft: dec("12")}
+
+	tests := []struct {
+		name      string
+		trade     Trade
+		execution Execution
+		now       time.Time
+		want      string
+	}{
+		{name: "immediate", trade: Trade{OrderSizeLeft: dec("12")}, execution: takeProfit, now: start, want: "12"},
+		{name: "iceberg slice", trade: Trade{Style: StyleIceberg, SliceSize: dec("5")}, execution: takeProfit, now: start, want: "5"},
+		{name: "iceberg waits for interval", trade: Trade{Style: StyleIceberg, SliceSize: dec("5"), SliceInterval: time.Minute, LastSliceAt: at(0)}, execution: takeProfit, now: start.Add(30 * time.Second)},
+		{name: "iceberg after interval", trade: Trade{Style: StyleIceberg, SliceSize: dec("5"), SliceInterval: time.Minute, LastSliceAt: at(0)}, execution: takeProfit, now: start.Add(time.Minute), want: "5"},
+		{name: "stop is not sliced", trade: Trade{Style: StyleIceberg, SliceSize: dec("5")}, execution: Execution{Trigger: TriggerStopLoss, SizeLeft: dec("12")}, now: start, want: "12"},
+		{name: "twap first slice", trade: twap, execution: takeProfit, now: start, want: "2"},
+		{
+			name:      "twap catches up",
+			trade:     Trade{Style: StyleTWAP, OrderSize: dec("12"), OrderSizeLeft: dec("10"), TwapDuration: time.Hour, SliceInterval: 10 * time.Minute, ScheduleStartedAt: at(0), LastSliceAt: at(0)},
+			execution: takeProfit,
+			now:       start.Add(35 * time.Minute),
+			want:      "6",
+		},
+		{
+			name:      "twap slice already ordered",
+			trade:     Trade{Style: StyleTWAP, OrderSize: dec("12"), OrderSizeLeft: dec("8"), TwapDuration: time.Hour, SliceInterval: 10 * time.Minute, ScheduleStartedAt: at(0), LastSliceAt: at(10 * time.Minute)},
+			execution: takeProfit,
+			now:       start.Add(15 * time.Minute),
+		},
+		{
+			name:      "twap after window",
+			trade:     Trade{Style: StyleTWAP, OrderSize: dec("12"), OrderSizeLeft: dec("8"), TwapDuration: time.Hour, SliceInterval: 10 * time.Minute, ScheduleStartedAt: at(0), LastSliceAt: at(10 * time.Minute)},
+			execution: takeProfit,
+			now:       start.Add(2 * time.Hour),
+			want:      "8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := tt.trade.Schedule(tt.execution, tt.now)
+
+			assert.Equal(t, tt.want != "", ok)
+
+			if ok {
+				assertDecimal(t, tt.want, e.SizeLeft)
+			}
+		})
+	}
+}
+
+func TestTrade_Validate_Style(t *testing.T) {
+	valid := func(change func(t *Trade)) Trade {
+		t := Trade{OrderSizeCurrency: "BNB", OrderPriceCurrency: "USDT", OrderSize: dec("1"), OrderSizeLeft: dec("1"), OrderPrice: dec("110")}
+		change(&t)
+
+		return t
+	}
+
+	tests := []struct {
+		name    string
+		trade   Trade
+		wantErr bool
+	}{
+		{name: "immediate", trade: valid(func(t *Trade) {})},
+		{name: "iceberg", trade: valid(func(t *Trade) { t.Style, t.SliceSize, t.SliceInterval = StyleIceberg, dec("0.1"), time.Minute })},
+		{name: "twap", trade: valid(func(t *Trade) { t.Style, t.TwapDuration, t.SliceInterval = StyleTWAP, time.Hour, time.Minute })},
+		{name: "unknown style", trade: valid(func(t *Trade) { t.Style = "VWAP" }), wantErr: true},
+		{name: "immediate slice", trade: valid(func(t *Trade) { t.SliceSize = dec("0.1") }), wantErr: true},
+		{name: "iceberg without slice", trade: valid(func(t *Trade) { t.Style = StyleIceberg }), wantErr: true},
+		{name: "twap without interval", trade: valid(func(t *Trade) { t.Style, t.TwapDuration = StyleTWAP, time.Hour }), wantErr: true},
+		{name: "twap interval above duration", trade: valid(func(t *Trade) { t.Style, t.TwapDuration, t.SliceInterval = StyleTWAP, time.Minute, time.Hour }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trade.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTrade)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
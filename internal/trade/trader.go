@@ -3,17 +3,26 @@ package trade
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/beng90/trader/internal/orderbookticker"
 	"github.com/beng90/trader/pkg/logus"
 )
 
+// ErrNothingToTrade is returned by Watch when there is no active trade
+var ErrNothingToTrade = errors.New("nothing to trade")
+
 type Trader struct {
 	logger              logus.Logger
 	orderBookTickerRepo orderbookticker.RepositoryInterface
 	tradeRepo           RepositoryInterface
 	orderCreator        OrderCreatorInterface
+	allocationPolicy    AllocationPolicy
+	guard               Guard
+	clock               Clock
 }
 
 func NewTrader(
@@ -21,18 +30,24 @@ func NewTrader(
 	orderBookTickerRepo orderbookticker.RepositoryInterface,
 	tradeRepo RepositoryInterface,
 	orderCreator OrderCreatorInterface,
+	allocationPolicy AllocationPolicy,
+	guard Guard,
+	clock Clock,
 ) Trader {
 	return Trader{
 		logger:              logger,
 		orderBookTickerRepo: orderBookTickerRepo,
 		tradeRepo:           tradeRepo,
 		orderCreator:        orderCreator,
+		allocationPolicy:    allocationPolicy,
+		guard:               guard,
+		clock:               clock,
 	}
 }
 
 // Watch looks for trades to be done
 func (s Trader) Watch(ctx context.Context) error {
-	trades, err := s.tradeRepo.FindAllActive()
+	trades, err := s.tradeRepo.FindAllActive(s.clock())
 	if err != nil {
 		s.logger.Error(err)
 
@@ -40,30 +55,73 @@ func (s Trader) Watch(ctx context.Context) error {
 	}
 
 	if trades == nil {
-		return errors.New("nothing to trade")
+		return ErrNothingToTrade
+	}
+
+	return s.watch(ctx, trades)
+}
+
+// WatchSymbol looks for trades to be done on symbol only, it is meant to be called on every ticker update
+func (s Trader) WatchSymbol(ctx context.Context, symbol string) error {
+	trades, err := s.tradeRepo.FindAllActive(s.clock())
+	if err != nil {
+		s.logger.Error(err)
+
+		return err
 	}
 
+	var symbolTrades []Trade
+
+	for i := range trades {
+		if trades[i].GetSymbol() == symbol {
+			symbolTrades = append(symbolTrades, trades[i])
+		}
+	}
+
+	return s.watch(ctx, symbolTrades)
+}
+
+// watch trades symbols in parallel, tickers of all symbols are fetched at once and trades on the same symbol
+// share one ticker
+func (s Trader) watch(ctx context.Context, trades []Trade) error {
 	s.logger.Debug("trades", trades)
 
+	symbols := map[string][]Trade{}
+	for i := range trades {
+		symbols[trades[i].GetSymbol()] = append(symbols[trades[i].GetSymbol()], trades[i])
+	}
+
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(symbols))
+	for symbol := range symbols {
+		names = append(names, symbol)
+	}
+
+	sort.Strings(names)
+
+	results, err := s.orderBookTickerRepo.FindAllBySymbols(ctx, names)
+	if err != nil {
+		return err
+	}
+
 	var wg sync.WaitGroup
 
-	for i := range trades {
+	for _, symbol := range names {
 		wg.Add(1)
 
-		go func(trade Trade) {
+		go func(symbol string, trades []Trade) {
 			defer wg.Done()
 
-			s.logger.Debugf(
-				"TRADE  - Symbol: %s, OrderPrice: %.2f, OrderSize: %.2f",
-				trade.GetSymbol(),
-				trade.OrderPrice,
-				trade.OrderSize)
-
-			err := s.trade(ctx, trade)
-			if err != nil {
-				s.logger.Error(err)
+			if err := s.trade(ctx, symbol, trades, results[symbol]); err != nil {
+				// every trade on the symbol is reported, they all missed the tick
+				for i := range trades {
+					s.logger.Error(fmt.Errorf("TRADE %s on %s: %w", trades[i].ID, symbol, err))
+				}
 			}
-		}(trades[i])
+		}(symbol, symbols[symbol])
 	}
 
 	wg.Wait()
@@ -71,23 +129,118 @@ func (s Trader) Watch(ctx context.Context) error {
 	return nil
 }
 
-// trade finds order book ticker for trade
-func (s Trader) trade(ctx context.Context, trade Trade) error {
-	ticker, err := s.orderBookTickerRepo.FindOneBySymbol(ctx, trade.GetSymbol())
-	if err != nil {
-		return err
+// trade allocates quantity of the symbol ticker to trades
+func (s Trader) trade(ctx context.Context, symbol string, trades []Trade, result orderbookticker.Result) error {
+	if result.Err != nil {
+		return result.Err
 	}
 
+	ticker := result.Ticker
 	if ticker == nil {
 		return errors.New("no ticker returned from database")
 	}
 
-	s.logger.Debugf("TICKER  - Symbol: %s, BidPrice: %.2f, BidQty: %.2f", ticker.Symbol, ticker.BidPrice, ticker.BidQty)
+	s.logger.Debugf("TICKER  - Symbol: %s, BidPrice: %s, BidQty: %s", ticker.Symbol, ticker.BidPrice, ticker.BidQty)
 
-	_, err = s.orderCreator.CreateOrder(trade, *ticker)
-	if err != nil {
-		return err
+	for i := range trades {
+		s.trail(&trades[i], *ticker)
+	}
+
+	trades = s.check(trades, *ticker)
+
+	allocations := s.allocationPolicy.Allocate(trades, *ticker, s.clock())
+
+	for i := range trades {
+		allocation, ok := allocations[trades[i].ID]
+		if !ok {
+			continue
+		}
+
+		s.logger.Debugf(
+			"TRADE  - Symbol: %s, OrderPrice: %s, OrderSize: %s, Allocated: %s",
+			symbol,
+			trades[i].OrderPrice,
+			trades[i].OrderSize,
+			allocation.Size)
+
+		// a failing trade does not stop the others
+		if _, err := s.orderCreator.CreateOrder(ctx, trades[i], *ticker, allocation); err != nil {
+			s.logger.Error(err)
+		}
 	}
 
 	return nil
 }
+
+// check leaves out trades the guard keeps from ordering on the ticker, so they get no allocation. The reason is
+// recorded on the trade when its kind changes and cleared once the trade passes again, a trade skipped for the
+// same kind on every ticker keeps the time and prices of the first skip.
+func (s Trader) check(trades []Trade, ticker orderbookticker.OrderBookTicker) []Trade {
+	var res []Trade
+
+	for i := range trades {
+		e, ok := trades[i].Match(ticker)
+		if !ok {
+			res = append(res, trades[i])
+
+			continue
+		}
+
+		skip := s.guard.Check(trades[i], ticker, e)
+		if skip.Kind == "" {
+			s.skip(&trades[i], skip, nil)
+			res = append(res, trades[i])
+
+			continue
+		}
+
+		s.logger.Debugf("TRADE %s skipped: %s", trades[i].ID, skip.Reason)
+
+		now := s.clock()
+		s.skip(&trades[i], skip, &now)
+	}
+
+	return res
+}
+
+// skip stores the skip of the trade when its kind changed, a skip which cannot be stored is recorded on a later
+// ticker
+func (s Trader) skip(trade *Trade, skip Skip, at *time.Time) {
+	if skip.Kind == trade.LastSkipKind {
+		return
+	}
+
+	err := s.tradeRepo.SetSkipReason(*trade, skip, at)
+	if errors.Is(err, ErrConcurrentUpdate) {
+		s.logger.Debugf("TRADE %s changed concurrently, skip reason %q is not stored", trade.ID, skip.Reason)
+
+		return
+	} else if err != nil {
+		s.logger.Error(err)
+
+		return
+	}
+
+	trade.Version++
+	trade.LastSkipKind, trade.LastSkipReason, trade.LastSkippedAt = skip.Kind, skip.Reason, at
+}
+
+// trail moves the peak of a trailing stop before the trade is matched, so the stop follows the ticker it is
+// evaluated on. A peak which cannot be stored is kept in memory for this ticker only.
+func (s Trader) trail(trade *Trade, ticker orderbookticker.OrderBookTicker) {
+	peak, changed := trade.Trail(ticker)
+	if !changed {
+		return
+	}
+
+	err := s.tradeRepo.SetTrailingPeak(*trade, peak)
+	if errors.Is(err, ErrConcurrentUpdate) {
+		s.logger.Debugf("TRADE %s changed concurrently, peak %s is not stored", trade.ID, peak)
+	} else if err != nil {
+		s.logger.Error(err)
+	} else {
+		trade.Version++
+	}
+
+	trade.TrailingPeak = peak
+}
@@ -0,0 +1,694 @@
+package trade
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/beng90/trader/internal/symbol"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var bnbUsdt = exchange.SymbolInfo{Symbol: "BNBUSDT", Status: "TRADING", BaseAsset: "BNB", QuoteAsset: "USDT"}
+
+// newTestDB opens a migrated in-memory database, single connection keeps all queries on the same memory db
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(&Trade{}, &Level{}, &order.Order{}))
+
+	return db
+}
+
+// testTrader is a trader on the simulated exchange and a test database, the test reads the database through its
+// repositories
+type testTrader struct {
+	Trader
+	tradeRepo    Repository
+	orderRepo    order.Repository
+	orderCreator OrderCreator
+}
+
+type testTraderOption func(*testTraderOptions)
+
+type testTraderOptions struct {
+	allocationPolicy AllocationPolicy
+	guard            Guard
+	clock            Clock
+}
+
+func withAllocationPolicy(policy AllocationPolicy) testTraderOption {
+	return func(o *testTraderOptions) { o.allocationPolicy = policy }
+}
+
+func withGuard(guard Guard) testTraderOption {
+	return func(o *testTraderOptions) { o.guard = guard }
+}
+
+func withClock(clock Clock) testTraderOption {
+	return func(o *testTraderOptions) { o.clock = clock }
+}
+
+// newTestTrader wires a trader as run does, FIFO allocation, no guard and time.Now unless opts change them
+func newTestTrader(t *testing.T, db *gorm.DB, sim *exchange.Simulated, opts ...testTraderOption) testTrader {
+	t.Helper()
+
+	o := testTraderOptions{allocationPolicy: AllocationFIFO, clock: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res := testTrader{
+		tradeRepo: NewRepository(db, testLogger),
+		orderRepo: order.NewRepository(db, testLogger),
+	}
+
+	res.orderCreator = NewOrderCreator(testLogger, res.tradeRepo, res.orderRepo, symbol.NewRepository(sim, testLogger, time.Hour), sim, NewUnitOfWork(db, testLogger), o.clock)
+	res.Trader = NewTrader(testLogger, orderbookticker.NewRepository(sim, testLogger), res.tradeRepo, res.orderCreator, o.allocationPolicy, o.guard, o.clock)
+
+	return res
+}
+
+func TestTrader_Watch_SimulatedExchange(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT",
+		[]exchange.PriceLevel{{Price: dec("115"), Quantity: dec("20")}, {Price: dec("112"), Quantity: dec("10")}, {Price: dec("100"), Quantity: dec("50")}},
+		[]exchange.PriceLevel{{Price: dec("116"), Quantity: dec("100")}},
+	)
+
+	trader := newTestTrader(t, db, sim)
+
+	tradeId := uuid.New()
+	require.NoError(t, db.Create(&Trade{
+		ID:                 tradeId,
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}).Error)
+
+	sizeLeft := func() decimal.Decimal {
+		var tr Trade
+		require.NoError(t, db.First(&tr, "id = ?", tradeId).Error)
+
+		return tr.OrderSizeLeft
+	}
+
+	ctx := context.Background()
+
+	// every tick sells what is offered at the top of the book
+	require.NoError(t, trader.Watch(ctx))
+	assertDecimal(t, "30", sizeLeft())
+
+	require.NoError(t, trader.Watch(ctx))
+	assertDecimal(t, "20", sizeLeft())
+
+	// bid below the order price
+	require.NoError(t, trader.Watch(ctx))
+	assertDecimal(t, "20", sizeLeft())
+
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("100")}}, nil)
+
+	require.NoError(t, trader.Watch(ctx))
+	assertDecimal(t, "0", sizeLeft())
+
+	var orders []order.Order
+	require.NoError(t, db.Order("created_at, order_id").Find(&orders, "trade_id = ?", tradeId).Error)
+	require.Len(t, orders, 3)
+
+	assert.Equal(t, []string{"20", "10", "20"}, []string{orders[0].ExecutedSize.String(), orders[1].ExecutedSize.String(), orders[2].ExecutedSize.String()})
+	assert.Equal(t, []string{"115", "112", "120"}, []string{orders[0].OrderPrice.String(), orders[1].OrderPrice.String(), orders[2].OrderPrice.String()})
+}
+
+func TestTrader_Watch_StopLoss(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("100"), Quantity: dec("20")}}, nil)
+
+	trader := newTestTrader(t, db, sim)
+
+	tr := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("30"),
+		OrderSizeLeft:      dec("30"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("120"),
+		OrderPriceCurrency: "USDT",
+		StopPrice:          dec("95"),
+	}
+	require.NoError(t, db.Create(&tr).Error)
+
+	// bid between the stop and the price
+	require.NoError(t, trader.Watch(context.Background()))
+
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("94"), Quantity: dec("10")}}, nil)
+	require.NoError(t, trader.Watch(context.Background()))
+
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("121"), Quantity: dec("50")}}, nil)
+	require.NoError(t, trader.Watch(context.Background()))
+
+	var orders []order.Order
+	require.NoError(t, db.Order("created_at, order_id").Find(&orders, "trade_id = ?", tr.ID).Error)
+	require.Len(t, orders, 2)
+
+	assert.Equal(t, string(TriggerStopLoss), orders[0].Trigger)
+	assert.Equal(t, "10", orders[0].ExecutedSize.String())
+	assertDecimal(t, "95", orders[0].ReferencePrice)
+	assert.Equal(t, string(TriggerTakeProfit), orders[1].Trigger)
+	assert.Equal(t, "20", orders[1].ExecutedSize.String())
+	assertDecimal(t, "120", orders[1].ReferencePrice)
+}
+
+func TestTrader_Watch_TrailingStop(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+
+	tradeRepo := NewRepository(db, testLogger)
+
+	tr := Trade{
+		ID:                      uuid.New(),
+		OrderSize:               dec("10"),
+		OrderSizeLeft:           dec("10"),
+		OrderSizeCurrency:       "BNB",
+		OrderPriceCurrency:      "USDT",
+		TrailingPercent:         dec("2"),
+		TrailingActivationPrice: dec("110"),
+	}
+	require.NoError(t, db.Create(&tr).Error)
+
+	stored := func() Trade {
+		res, err := tradeRepo.FindOneById(tr.ID)
+		require.NoError(t, err)
+
+		return *res
+	}
+
+	for _, bid := range []string{"100", "112", "115", "113"} {
+		sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec(bid), Quantity: dec("100")}}, nil)
+
+		// a new trader on every ticker, the peak is read from the database
+		require.NoError(t, newTestTrader(t, db, sim).Watch(context.Background()))
+	}
+
+	assertDecimal(t, "115", stored().TrailingPeak)
+	assertDecimal(t, "10", stored().OrderSizeLeft)
+
+	// 2% below the peak of 115
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("112.7"), Quantity: dec("100")}}, nil)
+
+	require.NoError(t, newTestTrader(t, db, sim).Watch(context.Background()))
+
+	assertDecimal(t, "0", stored().OrderSizeLeft)
+
+	var orders []order.Order
+	require.NoError(t, db.Find(&orders, "trade_id = ?", tr.ID).Error)
+	require.Len(t, orders, 1)
+	assert.Equal(t, string(TriggerTrailingStop), orders[0].Trigger)
+	assertDecimal(t, "112.7", orders[0].OrderPrice)
+}
+
+func TestTrader_Watch_Ladder(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+
+	trader := newTestTrader(t, db, sim)
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSizeCurrency:  "BNB",
+		OrderPriceCurrency: "USDT",
+		Levels: []Level{
+			{Price: dec("110"), Size: dec("10")},
+			{Price: dec("120"), Size: dec("20")},
+			{Price: dec("130"), Size: dec("30")},
+		},
+	})
+	require.NoError(t, err)
+
+	stored := func() Trade {
+		res, err := tradeRepo.FindOneById(added.ID)
+		require.NoError(t, err)
+
+		return *res
+	}
+
+	// only the first level is reached
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("100")}}, nil)
+	require.NoError(t, trader.Watch(context.Background()))
+
+	tr := stored()
+	assertDecimal(t, "50", tr.OrderSizeLeft)
+	assertDecimal(t, "0", tr.Levels[0].SizeLeft)
+	assertDecimal(t, "20", tr.Levels[1].SizeLeft)
+
+	// the second level is filled partly by the quantity offered
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("125"), Quantity: dec("15")}}, nil)
+	require.NoError(t, trader.Watch(context.Background()))
+
+	tr = stored()
+	assertDecimal(t, "35", tr.OrderSizeLeft)
+	assertDecimal(t, "5", tr.Levels[1].SizeLeft)
+	assertDecimal(t, "30", tr.Levels[2].SizeLeft)
+
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("130"), Quantity: dec("100")}}, nil)
+	require.NoError(t, trader.Watch(context.Background()))
+
+	tr = stored()
+	assertDecimal(t, "0", tr.OrderSizeLeft)
+	assertDecimal(t, "0", tr.Levels[2].SizeLeft)
+
+	var orders []order.Order
+	require.NoError(t, db.Order("created_at, order_id").Find(&orders, "trade_id = ?", added.ID).Error)
+	require.Len(t, orders, 3)
+	assertDecimal(t, "10", orders[0].ExecutedSize)
+	assertDecimal(t, "15", orders[1].ExecutedSize)
+	assertDecimal(t, "35", orders[2].ExecutedSize)
+}
+
+func TestTrader_Watch_TWAP(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("1000")}}, nil)
+
+	tradeRepo := NewRepository(db, testLogger)
+	orderRepo := order.NewRepository(db, testLogger)
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("40"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+		Style:              StyleTWAP,
+		TwapDuration:       time.Hour,
+		SliceInterval:      15 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	// a new trader on every ticker, as after a restart the schedule is read from the database
+	watch := func(at time.Duration) Trade {
+		clock := func() time.Time { return now.Add(at) }
+
+		require.NoError(t, newTestTrader(t, db, sim, withClock(clock)).Watch(context.Background()))
+
+		res, err := tradeRepo.FindOneById(added.ID)
+		require.NoError(t, err)
+
+		return *res
+	}
+
+	tr := watch(0)
+	assertDecimal(t, "30", tr.OrderSizeLeft)
+	require.NotNil(t, tr.ScheduleStartedAt)
+	assert.True(t, now.Equal(*tr.ScheduleStartedAt))
+
+	// interval did not pass yet
+	assertDecimal(t, "30", watch(10*time.Minute).OrderSizeLeft)
+	assertDecimal(t, "20", watch(15*time.Minute).OrderSizeLeft)
+
+	// missed slices are caught up
+	assertDecimal(t, "0", watch(50*time.Minute).OrderSizeLeft)
+}
+
+func TestTrader_Watch_Iceberg(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("1000")}}, nil)
+
+	trader := newTestTrader(t, db, sim)
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("25"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+		Style:              StyleIceberg,
+		SliceSize:          dec("10"),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, trader.Watch(context.Background()))
+	}
+
+	var orders []order.Order
+	require.NoError(t, db.Order("created_at, order_id").Find(&orders, "trade_id = ?", added.ID).Error)
+	require.Len(t, orders, 3)
+	assertDecimal(t, "10", orders[0].OrderSize)
+	assertDecimal(t, "10", orders[1].OrderSize)
+	assertDecimal(t, "5", orders[2].OrderSize)
+}
+
+func TestTrader_Watch_SharedTicker(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("30")}, {Price: dec("100"), Quantity: dec("100")}}, nil)
+
+	trader := newTestTrader(t, db, sim, withAllocationPolicy(AllocationProRata))
+	tradeRepo := trader.tradeRepo
+
+	first := createTestTrade(t, db)
+	second := createTestTrade(t, db)
+
+	require.NoError(t, trader.Watch(context.Background()))
+
+	// both trades together take only what the book offers
+	var orders []order.Order
+	require.NoError(t, db.Find(&orders).Error)
+	require.Len(t, orders, 2)
+
+	for _, o := range orders {
+		assertDecimal(t, "15", o.ExecutedSize)
+		assertDecimal(t, "15", o.AllocatedSize)
+		assert.Equal(t, string(AllocationProRata), o.AllocationPolicy)
+	}
+
+	for _, id := range []uuid.UUID{first.ID, second.ID} {
+		stored, err := tradeRepo.FindOneById(id)
+		require.NoError(t, err)
+		assertDecimal(t, "35", stored.OrderSizeLeft)
+	}
+}
+
+func TestRepository_FindAllActive_DefaultSide(t *testing.T) {
+	db := newTestDB(t)
+
+	// row written before trades had a side
+	require.NoError(t, db.Exec(
+		"INSERT INTO trades (id, order_size, order_size_left, order_size_currency, order_price, order_price_currency) VALUES (?, 1, 1, 'BNB', 100, 'USDT')",
+		uuid.New(),
+	).Error)
+
+	trades, err := NewRepository(db, testLogger).FindAllActive(time.Now())
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, exchange.SideSell, trades[0].Side)
+}
+
+func TestRepository_FindAllActive_SizeLeft(t *testing.T) {
+	db := newTestDB(t)
+	tradeRepo := NewRepository(db, testLogger)
+
+	left := createTestTrade(t, db)
+	require.NoError(t, tradeRepo.Reserve(left, dec("49.99999999")))
+
+	filled := createTestTrade(t, db)
+	require.NoError(t, tradeRepo.Reserve(filled, dec("50")))
+
+	trades, err := tradeRepo.FindAllActive(time.Now())
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, left.ID, trades[0].ID)
+	assertDecimal(t, "0.00000001", trades[0].OrderSizeLeft)
+}
+
+func TestOrderCreator_CreateOrder_Concurrent(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("1000")}}, nil)
+
+	trader := newTestTrader(t, db, sim)
+	tradeRepo, s := trader.tradeRepo, trader.orderCreator
+
+	ticker := orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec("115"), BidQty: dec("1000")}
+
+	// every evaluation works on the same copy of the trade, as overlapping ticks would
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := s.CreateOrder(context.Background(), trade, ticker, Allocation{Policy: AllocationFIFO, Size: dec("1000")})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	var orders []order.Order
+	require.NoError(t, db.Find(&orders).Error)
+	require.Len(t, orders, 1)
+	assertDecimal(t, "50", orders[0].ExecutedSize)
+
+	stored, err := tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "0", stored.OrderSizeLeft)
+}
+
+func TestRepository_Update_StaleVersion(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+	tradeRepo := NewRepository(db, testLogger)
+
+	require.NoError(t, tradeRepo.Reserve(trade, dec("20")))
+
+	// trade was read before the reservation
+	trade.OrderSizeLeft = dec("10")
+	assert.ErrorIs(t, tradeRepo.Update(trade), ErrConcurrentUpdate)
+	assert.ErrorIs(t, tradeRepo.Reserve(trade, dec("10")), ErrConcurrentUpdate)
+
+	stored, err := tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "30", stored.OrderSizeLeft)
+	assert.Equal(t, int64(1), stored.Version)
+
+	stored.OrderSizeLeft = dec("25")
+	require.NoError(t, tradeRepo.Update(*stored))
+
+	stored, err = tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "25", stored.OrderSizeLeft)
+	assert.Equal(t, int64(2), stored.Version)
+}
+
+func TestRepository_SetStatus_Dust(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+	tradeRepo := NewRepository(db, testLogger)
+
+	trades, err := tradeRepo.FindAllActive(time.Now())
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, StatusActive, trades[0].Status)
+
+	require.NoError(t, tradeRepo.SetStatus(trade.ID, StatusDust))
+
+	// dust is not traded anymore
+	trades, err = tradeRepo.FindAllActive(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, trades)
+}
+
+func TestTrader_Watch_Guard(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("100")}}, []exchange.PriceLevel{{Price: dec("130"), Quantity: dec("100")}})
+
+	trader := newTestTrader(t, db, sim, withGuard(Guard{MaxSpreadPercent: dec("1")}))
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, trader.Watch(context.Background()))
+
+	stored, err := tradeRepo.FindOneById(added.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "10", stored.OrderSizeLeft)
+	assert.Equal(t, "spread 8.00% is above 1%", stored.LastSkipReason)
+	assert.NotNil(t, stored.LastSkippedAt)
+
+	// the trade orders once the book tightens
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("100")}}, []exchange.PriceLevel{{Price: dec("120.5"), Quantity: dec("100")}})
+
+	require.NoError(t, trader.Watch(context.Background()))
+
+	stored, err = tradeRepo.FindOneById(added.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "0", stored.OrderSizeLeft)
+}
+
+func TestTrader_Watch_Guard_SkipPassSkip(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+
+	trader := newTestTrader(t, db, sim, withGuard(Guard{MaxSpreadPercent: dec("1")}), withClock(clock))
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+	})
+	require.NoError(t, err)
+
+	watch := func(ask string) Trade {
+		sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("4")}}, []exchange.PriceLevel{{Price: dec(ask), Quantity: dec("100")}})
+
+		require.NoError(t, trader.Watch(context.Background()))
+
+		stored, err := tradeRepo.FindOneById(added.ID)
+		require.NoError(t, err)
+
+		return *stored
+	}
+
+	stored := watch("130")
+	assertDecimal(t, "10", stored.OrderSizeLeft)
+	assert.Equal(t, "spread 8.00% is above 1%", stored.LastSkipReason)
+	require.NotNil(t, stored.LastSkippedAt)
+	assert.True(t, now.Equal(*stored.LastSkippedAt))
+
+	// the reason is cleared once the trade passes and its order is reserved on the cleared version
+	now = now.Add(time.Minute)
+	stored = watch("120.5")
+	assertDecimal(t, "6", stored.OrderSizeLeft)
+	assert.Empty(t, stored.LastSkipReason)
+	assert.Nil(t, stored.LastSkippedAt)
+
+	// the same reason is recorded again
+	now = now.Add(time.Minute)
+	stored = watch("130")
+	assertDecimal(t, "6", stored.OrderSizeLeft)
+	assert.Equal(t, "spread 8.00% is above 1%", stored.LastSkipReason)
+	require.NotNil(t, stored.LastSkippedAt)
+	assert.True(t, now.Equal(*stored.LastSkippedAt))
+}
+
+func TestTrader_Watch_Fees(t *testing.T) {
+	db := newTestDB(t)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetFees(exchange.FeeSchedule{TakerPercent: dec("0.1")})
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("120"), Quantity: dec("100")}}, nil)
+
+	trader := newTestTrader(t, db, sim)
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, trader.Watch(context.Background()))
+
+	orders, err := orderRepo.FindAll(order.Filter{TradeId: added.ID})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assertDecimal(t, "1.2", orders[0].Fee)
+	assert.Equal(t, "USDT", orders[0].FeeAsset)
+
+	p := NewProceeds(*added, orders)
+	assertDecimal(t, "1200", p.Gross)
+	assertDecimal(t, "1198.8", p.Net)
+}
+
+func TestTrader_Watch_Guard_SameKind(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := now
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+
+	trader := newTestTrader(t, db, sim, withGuard(Guard{MaxSpreadPercent: dec("1")}), withClock(func() time.Time { return now }))
+	tradeRepo, orderRepo := trader.tradeRepo, trader.orderRepo
+
+	added, err := NewManager(testLogger, tradeRepo, orderRepo).Add(Trade{
+		OrderSize:          dec("10"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("110"),
+		OrderPriceCurrency: "USDT",
+	})
+	require.NoError(t, err)
+
+	watch := func(bid string, ask string) Trade {
+		sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec(bid), Quantity: dec("4")}}, []exchange.PriceLevel{{Price: dec(ask), Quantity: dec("100")}})
+
+		require.NoError(t, trader.Watch(context.Background()))
+
+		stored, err := tradeRepo.FindOneById(added.ID)
+		require.NoError(t, err)
+
+		return *stored
+	}
+
+	stored := watch("120", "130")
+	assert.Equal(t, SkipSpread, stored.LastSkipKind)
+	version := stored.Version
+
+	// the spread moves, the trade is skipped for the same kind since the first skip and is not written
+	now = now.Add(time.Minute)
+	stored = watch("120", "131")
+	assert.Equal(t, "spread 8.00% is above 1%", stored.LastSkipReason)
+	require.NotNil(t, stored.LastSkippedAt)
+	assert.True(t, first.Equal(*stored.LastSkippedAt))
+	assert.Equal(t, version, stored.Version)
+
+	// another kind is recorded from its first skip
+	now = now.Add(time.Minute)
+	stored = watch("131", "130")
+	assert.Equal(t, SkipCrossed, stored.LastSkipKind)
+	assert.Equal(t, "book is crossed, ask 130 is below bid 131", stored.LastSkipReason)
+	require.NotNil(t, stored.LastSkippedAt)
+	assert.True(t, now.Equal(*stored.LastSkippedAt))
+}
@@ -2,6 +2,7 @@ package trade
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
@@ -10,17 +11,50 @@ import (
 	"github.com/beng90/trader/internal/orderbookticker"
 	"github.com/beng90/trader/pkg/logus"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 var testLogger = &logus.TestLogger{}
 
+func dec(v string) decimal.Decimal {
+	return decimal.RequireFromString(v)
+}
+
+// assertDecimal compares values, not representations, 1.50 equals 1.5
+func assertDecimal(t *testing.T, want string, got decimal.Decimal) {
+	t.Helper()
+
+	assert.Equal(t, dec(want).String(), got.String())
+}
+
+// assertJSONEqual compares models by their JSON form, decimals equal in value differ in their internal representation
+func assertJSONEqual(t *testing.T, want any, got any) {
+	t.Helper()
+
+	wantJSON, err := json.Marshal(want)
+	assert.NoError(t, err)
+
+	gotJSON, err := json.Marshal(got)
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, string(wantJSON), string(gotJSON))
+}
+
+// decimalArg matches a mock argument equal to v in value
+func decimalArg(v string) any {
+	return mock.MatchedBy(func(got decimal.Decimal) bool {
+		return got.Equal(dec(v))
+	})
+}
+
 type OrderCreatorMock struct {
 	mock.Mock
 }
 
-func (m *OrderCreatorMock) CreateOrder(trade Trade, ticker orderbookticker.OrderBookTicker) (*string, error) {
-	args := m.Called(trade, ticker)
+func (m *OrderCreatorMock) CreateOrder(ctx context.Context, trade Trade, ticker orderbookticker.OrderBookTicker, allocation Allocation) (*string, error) {
+	args := m.Called(trade, ticker, allocation)
 
 	return args.Get(0).(*string), args.Error(1)
 }
@@ -55,13 +89,24 @@ func TestNewTraderService(t *testing.T) {
 				orderBookTickerRepo: orderBookTickerRepo,
 				tradeRepo:           tradeRepo,
 				orderCreator:        orderCreator,
+				allocationPolicy:    AllocationFIFO,
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewTrader(tt.args.logger, tt.args.orderBookTickerRepo, tt.args.tradeRepo, tt.args.orderCreator); !reflect.DeepEqual(got, tt.want) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			got := NewTrader(tt.args.logger, tt.args.orderBookTickerRepo, tt.args.tradeRepo, tt.args.orderCreator, AllocationFIFO, Guard{}, func() time.Time { return now })
+			if got.clock() != now {
+				t.Errorf("NewTrader() clock = %s, want %s", got.clock(), now)
+			}
+
+			// functions are not comparable
+			got.clock = nil
+
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewTrader() = %+v, want %+v", got, tt.want)
 			}
 		})
@@ -78,10 +123,33 @@ func (m *OrderBookTickerRepositoryMock) FindOneBySymbol(ctx context.Context, sym
 	return args.Get(0).(*orderbookticker.OrderBookTicker), args.Error(1)
 }
 
+func (m *OrderBookTickerRepositoryMock) FindAllBySymbols(ctx context.Context, symbols []string) (map[string]orderbookticker.Result, error) {
+	args := m.Called(symbols)
+
+	return args.Get(0).(map[string]orderbookticker.Result), args.Error(1)
+}
+
 type TradeRepositoryMock struct {
 	mock.Mock
 
-	trade Trade
+	trade  Trade
+	trades []Trade
+}
+
+func (m *TradeRepositoryMock) Create(trade Trade) error {
+	args := m.Called(trade)
+
+	m.trade = trade
+
+	return args.Error(0)
+}
+
+func (m *TradeRepositoryMock) FindAll(filter Filter) ([]Trade, error) {
+	return append([]Trade{}, m.trades...), nil
+}
+
+func (m *TradeRepositoryMock) Count(filter Filter) (int64, error) {
+	return int64(len(m.trades)), nil
 }
 
 func (m *TradeRepositoryMock) Update(trade Trade) error {
@@ -92,40 +160,78 @@ func (m *TradeRepositoryMock) Update(trade Trade) error {
 	return args.Error(0)
 }
 
-func (m *TradeRepositoryMock) FindAllActive() ([]Trade, error) {
-	return []Trade{}, nil
+func (m *TradeRepositoryMock) Reserve(trade Trade, size decimal.Decimal) error {
+	args := m.Called(trade, size)
+
+	m.trade = trade
+	m.trade.OrderSizeLeft = m.trade.OrderSizeLeft.Sub(size)
+
+	return args.Error(0)
 }
 
-func TestTraderService_trade(t *testing.T) {
-	getOrderBookTickerRepo := func(obt *orderbookticker.OrderBookTicker, err error) *OrderBookTickerRepositoryMock {
-		orderBookTickerRepo := &OrderBookTickerRepositoryMock{}
+func (m *TradeRepositoryMock) Release(id uuid.UUID, size decimal.Decimal) error {
+	args := m.Called(id, size)
 
-		orderBookTickerRepo.
-			On("FindOneBySymbol", "BNBUSDT").
-			Return(obt, err)
+	m.trade.OrderSizeLeft = m.trade.OrderSizeLeft.Add(size)
 
-		return orderBookTickerRepo
-	}
+	return args.Error(0)
+}
+
+func (m *TradeRepositoryMock) SetTrailingPeak(trade Trade, peak decimal.Decimal) error {
+	m.trade = trade
+	m.trade.TrailingPeak = peak
+
+	return nil
+}
+
+func (m *TradeRepositoryMock) SetStatus(id uuid.UUID, status Status) error {
+	args := m.Called(id, status)
+
+	m.trade.Status = status
+
+	return args.Error(0)
+}
+
+func (m *TradeRepositoryMock) FindOneById(id uuid.UUID) (*Trade, error) {
+	trade := m.trade
 
+	return &trade, nil
+}
+
+func (m *TradeRepositoryMock) FindAllActive(now time.Time) ([]Trade, error) {
+	return append([]Trade{}, m.trades...), nil
+}
+
+func (m *TradeRepositoryMock) SetSkipReason(trade Trade, skip Skip, at *time.Time) error {
+	m.trade.LastSkipKind, m.trade.LastSkipReason = skip.Kind, skip.Reason
+
+	return nil
+}
+
+func (m *TradeRepositoryMock) FindAllExpired(now time.Time) ([]Trade, error) {
+	return nil, nil
+}
+
+func TestTraderService_trade(t *testing.T) {
 	getOrderCreator := func(orderId string, err error) *OrderCreatorMock {
 		orderCreator := &OrderCreatorMock{}
 
 		orderCreator.
-			On("CreateOrder", mock.Anything, mock.Anything).
+			On("CreateOrder", mock.Anything, mock.Anything, mock.Anything).
 			Return(&orderId, err)
 
 		return orderCreator
 	}
 
 	type fields struct {
-		logger              logus.Logger
-		orderBookTickerRepo orderbookticker.RepositoryInterface
-		tradeRepo           RepositoryInterface
-		orderCreator        OrderCreatorInterface
+		logger       logus.Logger
+		tradeRepo    RepositoryInterface
+		orderCreator OrderCreatorInterface
 	}
 
 	type args struct {
-		trade Trade
+		trade  Trade
+		result orderbookticker.Result
 	}
 
 	tests := []struct {
@@ -135,22 +241,22 @@ func TestTraderService_trade(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "FindOneBySymbol returned errors",
+			name: "ticker lookup returned error",
 			fields: fields{
-				logger:              testLogger,
-				orderBookTickerRepo: getOrderBookTickerRepo(nil, errors.New("test")),
-				tradeRepo:           nil,
-				orderCreator:        getOrderCreator("asd123", nil),
+				logger:       testLogger,
+				tradeRepo:    nil,
+				orderCreator: getOrderCreator("asd123", nil),
 			},
 			args: args{
+				result: orderbookticker.Result{Err: errors.New("test")},
 				trade: Trade{
 					ID:                 uuid.New(),
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
@@ -158,22 +264,22 @@ func TestTraderService_trade(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "FindOneBySymbol returned nil",
+			name: "ticker lookup returned nil",
 			fields: fields{
-				logger:              testLogger,
-				orderBookTickerRepo: getOrderBookTickerRepo(nil, nil),
-				tradeRepo:           nil,
-				orderCreator:        getOrderCreator("asd123", nil),
+				logger:       testLogger,
+				tradeRepo:    nil,
+				orderCreator: getOrderCreator("asd123", nil),
 			},
 			args: args{
+				result: orderbookticker.Result{},
 				trade: Trade{
 					ID:                 uuid.New(),
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
@@ -183,26 +289,26 @@ func TestTraderService_trade(t *testing.T) {
 		{
 			name: "trade created",
 			fields: fields{
-				logger: testLogger,
-				orderBookTickerRepo: getOrderBookTickerRepo(&orderbookticker.OrderBookTicker{
-					Symbol:   "BNBUSDT",
-					BidPrice: 55,
-					BidQty:   50,
-					AskPrice: 0,
-					AksQty:   0,
-				}, nil),
+				logger:       testLogger,
 				tradeRepo:    nil,
 				orderCreator: getOrderCreator("asd123", nil),
 			},
 			args: args{
+				result: orderbookticker.Result{Ticker: &orderbookticker.OrderBookTicker{
+					Symbol:   "BNBUSDT",
+					BidPrice: dec("55"),
+					BidQty:   dec("50"),
+					AskPrice: dec("0"),
+					AksQty:   dec("0"),
+				}},
 				trade: Trade{
 					ID:                 uuid.New(),
 					CreatedAt:          time.Time{},
 					UpdatedAt:          time.Time{},
-					OrderSize:          50,
-					OrderSizeLeft:      50,
+					OrderSize:          dec("50"),
+					OrderSizeLeft:      dec("50"),
 					OrderSizeCurrency:  "BNB",
-					OrderPrice:         111,
+					OrderPrice:         dec("111"),
 					OrderPriceCurrency: "USDT",
 					Orders:             nil,
 				},
@@ -214,15 +320,97 @@ func TestTraderService_trade(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := Trader{
-				logger:              tt.fields.logger,
-				orderBookTickerRepo: tt.fields.orderBookTickerRepo,
-				tradeRepo:           tt.fields.tradeRepo,
-				orderCreator:        tt.fields.orderCreator,
+				logger:           tt.fields.logger,
+				tradeRepo:        tt.fields.tradeRepo,
+				orderCreator:     tt.fields.orderCreator,
+				allocationPolicy: AllocationFIFO,
+				clock:            time.Now,
 			}
 
-			if err := s.trade(context.Background(), tt.args.trade); (err != nil) != tt.wantErr {
+			if err := s.trade(context.Background(), "BNBUSDT", []Trade{tt.args.trade}, tt.args.result); (err != nil) != tt.wantErr {
 				t.Errorf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestTraderService_WatchSymbol(t *testing.T) {
+	bnbTrade := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}
+	ethTrade := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("2"),
+		OrderSizeLeft:      dec("2"),
+		OrderSizeCurrency:  "ETH",
+		OrderPrice:         dec("1500"),
+		OrderPriceCurrency: "USDT",
+	}
+
+	ticker := &orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec("115"), BidQty: dec("10")}
+
+	orderBookTickerRepo := &OrderBookTickerRepositoryMock{}
+	orderBookTickerRepo.
+		On("FindAllBySymbols", []string{"BNBUSDT"}).
+		Return(map[string]orderbookticker.Result{"BNBUSDT": {Ticker: ticker}}, nil)
+
+	orderId := "1"
+	orderCreator := &OrderCreatorMock{}
+	orderCreator.
+		On("CreateOrder", bnbTrade, *ticker, Allocation{Policy: AllocationFIFO, Size: dec("10")}).
+		Return(&orderId, nil)
+
+	tradeRepo := &TradeRepositoryMock{trades: []Trade{bnbTrade, ethTrade}}
+
+	s := NewTrader(testLogger, orderBookTickerRepo, tradeRepo, orderCreator, AllocationFIFO, Guard{}, time.Now)
+
+	err := s.WatchSymbol(context.Background(), "BNBUSDT")
+	if err != nil {
+		t.Errorf("WatchSymbol() error = %v", err)
+	}
+
+	// only the trade on updated symbol is evaluated
+	orderBookTickerRepo.AssertNumberOfCalls(t, "FindAllBySymbols", 1)
+	orderCreator.AssertNumberOfCalls(t, "CreateOrder", 1)
+}
+
+func TestTraderService_Watch_BatchLookup(t *testing.T) {
+	bnbTrade := Trade{ID: uuid.New(), OrderSizeLeft: dec("50"), OrderSizeCurrency: "BNB", OrderPrice: dec("111"), OrderPriceCurrency: "USDT"}
+	otherBnbTrade := Trade{ID: uuid.New(), OrderSizeLeft: dec("5"), OrderSizeCurrency: "BNB", OrderPrice: dec("100"), OrderPriceCurrency: "USDT"}
+	ethTrade := Trade{ID: uuid.New(), OrderSizeLeft: dec("2"), OrderSizeCurrency: "ETH", OrderPrice: dec("1500"), OrderPriceCurrency: "USDT"}
+
+	ticker := &orderbookticker.OrderBookTicker{Symbol: "BNBUSDT", BidPrice: dec("115"), BidQty: dec("100")}
+
+	orderBookTickerRepo := &OrderBookTickerRepositoryMock{}
+	orderBookTickerRepo.
+		On("FindAllBySymbols", []string{"BNBUSDT", "ETHUSDT"}).
+		Return(map[string]orderbookticker.Result{
+			"BNBUSDT": {Ticker: ticker},
+			"ETHUSDT": {Err: orderbookticker.ErrOrderBookTickerNotFound},
+		}, nil)
+
+	orderId := "1"
+	orderCreator := &OrderCreatorMock{}
+	orderCreator.
+		On("CreateOrder", mock.Anything, *ticker, mock.Anything).
+		Return(&orderId, nil)
+
+	tradeRepo := &TradeRepositoryMock{trades: []Trade{bnbTrade, ethTrade, otherBnbTrade}}
+
+	s := NewTrader(testLogger, orderBookTickerRepo, tradeRepo, orderCreator, AllocationFIFO, Guard{}, time.Now)
+
+	err := s.Watch(context.Background())
+	if err != nil {
+		t.Errorf("Watch() error = %v", err)
+	}
+
+	// one lookup for all symbols, the missing ETH ticker does not stop BNB trades
+	orderBookTickerRepo.AssertNumberOfCalls(t, "FindAllBySymbols", 1)
+	orderBookTickerRepo.AssertNotCalled(t, "FindOneBySymbol", mock.Anything)
+	orderCreator.AssertNumberOfCalls(t, "CreateOrder", 2)
+}
@@ -0,0 +1,31 @@
+package trade
+
+import (
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/pkg/logus"
+	"gorm.io/gorm"
+)
+
+type UnitOfWorkInterface interface {
+	Do(fn func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error) error
+}
+
+// UnitOfWork gives repositories sharing a single transaction, writes made through them are committed
+// together when fn returns nil and rolled back otherwise
+type UnitOfWork struct {
+	db     *gorm.DB
+	logger logus.Logger
+}
+
+func NewUnitOfWork(
+	db *gorm.DB,
+	logger logus.Logger,
+) UnitOfWork {
+	return UnitOfWork{db, logger}
+}
+
+func (u UnitOfWork) Do(fn func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepository(tx, u.logger), order.NewRepository(tx, u.logger))
+	})
+}
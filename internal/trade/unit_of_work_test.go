@@ -0,0 +1,132 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/beng90/trader/internal/exchange"
+	"github.com/beng90/trader/internal/order"
+	"github.com/beng90/trader/internal/orderbookticker"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failTradeUpdates makes updates of the trades table fail after the first skip of them passed,
+// writes to other tables still pass
+func failTradeUpdates(t *testing.T, db *gorm.DB, skip int) {
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_trade_updates", func(tx *gorm.DB) {
+		if tx.Statement.Table != "trades" {
+			return
+		}
+
+		if skip > 0 {
+			skip--
+
+			return
+		}
+
+		_ = tx.AddError(errInjected)
+	})
+	require.NoError(t, err)
+}
+
+func createTestTrade(t *testing.T, db *gorm.DB) Trade {
+	trade := Trade{
+		ID:                 uuid.New(),
+		OrderSize:          dec("50"),
+		OrderSizeLeft:      dec("50"),
+		OrderSizeCurrency:  "BNB",
+		OrderPrice:         dec("111"),
+		OrderPriceCurrency: "USDT",
+	}
+	require.NoError(t, db.Create(&trade).Error)
+
+	return trade
+}
+
+func TestUnitOfWork_Do(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+
+	err := NewUnitOfWork(db, testLogger).Do(func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error {
+		o := order.Order{OrderId: "1", TradeId: trade.ID, ExecutedSize: dec("20"), Fee: dec("2.3"), FeeAsset: "USDT", OtherFees: order.Fees{"BNB": dec("0.01")}}
+		if err := orderRepo.Create(o); err != nil {
+			return err
+		}
+
+		trade.OrderSizeLeft = dec("30")
+
+		return tradeRepo.Update(trade)
+	})
+	require.NoError(t, err)
+
+	orders, err := order.NewRepository(db, testLogger).FindAll(order.Filter{TradeId: trade.ID})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assertDecimal(t, "2.3", orders[0].Fee)
+	require.Len(t, orders[0].OtherFees, 1)
+	assertDecimal(t, "0.01", orders[0].OtherFees["BNB"])
+
+	stored, err := NewRepository(db, testLogger).FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "30", stored.OrderSizeLeft)
+}
+
+func TestUnitOfWork_DoRollback(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+	failTradeUpdates(t, db, 0)
+
+	err := NewUnitOfWork(db, testLogger).Do(func(tradeRepo RepositoryInterface, orderRepo order.RepositoryInterface) error {
+		o := order.Order{OrderId: "1", TradeId: trade.ID, ExecutedSize: dec("20"), Fee: dec("2.3"), FeeAsset: "USDT", OtherFees: order.Fees{"BNB": dec("0.01")}}
+		if err := orderRepo.Create(o); err != nil {
+			return err
+		}
+
+		trade.OrderSizeLeft = dec("30")
+
+		return tradeRepo.Update(trade)
+	})
+	assert.ErrorIs(t, err, errInjected)
+
+	// order insert is rolled back together with the failed trade update
+	var orders int64
+	require.NoError(t, db.Model(&order.Order{}).Count(&orders).Error)
+	assert.Equal(t, int64(0), orders)
+}
+
+func TestOrderCreator_CreateOrder_Rollback(t *testing.T) {
+	db := newTestDB(t)
+	trade := createTestTrade(t, db)
+	// reservation passes, giving back what was not executed fails
+	failTradeUpdates(t, db, 1)
+
+	sim := exchange.NewSimulated(testLogger)
+	sim.SetSymbol(bnbUsdt)
+	sim.SetBook("BNBUSDT", []exchange.PriceLevel{{Price: dec("115"), Quantity: dec("20")}}, nil)
+
+	trader := newTestTrader(t, db, sim)
+	tradeRepo, s := trader.tradeRepo, trader.orderCreator
+
+	oId, err := s.CreateOrder(context.Background(), trade, orderbookticker.OrderBookTicker{
+		Symbol:   "BNBUSDT",
+		BidPrice: dec("115"),
+		BidQty:   dec("30"),
+	}, Allocation{Policy: AllocationFIFO, Size: dec("30")})
+	assert.ErrorIs(t, err, errInjected)
+	assert.Nil(t, oId)
+
+	var orders int64
+	require.NoError(t, db.Model(&order.Order{}).Count(&orders).Error)
+	assert.Equal(t, int64(0), orders)
+
+	// order insert is rolled back, the whole order size stays reserved
+	stored, err := tradeRepo.FindOneById(trade.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "20", stored.OrderSizeLeft)
+}
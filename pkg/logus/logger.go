@@ -9,6 +9,7 @@ import (
 type Logger interface {
 	Debug(v ...any)
 	Debugf(format string, v ...any)
+	Infof(format string, v ...any)
 	Error(v ...any)
 	Fatal(v ...any)
 }
@@ -27,17 +28,21 @@ func (l *StdLogger) Debug(v ...any) {
 		return
 	}
 
-	l.logger.Println(v)
+	l.logger.Println(v...)
 }
 
 func (l *StdLogger) Debugf(format string, v ...any) {
 	l.logger.Printf(format, v...)
 }
 
+func (l *StdLogger) Infof(format string, v ...any) {
+	l.logger.Printf(format, v...)
+}
+
 func (l *StdLogger) Error(v ...any) {
-	l.logger.Println(v)
+	l.logger.Println(v...)
 }
 
 func (l *StdLogger) Fatal(v ...any) {
-	l.logger.Fatalln(v)
+	l.logger.Fatalln(v...)
 }
@@ -13,6 +13,9 @@ func (l *TestLogger) Debug(v ...any) {
 func (l *TestLogger) Debugf(format string, v ...any) {
 }
 
+func (l *TestLogger) Infof(format string, v ...any) {
+}
+
 func (l *TestLogger) Error(v ...any) {
 }
 